@@ -3,7 +3,6 @@ package evepraisal
 import (
 	"fmt"
 	"log"
-	"math"
 	"strings"
 	"time"
 
@@ -27,22 +26,30 @@ type Totals struct {
 // Appraisal represents an appraisal (duh?). This is what is persisted and returned to users. See cleanAppraisal
 // to see what is never returned to the user
 type Appraisal struct {
-	ID              string           `json:"id,omitempty"`
-	Created         int64            `json:"created"`
-	Kind            string           `json:"kind"`
-	MarketName      string           `json:"market_name"`
-	Totals          Totals           `json:"totals"`
-	Items           []AppraisalItem  `json:"items"`
-	Raw             string           `json:"raw"`
-	ParserLines     map[string][]int `json:"parser_lines,omitempty"`
-	Unparsed        map[int]string   `json:"unparsed"`
-	User            *User            `json:"user,omitempty"`
-	Private         bool             `json:"private"`
-	PrivateToken    string           `json:"private_token,omitempty"`
-	PricePercentage float64          `json:"price_percentage,omitempty"`
-	Live            bool             `json:"live"`
-	ExpireTime      *time.Time       `json:"expire_time,omitempty"`
-	ExpireMinutes   int64            `json:"expire_minutes,omitempty"`
+	ID              string               `json:"id,omitempty"`
+	Created         int64                `json:"created"`
+	Kind            string               `json:"kind"`
+	MarketName      string               `json:"market_name"`
+	Totals          Totals               `json:"totals"`
+	Items           []AppraisalItem      `json:"items"`
+	Raw             string               `json:"raw"`
+	ParserLines     map[string][]int     `json:"parser_lines,omitempty"`
+	Unparsed        map[int]string       `json:"unparsed"`
+	User            *User                `json:"user,omitempty"`
+	Private         bool                 `json:"private"`
+	PrivateToken    string               `json:"private_token,omitempty"`
+	PricePercentage float64              `json:"price_percentage,omitempty"`
+	Pricing         string               `json:"pricing,omitempty"`
+	SimulateDepth   bool                 `json:"simulate_depth,omitempty"`
+	Reprocessing    *ReprocessingOptions `json:"reprocessing,omitempty"`
+	Buyback         *BuybackProgram      `json:"buyback,omitempty"`
+	BestPrice       *BestPriceOptions    `json:"best_price,omitempty"`
+	HubTotals       []HubTotal           `json:"hub_totals,omitempty"`
+	SellPlan        *SellPlan            `json:"sell_plan,omitempty"`
+	Freight         *Freight             `json:"freight,omitempty"`
+	Live            bool                 `json:"live"`
+	ExpireTime      *time.Time           `json:"expire_time,omitempty"`
+	ExpireMinutes   int64                `json:"expire_minutes,omitempty"`
 }
 
 // IsExpired returns true if an appraisal is expired and should be deleted. Can be caused by ExpireTime or ExpireMinutes
@@ -74,6 +81,11 @@ func (appraisal *Appraisal) UsingPercentage() bool {
 	return true
 }
 
+// PricingPolicy returns the pricing policy that is used to value the items in the appraisal
+func (appraisal *Appraisal) PricingPolicy() PricingPolicy {
+	return pricingPolicyOrDefault(appraisal.Pricing)
+}
+
 // CreatedTime is the time that the appraisal was created (needed because the time is actually stored as a int64/unix timestamp)
 func (appraisal *Appraisal) CreatedTime() time.Time {
 	return time.Unix(appraisal.Created, 0)
@@ -97,49 +109,100 @@ func (appraisal *Appraisal) Summary() string {
 	return s
 }
 
-// AppraisalItem represents a single type of item and details the name, quantity, prices, etc. for the appraisal.
-type AppraisalItem struct {
-	Name       string  `json:"name"`
-	TypeID     int64   `json:"typeID"`
-	TypeName   string  `json:"typeName"`
-	TypeVolume float64 `json:"typeVolume"`
-	Quantity   int64   `json:"quantity"`
-	Prices     Prices  `json:"prices"`
-	Extra      struct {
-		Fitted     bool    `json:"fitted,omitempty"`
-		Dropped    bool    `json:"dropped,omitempty"`
-		Destroyed  bool    `json:"destroyed,omitempty"`
-		Location   string  `json:"location,omitempty"`
-		PlayerName string  `json:"player_name,omitempty"`
-		Routed     bool    `json:"routed,omitempty"`
-		Volume     float64 `json:"volume,omitempty"`
-		Distance   string  `json:"distance,omitempty"`
-		BPC        bool    `json:"bpc,omitempty"`
-		BPCRuns    int64   `json:"bpcRuns,omitempty"`
-	} `json:"meta,omitempty"`
+// AppraisalOptions are the user-selected settings that change how an appraisal is priced
+type AppraisalOptions struct {
+	MarketName      string
+	PricePercentage float64
+	Pricing         string
+	SimulateDepth   bool
+	Reprocessing    *ReprocessingOptions
+	Buyback         *BuybackProgram
+	BestPrice       *BestPriceOptions
 }
 
+// AppraisalItem represents a single type of item and details the name, quantity, prices, etc. for the appraisal.
+type AppraisalItem struct {
+	Name          string             `json:"name"`
+	TypeID        int64              `json:"typeID"`
+	TypeName      string             `json:"typeName"`
+	TypeVolume    float64            `json:"typeVolume"`
+	Quantity      int64              `json:"quantity"`
+	Prices        Prices             `json:"prices"`
+	Depth         *ItemDepth         `json:"depth,omitempty"`
+	MarketHistory *ItemMarketHistory `json:"market_history,omitempty"`
+	Warnings      []ItemWarning      `json:"warnings,omitempty"`
+	Reprocessed   *ItemReprocessing  `json:"reprocessed,omitempty"`
+	BPCValue      *ItemBPC           `json:"bpc_value,omitempty"`
+	Buyback       *ItemBuyback       `json:"buyback,omitempty"`
+	BestPrice     *ItemBestPrice     `json:"best_price,omitempty"`
+	SellPlan      *ItemSellPlan      `json:"sell_plan,omitempty"`
+	Pricing       string             `json:"-"`
+	Extra         AppraisalItemExtra `json:"meta,omitempty"`
+}
+
+// AppraisalItemExtra holds details about an item that depend on what kind of paste it came from
+type AppraisalItemExtra struct {
+	Fitted     bool    `json:"fitted,omitempty"`
+	Dropped    bool    `json:"dropped,omitempty"`
+	Destroyed  bool    `json:"destroyed,omitempty"`
+	Location   string  `json:"location,omitempty"`
+	PlayerName string  `json:"player_name,omitempty"`
+	Routed     bool    `json:"routed,omitempty"`
+	Volume     float64 `json:"volume,omitempty"`
+	Distance   string  `json:"distance,omitempty"`
+	BPC        bool    `json:"bpc,omitempty"`
+	BPCRuns    int64   `json:"bpcRuns,omitempty"`
+	ME         int64   `json:"me,omitempty"`
+	TE         int64   `json:"te,omitempty"`
+}
+
+// SellPrice is the sell value of a single item using the appraisal's pricing policy
 func (i AppraisalItem) SellPrice() float64 {
-	if i.Prices.Sell.Percentile-i.Prices.Sell.Min > i.Prices.Sell.Min*0.02 {
-		return i.Prices.Sell.Min
-	}
-	return i.Prices.Sell.Percentile
+	return pricingPolicyOrDefault(i.Pricing).Sell(i.Prices)
 }
 
+// BuyPrice is the buy value of a single item using the appraisal's pricing policy
 func (i AppraisalItem) BuyPrice() float64 {
-	if i.Prices.Buy.Max-i.Prices.Buy.Percentile > i.Prices.Buy.Max*0.02 {
-		return i.Prices.Buy.Max
-	}
-	return i.Prices.Buy.Percentile
+	return pricingPolicyOrDefault(i.Pricing).Buy(i.Prices)
+}
+
+// ItemDepth holds what selling the full quantity of an item against the market's order book would give
+type ItemDepth struct {
+	Buy  OrderBookFill `json:"buy"`
+	Sell OrderBookFill `json:"sell"`
 }
 
 // SellTotal is used to give a representative sell total for an item
 func (i AppraisalItem) SellTotal() float64 {
+	if i.Buyback != nil {
+		return i.Buyback.Total
+	}
+	if i.BestPrice != nil {
+		return float64(i.Quantity) * i.BestPrice.SellPrice
+	}
+	if i.Reprocessed != nil {
+		return i.Reprocessed.Sell
+	}
+	if i.Depth != nil {
+		return i.Depth.Sell.Total
+	}
 	return float64(i.Quantity) * i.SellPrice()
 }
 
 // BuyTotal is used to give a representative buy total for an item
 func (i AppraisalItem) BuyTotal() float64 {
+	if i.Buyback != nil {
+		return i.Buyback.Total
+	}
+	if i.BestPrice != nil {
+		return float64(i.Quantity) * i.BestPrice.BuyPrice
+	}
+	if i.Reprocessed != nil {
+		return i.Reprocessed.Buy
+	}
+	if i.Depth != nil {
+		return i.Depth.Buy.Total
+	}
 	return float64(i.Quantity) * i.BuyPrice()
 }
 
@@ -156,10 +219,10 @@ func (i AppraisalItem) BuyISKVolume() float64 {
 // SingleRepresentativePrice is used to give a representative price for a single item
 func (i AppraisalItem) SingleRepresentativePrice() float64 {
 	if i.SellPrice() != 0 {
-		return i.Prices.Sell.Percentile
+		return i.SellPrice()
 	}
 
-	return i.Prices.Buy.Percentile
+	return i.BuyPrice()
 }
 
 // RepresentativePrice is used to give a representative price for an item. This is used for sorting.
@@ -305,6 +368,9 @@ type PriceStats struct {
 	Stddev     float64 `json:"stddev"`
 	Volume     int64   `json:"volume"`
 	OrderCount int64   `json:"order_count"`
+
+	// ExcludedOrderCount is the number of orders that were filtered out as outliers and not used for these stats
+	ExcludedOrderCount int64 `json:"excluded_order_count,omitempty"`
 }
 
 // PricesForItem will look up market prices for the given item in the given market
@@ -315,62 +381,18 @@ func (app *App) PricesForItem(market string, item AppraisalItem) (Prices, error)
 	)
 
 	if item.Extra.BPC {
-		tName := strings.TrimSuffix(item.TypeName, " Blueprint")
-		bpType, ok := app.TypeDB.GetType(tName)
-		if !ok {
-			log.Printf("WARN: parsed out name that isn't a type: %q", tName)
+		bpc := app.BPCForItem(market, item)
+		if bpc == nil {
 			return prices, err
 		}
-
-		marketMarket := market
-		// If the user selected "universe" as the market then it is fairly likely that someone has a
-		// rediculously low price in a station no one wants to travel to. To avoid negative "value"
-		// for blueprint copies, we're forcing this item to be sold at jita prices Z
-		if marketMarket == "universe" {
-			marketMarket = "jita"
-		}
-
-		marketPrices := Prices{Strategy: "bpc"}
-		for _, product := range bpType.BlueprintProducts {
-			p, ok := app.PriceDB.GetPrice(marketMarket, product.TypeID)
-			if !ok {
-				log.Printf("WARN: No market data for type (%d %s)", item.TypeID, item.TypeName)
-				continue
-			}
-
-			marketPrices = marketPrices.Add(p.Set(p.Sell.Min).Mul(float64(product.Quantity)))
-		}
-
-		manufacturedPrices := Prices{Strategy: "bpc"}
-		for _, component := range bpType.Components {
-			p, ok := app.PriceDB.GetPrice(market, component.TypeID)
-			if !ok {
-				log.Println("Failed getting getting price for component", component.TypeID)
-				continue
-			}
-			manufacturedPrices = manufacturedPrices.Add(p.Set(math.Min(p.Sell.Min, p.Buy.Max)).Mul(float64(component.Quantity)))
-		}
-
-		// Assume Industry V (+10%) and misc costs (-1%)
-		manufacturedPrices = manufacturedPrices.Mul(0.91)
-		// prices := marketPrices.Sub(manufacturedPrices).Mul(float64(item.Extra.BPCRuns))
-
-		// log.Println("BPC Name: ", item.TypeName)
-		// log.Println("BPC materials:", manufacturedPrices)
-		// log.Println("BPC item value:", marketPrices)
-		log.Printf("BPC price for %s: %v, Item price: %v", tName, marketPrices.Sub(manufacturedPrices), marketPrices)
-
-		// bpcPrice := marketPrices.Sub(manufacturedPrices)
-		// if bpcPrice.Sell.Min > 0 && bpcPrice.Buy.Max > 0 {
-		// 	return bpcPrice, nil
-		// }
-
-		return Prices{}, nil
-		// return prices, nil
+		return bpc.Prices(), nil
 	}
 
-	prices, _ = app.PriceDB.GetPrice(market, item.TypeID)
-	return prices, nil
+	t, ok := app.TypeDB.GetTypeByID(item.TypeID)
+	if !ok {
+		t = typedb.EveType{ID: item.TypeID}
+	}
+	return app.fallbackPrices(market, t), nil
 }
 
 // PopulateItems will populate appraisal items with type and price information
@@ -379,6 +401,13 @@ func (app *App) PopulateItems(appraisal *Appraisal) {
 	appraisal.Totals.Sell = 0
 	appraisal.Totals.Volume = 0
 
+	var hubs []Market
+	if appraisal.BestPrice != nil {
+		hubs = app.BestPriceMarkets(*appraisal.BestPrice)
+	}
+
+	types := make([]typedb.EveType, 0, len(appraisal.Items))
+	found := make([]bool, len(appraisal.Items))
 	for i := 0; i < len(appraisal.Items); i++ {
 		var (
 			t  typedb.EveType
@@ -404,29 +433,159 @@ func (app *App) PopulateItems(appraisal *Appraisal) {
 		} else {
 			appraisal.Items[i].TypeVolume = t.Volume
 		}
+		types = append(types, t)
+		found[i] = true
+	}
+	app.prefetchPrices(appraisal, types)
 
-		prices, err := app.PricesForItem(appraisal.MarketName, appraisal.Items[i])
-		if err != nil {
+	for i := 0; i < len(appraisal.Items); i++ {
+		if !found[i] {
 			continue
 		}
+
+		// Blueprint copies are priced by their value, which is only worked out once since it reads every component
+		var prices Prices
+		appraisal.Items[i].BPCValue = app.BPCForItem(appraisal.MarketName, appraisal.Items[i])
+		if appraisal.Items[i].Extra.BPC {
+			if appraisal.Items[i].BPCValue != nil {
+				prices = appraisal.Items[i].BPCValue.Prices()
+			}
+		} else {
+			var err error
+			prices, err = app.PricesForItem(appraisal.MarketName, appraisal.Items[i])
+			if err != nil {
+				continue
+			}
+		}
 		if appraisal.PricePercentage > 0 {
 			prices = prices.Mul(appraisal.PricePercentage / 100)
 		}
 		appraisal.Items[i].Prices = prices
-		appraisal.Totals.Buy += prices.Buy.Max * float64(appraisal.Items[i].Quantity)
-		appraisal.Totals.Sell += prices.Sell.Min * float64(appraisal.Items[i].Quantity)
+		appraisal.Items[i].Pricing = appraisal.Pricing
+
+		if appraisal.Items[i].BPCValue != nil && appraisal.PricePercentage > 0 {
+			*appraisal.Items[i].BPCValue = appraisal.Items[i].BPCValue.Mul(appraisal.PricePercentage / 100)
+		}
+
+		appraisal.Items[i].MarketHistory = nil
+		if !appraisal.Items[i].Extra.BPC {
+			appraisal.Items[i].MarketHistory = app.MarketHistoryForItem(appraisal.MarketName, appraisal.Items[i].TypeID, time.Now())
+		}
+		if appraisal.Items[i].MarketHistory != nil {
+			appraisal.Items[i].MarketHistory.DaysToSell = appraisal.Items[i].DaysToSell(appraisal.Items[i].Quantity)
+		}
+		appraisal.Items[i].Warnings = app.WarningsForItem(appraisal.MarketName, appraisal.Items[i], time.Now())
+
+		appraisal.Items[i].Depth = nil
+		if appraisal.SimulateDepth && !appraisal.Items[i].Extra.BPC {
+			appraisal.Items[i].Depth = app.DepthForItem(appraisal.MarketName, appraisal.Items[i])
+		}
+
+		if appraisal.Items[i].Depth != nil {
+			if appraisal.PricePercentage > 0 {
+				appraisal.Items[i].Depth.Buy = appraisal.Items[i].Depth.Buy.Mul(appraisal.PricePercentage / 100)
+				appraisal.Items[i].Depth.Sell = appraisal.Items[i].Depth.Sell.Mul(appraisal.PricePercentage / 100)
+			}
+		}
+
+		appraisal.Items[i].Reprocessed = nil
+		if appraisal.Reprocessing != nil && !appraisal.Items[i].Extra.BPC {
+			appraisal.Items[i].Reprocessed = app.ReprocessingForItem(appraisal.MarketName, appraisal.Items[i], *appraisal.Reprocessing, appraisal.PricePercentage)
+		}
+
+		// Buyback programs have their own percentages so the price percentage isn't used here
+		appraisal.Items[i].Buyback = nil
+		if appraisal.Buyback != nil {
+			appraisal.Items[i].Buyback = app.BuybackForItem(appraisal.MarketName, appraisal.Items[i], *appraisal.Buyback)
+		}
+
+		appraisal.Items[i].BestPrice = nil
+		if appraisal.BestPrice != nil && !appraisal.Items[i].Extra.BPC {
+			appraisal.Items[i].BestPrice = app.BestPriceForItem(hubs, appraisal.Items[i], appraisal.PricingPolicy())
+		}
+		if appraisal.Items[i].BestPrice != nil && appraisal.PricePercentage > 0 {
+			appraisal.Items[i].BestPrice.SellPrice *= appraisal.PricePercentage / 100
+			appraisal.Items[i].BestPrice.BuyPrice *= appraisal.PricePercentage / 100
+		}
+
+		appraisal.Totals.Buy += appraisal.Items[i].BuyTotal()
+		appraisal.Totals.Sell += appraisal.Items[i].SellTotal()
 		appraisal.Totals.Volume += appraisal.Items[i].TypeVolume * float64(appraisal.Items[i].Quantity)
 	}
+
+	appraisal.HubTotals = nil
+	if appraisal.BestPrice != nil {
+		appraisal.HubTotals = HubTotals(appraisal.Items)
+	}
+}
+
+// prefetchPrices reads the prices that the items of an appraisal are likely to need, one market at a time, so that
+// they're already cached when the items are priced one by one
+func (app *App) prefetchPrices(appraisal *Appraisal, types []typedb.EveType) {
+	seen := make(map[int64]bool)
+	typeIDs := make([]int64, 0, len(types))
+	add := func(typeID int64) {
+		if !seen[typeID] {
+			seen[typeID] = true
+			typeIDs = append(typeIDs, typeID)
+		}
+	}
+	for _, t := range types {
+		add(t.ID)
+		for _, component := range t.Components {
+			add(component.TypeID)
+		}
+		if appraisal.Reprocessing != nil {
+			for _, material := range t.Materials {
+				add(material.TypeID)
+			}
+		}
+	}
+	if len(typeIDs) == 0 {
+		return
+	}
+
+	markets := []string{appraisal.MarketName}
+	if appraisal.MarketName != UniverseMarketName {
+		markets = append(markets, UniverseMarketName)
+	}
+	markets = append(markets, CCPMarketName)
+	for _, market := range markets {
+		_, err := app.PriceDB.GetPrices(market, typeIDs)
+		if err != nil {
+			log.Printf("WARN: prefetching prices for %s: %s", market, err)
+			return
+		}
+	}
 }
 
-// StringToAppraisal is the big function that everything is based on. It returns a full appraisal at the given
-// market with a string of the appraisal contents (and pricePercentage).
-func (app *App) StringToAppraisal(market string, s string, pricePercentage float64) (*Appraisal, error) {
+// DepthForItem walks the order book of the given market to find out what selling the full quantity of the item would
+// give. It returns nil if there is no order book for the item.
+func (app *App) DepthForItem(market string, item AppraisalItem) *ItemDepth {
+	book, ok := app.PriceDB.GetOrderBook(market, item.TypeID)
+	if !ok {
+		return nil
+	}
+
+	return &ItemDepth{
+		Buy:  book.DumpToBuyOrders(item.Quantity),
+		Sell: book.UndercutSellOrders(item.Quantity),
+	}
+}
+
+// StringToAppraisal is the big function that everything is based on. It returns a full appraisal with a string of
+// the appraisal contents priced using the given options.
+func (app *App) StringToAppraisal(s string, options AppraisalOptions) (*Appraisal, error) {
 	appraisal := &Appraisal{
 		Created:         time.Now().Unix(),
 		Raw:             s,
-		PricePercentage: pricePercentage,
-		MarketName:      market,
+		PricePercentage: options.PricePercentage,
+		MarketName:      options.MarketName,
+		Pricing:         options.Pricing,
+		SimulateDepth:   options.SimulateDepth,
+		Reprocessing:    options.Reprocessing,
+		Buyback:         options.Buyback,
+		BestPrice:       options.BestPrice,
 	}
 
 	result, unparsed := app.Parser(parsers.StringToInput(s))
@@ -534,6 +693,8 @@ func parserResultToAppraisalItems(result parsers.ParserResult) []AppraisalItem {
 			if item.BPC {
 				newItem.Extra.BPCRuns = item.BPCRuns
 			}
+			newItem.Extra.ME = item.ME
+			newItem.Extra.TE = item.TE
 			items = append(items, newItem)
 		}
 	case *parsers.Killmail:
@@ -627,16 +788,20 @@ func parserResultToAppraisalItems(result parsers.ParserResult) []AppraisalItem {
 		}
 	}
 
-	mappedItems := make(map[AppraisalItem]int64)
+	// Merge items that are the same other than quantity
+	type itemKey struct {
+		Name  string
+		Extra AppraisalItemExtra
+	}
+	mappedItems := make(map[itemKey]int64)
 	for _, item := range items {
 		item.Name = strings.Trim(item.Name, " \t")
-		mappedItems[item] += item.Quantity
+		mappedItems[itemKey{Name: item.Name, Extra: item.Extra}] += item.Quantity
 	}
 
 	returnItems := make([]AppraisalItem, 0, len(mappedItems))
-	for item, quantity := range mappedItems {
-		item.Quantity = quantity
-		returnItems = append(returnItems, item)
+	for key, quantity := range mappedItems {
+		returnItems = append(returnItems, AppraisalItem{Name: key.Name, Quantity: quantity, Extra: key.Extra})
 	}
 
 	return returnItems
@@ -651,7 +816,6 @@ func filterUnparsed(unparsed map[int]string) map[int]string {
 	return unparsed
 }
 
-//nolint:unused
 func priceByComponents(t typedb.EveType, priceDB PriceDB, market string) Prices {
 	var prices Prices
 	for _, component := range t.Components {
@@ -0,0 +1,300 @@
+package evepraisal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// ArbitrageOptions decide which hubs are compared and how big a spread has to be to be listed
+type ArbitrageOptions struct {
+	// Markets are the hubs that are compared. Every market that isn't the universe or a composite market is used when
+	// this is empty.
+	Markets []string `mapstructure:"markets" json:"markets"`
+	// MinMargin is the smallest profit, as a percentage of the buy price after sales tax, that is listed
+	MinMargin float64 `mapstructure:"min_margin" json:"min_margin"`
+	// AccountingSkill lowers the sales tax that is paid when selling into buy orders
+	AccountingSkill int64 `mapstructure:"accounting_skill" json:"accounting_skill"`
+}
+
+// DefaultArbitrageOptions list spreads of at least 5% for a character with Accounting V
+var DefaultArbitrageOptions = ArbitrageOptions{
+	MinMargin:       5,
+	AccountingSkill: 5,
+}
+
+// CheckArbitrageOptions returns an error if the options can't be used with the given markets
+func CheckArbitrageOptions(options ArbitrageOptions, markets []Market) error {
+	for _, name := range options.Markets {
+		found := false
+		for _, market := range markets {
+			if market.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown market %q", name)
+		}
+	}
+	if options.MinMargin < 0 {
+		return fmt.Errorf("min_margin can't be negative")
+	}
+	if options.AccountingSkill < 0 || options.AccountingSkill > 5 {
+		return fmt.Errorf("accounting_skill must be between 0 and 5")
+	}
+	return nil
+}
+
+// NormalizeArbitrageOptions fills in the hubs that are compared when none are given
+func NormalizeArbitrageOptions(options ArbitrageOptions, markets []Market) ArbitrageOptions {
+	if len(options.Markets) > 0 {
+		return options
+	}
+	for _, market := range markets {
+		if market.IsUniverse() || market.IsComposite() {
+			continue
+		}
+		options.Markets = append(options.Markets, market.Name)
+	}
+	return options
+}
+
+// ArbitrageOpportunity is a type that can be bought from the sell orders in one hub and sold into the buy orders of
+// another for a profit. OrderVolume is the smaller of the volume for sale in BuyMarket and the volume wanted in
+// SellMarket, so Profit is the most that can be made if every order is at the best price.
+type ArbitrageOpportunity struct {
+	TypeID        int64   `json:"type_id"`
+	TypeName      string  `json:"type_name"`
+	MarketGroupID int64   `json:"market_group_id"`
+	TypeVolume    float64 `json:"type_volume"`
+	BuyMarket     string  `json:"buy_market"`
+	BuyPrice      float64 `json:"buy_price"`
+	SellMarket    string  `json:"sell_market"`
+	SellPrice     float64 `json:"sell_price"`
+	// Margin is the profit after sales tax as a percentage of the buy price
+	Margin        float64 `json:"margin"`
+	ProfitPerUnit float64 `json:"profit_per_unit"`
+	ISKPerM3      float64 `json:"isk_per_m3"`
+	OrderVolume   int64   `json:"order_volume"`
+	Profit        float64 `json:"profit"`
+}
+
+// ArbitrageFilter narrows down the listed opportunities. Zero values don't filter anything.
+type ArbitrageFilter struct {
+	MinMargin float64
+	// MaxTypeVolume is the largest volume of a single unit
+	MaxTypeVolume  float64
+	MinISKPerM3    float64
+	MinOrderVolume int64
+	MarketGroupID  int64
+}
+
+// Matches returns true if the opportunity passes the filter
+func (filter ArbitrageFilter) Matches(o ArbitrageOpportunity) bool {
+	switch {
+	case o.Margin < filter.MinMargin:
+		return false
+	case filter.MaxTypeVolume > 0 && o.TypeVolume > filter.MaxTypeVolume:
+		return false
+	case o.ISKPerM3 < filter.MinISKPerM3:
+		return false
+	case o.OrderVolume < filter.MinOrderVolume:
+		return false
+	case filter.MarketGroupID != 0 && o.MarketGroupID != filter.MarketGroupID:
+		return false
+	}
+	return true
+}
+
+// arbitrageQuote is the part of a hub's prices that arbitrage needs
+type arbitrageQuote struct {
+	sell       float64
+	sellVolume int64
+	buy        float64
+	buyVolume  int64
+}
+
+// ArbitrageFinder watches the prices that are saved to a PriceDB and finds the spreads between hubs whenever they
+// change, so listing them is cheap.
+type ArbitrageFinder struct {
+	PriceDB
+	options ArbitrageOptions
+	hubs    map[string]bool
+	// Types looks up the name, volume and market group of a type. Types that it doesn't know are skipped.
+	Types func(typeID int64) (typedb.EveType, bool)
+
+	lock   sync.RWMutex
+	quotes map[int64]map[string]arbitrageQuote
+	// best is the best opportunity of each type that has one and opportunities are all of them, sorted
+	best          map[int64]ArbitrageOpportunity
+	opportunities []ArbitrageOpportunity
+	updated       time.Time
+}
+
+// NewArbitrageFinder returns an arbitrage finder that watches the prices saved through it to priceDB
+func NewArbitrageFinder(priceDB PriceDB, options ArbitrageOptions) *ArbitrageFinder {
+	hubs := make(map[string]bool, len(options.Markets))
+	for _, market := range options.Markets {
+		hubs[market] = true
+	}
+	return &ArbitrageFinder{
+		PriceDB: priceDB,
+		options: options,
+		hubs:    hubs,
+		quotes:  make(map[int64]map[string]arbitrageQuote),
+		best:    make(map[int64]ArbitrageOpportunity),
+	}
+}
+
+// Options returns the options that the finder was made with
+func (f *ArbitrageFinder) Options() ArbitrageOptions {
+	return f.options
+}
+
+// UpdatePrices saves the prices to the PriceDB and finds the spreads again for the types whose hub prices changed
+func (f *ArbitrageFinder) UpdatePrices(items []MarketItemPrices) error {
+	err := f.PriceDB.UpdatePrices(items)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	changed := make(map[int64]bool)
+	for _, item := range items {
+		if !f.hubs[item.Market] {
+			continue
+		}
+		// Only a hub's own orders can be traded against. A price that was copied from the universe or from CCP means
+		// that the hub has too few orders of its own.
+		if item.Prices.Strategy != "orders" {
+			if _, ok := f.quotes[item.TypeID][item.Market]; ok {
+				delete(f.quotes[item.TypeID], item.Market)
+				changed[item.TypeID] = true
+			}
+			continue
+		}
+		if f.quotes[item.TypeID] == nil {
+			f.quotes[item.TypeID] = make(map[string]arbitrageQuote)
+		}
+		f.quotes[item.TypeID][item.Market] = arbitrageQuote{
+			sell:       item.Prices.Sell.Min,
+			sellVolume: item.Prices.Sell.Volume,
+			buy:        item.Prices.Buy.Max,
+			buyVolume:  item.Prices.Buy.Volume,
+		}
+		changed[item.TypeID] = true
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	for typeID := range changed {
+		best, ok := f.find(typeID)
+		if ok {
+			f.best[typeID] = best
+		} else {
+			delete(f.best, typeID)
+		}
+		if len(f.quotes[typeID]) == 0 {
+			delete(f.quotes, typeID)
+		}
+	}
+	f.opportunities = f.sorted()
+	f.updated = time.Now()
+	return nil
+}
+
+// find returns the best opportunity for a type. The lock must be held.
+func (f *ArbitrageFinder) find(typeID int64) (ArbitrageOpportunity, bool) {
+	salesTax := SellPlanOptions{AccountingSkill: f.options.AccountingSkill}.SalesTax() / 100
+	quotes := f.quotes[typeID]
+	var (
+		best  ArbitrageOpportunity
+		found bool
+	)
+	for buyMarket, from := range quotes {
+		if from.sell <= 0 || from.sellVolume <= 0 {
+			continue
+		}
+		for sellMarket, to := range quotes {
+			if sellMarket == buyMarket || to.buy <= 0 || to.buyVolume <= 0 {
+				continue
+			}
+			profit := to.buy*(1-salesTax) - from.sell
+			margin := profit / from.sell * 100
+			if margin < f.options.MinMargin || (found && margin <= best.Margin) {
+				continue
+			}
+			found = true
+			best = ArbitrageOpportunity{
+				TypeID:        typeID,
+				BuyMarket:     buyMarket,
+				BuyPrice:      from.sell,
+				SellMarket:    sellMarket,
+				SellPrice:     to.buy,
+				Margin:        margin,
+				ProfitPerUnit: profit,
+				OrderVolume:   minInt64(from.sellVolume, to.buyVolume),
+			}
+		}
+	}
+	if !found || f.Types == nil {
+		return best, false
+	}
+
+	t, ok := f.Types(typeID)
+	if !ok {
+		return best, false
+	}
+	best.TypeName = t.Name
+	best.MarketGroupID = t.MarketGroupID
+	best.TypeVolume = t.Volume
+	if t.PackagedVolume != 0.0 {
+		best.TypeVolume = t.PackagedVolume
+	}
+	if best.TypeVolume > 0 {
+		best.ISKPerM3 = best.ProfitPerUnit / best.TypeVolume
+	}
+	best.Profit = best.ProfitPerUnit * float64(best.OrderVolume)
+	return best, true
+}
+
+// sorted returns the best opportunity of every type, largest profit first. The lock must be held.
+func (f *ArbitrageFinder) sorted() []ArbitrageOpportunity {
+	opportunities := make([]ArbitrageOpportunity, 0, len(f.best))
+	for _, best := range f.best {
+		opportunities = append(opportunities, best)
+	}
+	sort.Slice(opportunities, func(i, j int) bool {
+		if opportunities[i].Profit != opportunities[j].Profit {
+			return opportunities[i].Profit > opportunities[j].Profit
+		}
+		return opportunities[i].TypeID < opportunities[j].TypeID
+	})
+	return opportunities
+}
+
+// Opportunities returns the opportunities that pass the filter, largest profit first, and when they were found
+func (f *ArbitrageFinder) Opportunities(filter ArbitrageFilter) ([]ArbitrageOpportunity, time.Time) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	opportunities := make([]ArbitrageOpportunity, 0)
+	for _, o := range f.opportunities {
+		if filter.Matches(o) {
+			opportunities = append(opportunities, o)
+		}
+	}
+	return opportunities, f.updated
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
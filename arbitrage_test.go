@@ -0,0 +1,155 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testArbitragePrices(market string, typeID int64, sell float64, sellVolume int64, buy float64, buyVolume int64) MarketItemPrices {
+	prices := Prices{Strategy: "orders"}
+	prices.Sell.Min = sell
+	prices.Sell.Volume = sellVolume
+	prices.Buy.Max = buy
+	prices.Buy.Volume = buyVolume
+	return MarketItemPrices{Market: market, TypeID: typeID, Prices: prices}
+}
+
+func TestArbitrageFinder(t *testing.T) {
+	priceDB := &testUpdatePriceDB{}
+	finder := NewArbitrageFinder(priceDB, ArbitrageOptions{Markets: []string{"jita", "amarr", "dodixie"}, MinMargin: 5, AccountingSkill: 5})
+	types := map[int64]typedb.EveType{
+		34: {ID: 34, Name: "Tritanium", Volume: 0.01, MarketGroupID: 1857},
+		35: {ID: 35, Name: "Pyerite", Volume: 0.01, MarketGroupID: 1857},
+		36: {ID: 36, Name: "Cargo Container", Volume: 1000, PackagedVolume: 10, MarketGroupID: 1651},
+	}
+	lookups := 0
+	finder.Types = func(typeID int64) (typedb.EveType, bool) {
+		lookups++
+		t, ok := types[typeID]
+		return t, ok
+	}
+
+	_, updated := finder.Opportunities(ArbitrageFilter{})
+	assert.True(t, updated.IsZero())
+
+	err := finder.UpdatePrices([]MarketItemPrices{
+		// Buying in jita and selling in amarr makes 4.8 * 0.96625 - 4 = 0.638 per unit (16%), which beats dodixie
+		testArbitragePrices("jita", 34, 4, 1000, 3.9, 5000),
+		testArbitragePrices("amarr", 34, 5, 1000, 4.8, 500),
+		testArbitragePrices("dodixie", 34, 5, 1000, 4.2, 5000),
+		// 4.2 * 0.96625 - 4 is only 1%
+		testArbitragePrices("jita", 35, 4, 1000, 3.9, 5000),
+		testArbitragePrices("amarr", 35, 5, 1000, 4.2, 5000),
+		// Nobody is selling in dodixie, so it can only be bought in jita
+		testArbitragePrices("jita", 36, 100, 10, 90, 10),
+		testArbitragePrices("dodixie", 36, 0, 0, 200, 5),
+		// Markets that aren't compared are only saved
+		testArbitragePrices("hek", 35, 1, 1000, 1, 1000),
+		// Types that aren't known are left out
+		testArbitragePrices("jita", 37, 1, 1000, 1, 1000),
+		testArbitragePrices("amarr", 37, 2, 1000, 2, 1000),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, priceDB.prices["hek"], 1)
+
+	opportunities, updated := finder.Opportunities(ArbitrageFilter{})
+	assert.False(t, updated.IsZero())
+	if assert.Len(t, opportunities, 2) {
+		// The most profitable comes first
+		o := opportunities[0]
+		assert.Equal(t, int64(36), o.TypeID)
+		assert.Equal(t, "jita", o.BuyMarket)
+		assert.Equal(t, "dodixie", o.SellMarket)
+		assert.Equal(t, 10.0, o.TypeVolume)
+		assert.Equal(t, int64(5), o.OrderVolume)
+		assert.InDelta(t, 93.25, o.ProfitPerUnit, 0.0001)
+		assert.InDelta(t, 9.325, o.ISKPerM3, 0.0001)
+		assert.InDelta(t, 466.25, o.Profit, 0.0001)
+
+		o = opportunities[1]
+		assert.Equal(t, int64(34), o.TypeID)
+		assert.Equal(t, "Tritanium", o.TypeName)
+		assert.Equal(t, "jita", o.BuyMarket)
+		assert.Equal(t, 4.0, o.BuyPrice)
+		assert.Equal(t, "amarr", o.SellMarket)
+		assert.Equal(t, 4.8, o.SellPrice)
+		assert.InDelta(t, 15.95, o.Margin, 0.0001)
+		assert.InDelta(t, 63.8, o.ISKPerM3, 0.0001)
+		assert.Equal(t, int64(500), o.OrderVolume)
+		assert.InDelta(t, 319, o.Profit, 0.0001)
+	}
+
+	// Only the types with a spread are looked up
+	assert.Equal(t, 3, lookups)
+
+	// Spreads are found again for the types whose prices changed
+	err = finder.UpdatePrices([]MarketItemPrices{testArbitragePrices("amarr", 34, 5, 1000, 3, 500)})
+	assert.NoError(t, err)
+	opportunities, _ = finder.Opportunities(ArbitrageFilter{})
+	if assert.Len(t, opportunities, 1) {
+		assert.Equal(t, int64(36), opportunities[0].TypeID)
+	}
+	assert.Equal(t, 3, lookups)
+
+	err = finder.UpdatePrices([]MarketItemPrices{testArbitragePrices("dodixie", 36, 0, 0, 300, 5)})
+	assert.NoError(t, err)
+	opportunities, _ = finder.Opportunities(ArbitrageFilter{})
+	if assert.Len(t, opportunities, 1) {
+		assert.InDelta(t, 189.875, opportunities[0].ProfitPerUnit, 0.0001)
+	}
+	assert.Equal(t, 4, lookups)
+}
+
+func TestArbitrageFinderOnlyUsesHubOrders(t *testing.T) {
+	finder := NewArbitrageFinder(&testUpdatePriceDB{}, ArbitrageOptions{Markets: []string{"jita", "amarr"}, MinMargin: 5})
+	finder.Types = func(typeID int64) (typedb.EveType, bool) {
+		return typedb.EveType{ID: typeID, Name: "Tritanium", Volume: 0.01}, true
+	}
+	copied := func(market string, strategy string) MarketItemPrices {
+		item := testArbitragePrices(market, 34, 10, 1000, 10, 1000)
+		item.Prices.Strategy = strategy
+		return item
+	}
+
+	// The universe and CCP prices that are stored for a hub with too few orders can't be traded against
+	assert.NoError(t, finder.UpdatePrices([]MarketItemPrices{
+		testArbitragePrices("jita", 34, 4, 1000, 3.9, 5000),
+		copied("amarr", "orders_universe"),
+	}))
+	opportunities, _ := finder.Opportunities(ArbitrageFilter{})
+	assert.Empty(t, opportunities)
+
+	assert.NoError(t, finder.UpdatePrices([]MarketItemPrices{testArbitragePrices("amarr", 34, 5, 1000, 4.8, 500)}))
+	opportunities, _ = finder.Opportunities(ArbitrageFilter{})
+	assert.Len(t, opportunities, 1)
+
+	// A copy replaces the hub's orders once they're gone
+	assert.NoError(t, finder.UpdatePrices([]MarketItemPrices{copied("amarr", "ccp")}))
+	opportunities, _ = finder.Opportunities(ArbitrageFilter{})
+	assert.Empty(t, opportunities)
+}
+
+func TestArbitrageFilter(t *testing.T) {
+	o := ArbitrageOpportunity{Margin: 10, TypeVolume: 5, ISKPerM3: 100, OrderVolume: 50, MarketGroupID: 1857}
+	assert.True(t, ArbitrageFilter{}.Matches(o))
+	assert.True(t, ArbitrageFilter{MinMargin: 10, MaxTypeVolume: 5, MinISKPerM3: 100, MinOrderVolume: 50, MarketGroupID: 1857}.Matches(o))
+	assert.False(t, ArbitrageFilter{MinMargin: 11}.Matches(o))
+	assert.False(t, ArbitrageFilter{MaxTypeVolume: 4}.Matches(o))
+	assert.False(t, ArbitrageFilter{MinISKPerM3: 101}.Matches(o))
+	assert.False(t, ArbitrageFilter{MinOrderVolume: 51}.Matches(o))
+	assert.False(t, ArbitrageFilter{MarketGroupID: 1}.Matches(o))
+}
+
+func TestArbitrageOptions(t *testing.T) {
+	markets := []Market{{Name: "universe"}, {Name: "jita"}, {Name: "amarr"}, {Name: "hubs", Markets: []string{"jita", "amarr"}}}
+	assert.NoError(t, CheckArbitrageOptions(DefaultArbitrageOptions, markets))
+	assert.NoError(t, CheckArbitrageOptions(ArbitrageOptions{Markets: []string{"jita", "amarr"}}, markets))
+	assert.Error(t, CheckArbitrageOptions(ArbitrageOptions{Markets: []string{"hek"}}, markets))
+	assert.Error(t, CheckArbitrageOptions(ArbitrageOptions{MinMargin: -1}, markets))
+	assert.Error(t, CheckArbitrageOptions(ArbitrageOptions{AccountingSkill: 6}, markets))
+
+	assert.Equal(t, []string{"jita", "amarr"}, NormalizeArbitrageOptions(DefaultArbitrageOptions, markets).Markets)
+	assert.Equal(t, []string{"amarr"}, NormalizeArbitrageOptions(ArbitrageOptions{Markets: []string{"amarr"}}, markets).Markets)
+}
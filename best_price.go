@@ -0,0 +1,138 @@
+package evepraisal
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// BestPriceOptions turn on pricing every item against every market hub. The hubs can be limited to those within a
+// number of jumps of a system.
+type BestPriceOptions struct {
+	OriginSystemID int64 `json:"origin_system_id,omitempty"`
+	// MaxJumps is how far from the origin a hub can be. 0 means that every hub is used.
+	MaxJumps int64 `json:"max_jumps,omitempty"`
+}
+
+// Validate returns an error if the options can't be used
+func (options BestPriceOptions) Validate() error {
+	if options.MaxJumps < 0 {
+		return fmt.Errorf("max_jumps can't be negative")
+	}
+	if options.MaxJumps > 0 && options.OriginSystemID <= 0 {
+		return fmt.Errorf("origin_system_id is needed to limit the number of jumps")
+	}
+	return nil
+}
+
+// ItemBestPrice is the hub with the best sell price and the hub with the best buy price for an item. Prices are per
+// unit.
+type ItemBestPrice struct {
+	SellMarket string  `json:"sell_market"`
+	SellPrice  float64 `json:"sell_price"`
+	BuyMarket  string  `json:"buy_market"`
+	BuyPrice   float64 `json:"buy_price"`
+}
+
+// HubTotal is the part of a best price appraisal that is sold in a single hub
+type HubTotal struct {
+	MarketName string  `json:"market_name"`
+	SellItems  int     `json:"sell_items"`
+	Sell       float64 `json:"sell"`
+	BuyItems   int     `json:"buy_items"`
+	Buy        float64 `json:"buy"`
+}
+
+// BestPriceMarkets returns the hubs that a best price appraisal with the given options uses. The universe market and
+// composite markets aren't hubs, since their orders are already in other markets. When the number of jumps is limited,
+// hubs that aren't tied to solar systems are skipped.
+func (app *App) BestPriceMarkets(options BestPriceOptions) []Market {
+	markets := make([]Market, 0, len(app.Markets))
+	for _, market := range app.Markets {
+		if market.IsUniverse() || market.IsComposite() {
+			continue
+		}
+		if options.MaxJumps > 0 && !app.withinJumps(market, options.OriginSystemID, options.MaxJumps) {
+			continue
+		}
+		markets = append(markets, market)
+	}
+	return markets
+}
+
+func (app *App) withinJumps(market Market, origin int64, maxJumps int64) bool {
+	if app.Jumps == nil {
+		log.Println("WARN: jumps can't be limited without a route source")
+		return false
+	}
+	for _, systemID := range market.SystemIDs {
+		jumps, ok := app.Jumps.Jumps(origin, systemID)
+		if ok && jumps <= maxJumps {
+			return true
+		}
+	}
+	return false
+}
+
+// BestPriceForItem finds the hubs with the highest sell and buy prices for an item, using the given pricing policy.
+// Only prices that come from a hub's own orders are used. Returns nil if no hub has orders for the item.
+func (app *App) BestPriceForItem(markets []Market, item AppraisalItem, policy PricingPolicy) *ItemBestPrice {
+	var best *ItemBestPrice
+	for _, market := range markets {
+		prices, ok := app.PriceDB.GetPrice(market.Name, item.TypeID)
+		if !ok || prices.Strategy != "orders" {
+			continue
+		}
+		if best == nil {
+			best = &ItemBestPrice{}
+		}
+		if sell := policy.Sell(prices); sell > best.SellPrice {
+			best.SellMarket = market.Name
+			best.SellPrice = sell
+		}
+		if buy := policy.Buy(prices); buy > best.BuyPrice {
+			best.BuyMarket = market.Name
+			best.BuyPrice = buy
+		}
+	}
+	return best
+}
+
+// HubTotals sums the items of a best price appraisal by the hub that they're best sold in, largest sell total first
+func HubTotals(items []AppraisalItem) []HubTotal {
+	byMarket := make(map[string]*HubTotal)
+	hub := func(name string) *HubTotal {
+		if byMarket[name] == nil {
+			byMarket[name] = &HubTotal{MarketName: name}
+		}
+		return byMarket[name]
+	}
+
+	for _, item := range items {
+		if item.BestPrice == nil {
+			continue
+		}
+		if item.BestPrice.SellMarket != "" {
+			total := hub(item.BestPrice.SellMarket)
+			total.SellItems++
+			total.Sell += item.BestPrice.SellPrice * float64(item.Quantity)
+		}
+		if item.BestPrice.BuyMarket != "" {
+			total := hub(item.BestPrice.BuyMarket)
+			total.BuyItems++
+			total.Buy += item.BestPrice.BuyPrice * float64(item.Quantity)
+		}
+	}
+
+	totals := make([]HubTotal, 0, len(byMarket))
+	for _, total := range byMarket {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Sell != totals[j].Sell {
+			return totals[i].Sell > totals[j].Sell
+		}
+		return totals[i].MarketName < totals[j].MarketName
+	})
+	return totals
+}
@@ -0,0 +1,85 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testJumps map[int64]int64
+
+func (jumps testJumps) Jumps(origin int64, destination int64) (int64, bool) {
+	j, ok := jumps[destination]
+	return j, ok
+}
+
+func TestBestPriceForItem(t *testing.T) {
+	app := &App{
+		Markets: NormalizeMarkets([]Market{
+			{Name: "jita", SystemIDs: []int64{30000142}},
+			{Name: "amarr", SystemIDs: []int64{30002187}},
+			{Name: "hek", StationIDs: []int64{60005686}},
+			{Name: "empire", Markets: []string{"jita", "amarr"}},
+		}),
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+			"jita": {
+				34: {Strategy: "orders", Sell: PriceStats{Min: 5}, Buy: PriceStats{Max: 4.5}},
+				35: {Strategy: "orders", Sell: PriceStats{Min: 10}, Buy: PriceStats{Max: 9}},
+			},
+			"amarr": {
+				34: {Strategy: "orders", Sell: PriceStats{Min: 6}, Buy: PriceStats{Max: 4}},
+				// Prices borrowed from other markets aren't the hub's own
+				35: {Strategy: "orders_universe", Sell: PriceStats{Min: 100}, Buy: PriceStats{Max: 90}},
+			},
+			"hek": {
+				35: {Strategy: "orders", Sell: PriceStats{Min: 11}, Buy: PriceStats{Max: 8}},
+			},
+			// A composite market is never a hub of its own
+			"empire": {
+				34: {Strategy: "orders", Sell: PriceStats{Min: 60}, Buy: PriceStats{Max: 50}},
+			},
+			UniverseMarketName: {
+				34: {Sell: PriceStats{Min: 50}, Buy: PriceStats{Max: 40}},
+			},
+		}},
+		Jumps: testJumps{30000142: 9, 30002187: 3},
+	}
+	policy, _ := GetPricingPolicy(DefaultPricingPolicy)
+
+	hubs := app.BestPriceMarkets(BestPriceOptions{})
+	assert.Len(t, hubs, 3)
+
+	best := app.BestPriceForItem(hubs, AppraisalItem{TypeID: 34}, policy)
+	assert.Equal(t, &ItemBestPrice{SellMarket: "amarr", SellPrice: 6, BuyMarket: "jita", BuyPrice: 4.5}, best)
+
+	best = app.BestPriceForItem(hubs, AppraisalItem{TypeID: 35}, policy)
+	assert.Equal(t, &ItemBestPrice{SellMarket: "hek", SellPrice: 11, BuyMarket: "jita", BuyPrice: 9}, best)
+
+	assert.Nil(t, app.BestPriceForItem(hubs, AppraisalItem{TypeID: 36}, policy))
+
+	// Only amarr is close enough and hek has no system to measure from
+	hubs = app.BestPriceMarkets(BestPriceOptions{OriginSystemID: 30002188, MaxJumps: 5})
+	assert.Len(t, hubs, 1)
+	assert.Equal(t, "amarr", hubs[0].Name)
+	best = app.BestPriceForItem(hubs, AppraisalItem{TypeID: 34}, policy)
+	assert.Equal(t, &ItemBestPrice{SellMarket: "amarr", SellPrice: 6, BuyMarket: "amarr", BuyPrice: 4}, best)
+
+	assert.Error(t, BestPriceOptions{MaxJumps: 5}.Validate())
+	assert.Error(t, BestPriceOptions{MaxJumps: -1}.Validate())
+}
+
+func TestHubTotals(t *testing.T) {
+	items := []AppraisalItem{
+		{Quantity: 10, BestPrice: &ItemBestPrice{SellMarket: "amarr", SellPrice: 6, BuyMarket: "jita", BuyPrice: 4.5}},
+		{Quantity: 2, BestPrice: &ItemBestPrice{SellMarket: "jita", SellPrice: 10, BuyMarket: "jita", BuyPrice: 9}},
+		{Quantity: 1, BestPrice: &ItemBestPrice{SellMarket: "amarr", SellPrice: 100}},
+		{Quantity: 1},
+	}
+	assert.Equal(t, []HubTotal{
+		{MarketName: "amarr", SellItems: 2, Sell: 160},
+		{MarketName: "jita", SellItems: 1, Sell: 20, BuyItems: 2, Buy: 63},
+	}, HubTotals(items))
+
+	assert.Equal(t, 60.0, items[0].SellTotal())
+	assert.Equal(t, 45.0, items[0].BuyTotal())
+}
@@ -0,0 +1,93 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// BuybackDB stores buyback programs
+type BuybackDB struct {
+	db *bolt.DB
+}
+
+// NewBuybackDB returns a new BuybackDB instance
+func NewBuybackDB(filename string) (evepraisal.BuybackDB, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err = tx.CreateBucketIfNotExists([]byte("buyback_programs"))
+		if err != nil {
+			return fmt.Errorf("create buyback_programs bucket: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BuybackDB{db: db}, nil
+}
+
+// GetBuybackProgram returns the buyback program with the given name
+func (db *BuybackDB) GetBuybackProgram(name string) (evepraisal.BuybackProgram, error) {
+	var program evepraisal.BuybackProgram
+	err := db.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket([]byte("buyback_programs")).Get([]byte(name))
+		if buf == nil {
+			return evepraisal.ErrBuybackProgramNotFound
+		}
+		return json.Unmarshal(buf, &program)
+	})
+	return program, err
+}
+
+// ListBuybackPrograms returns every buyback program, ordered by name
+func (db *BuybackDB) ListBuybackPrograms() ([]evepraisal.BuybackProgram, error) {
+	programs := make([]evepraisal.BuybackProgram, 0)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("buyback_programs")).ForEach(func(k, v []byte) error {
+			var program evepraisal.BuybackProgram
+			err := json.Unmarshal(v, &program)
+			if err != nil {
+				return err
+			}
+			programs = append(programs, program)
+			return nil
+		})
+	})
+	return programs, err
+}
+
+// PutBuybackProgram creates or replaces the buyback program with the program's name
+func (db *BuybackDB) PutBuybackProgram(program evepraisal.BuybackProgram) error {
+	buf, err := json.Marshal(program)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("buyback_programs")).Put([]byte(program.Name), buf)
+	})
+}
+
+// DeleteBuybackProgram deletes the buyback program with the given name
+func (db *BuybackDB) DeleteBuybackProgram(name string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("buyback_programs"))
+		if b.Get([]byte(name)) == nil {
+			return evepraisal.ErrBuybackProgramNotFound
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// Close closes the database
+func (db *BuybackDB) Close() error {
+	return db.db.Close()
+}
@@ -1,9 +1,14 @@
 package bolt
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -11,13 +16,35 @@ import (
 	"github.com/golang/snappy"
 )
 
+// PriceHistoryTier defines how densely price snapshots are kept. Snapshots up to MaxAge old are kept at most once
+// per Resolution. A Resolution of zero keeps every snapshot.
+type PriceHistoryTier struct {
+	MaxAge     time.Duration `mapstructure:"max_age"`
+	Resolution time.Duration `mapstructure:"resolution"`
+}
+
+// DefaultPriceHistoryTiers keeps hourly snapshots for a week and daily snapshots for a year
+var DefaultPriceHistoryTiers = []PriceHistoryTier{
+	{MaxAge: 7 * 24 * time.Hour, Resolution: time.Hour},
+	{MaxAge: 365 * 24 * time.Hour, Resolution: 24 * time.Hour},
+}
+
+var priceHistoryCompactInterval = time.Hour
+
+// priceHistoryCompactBatch is about how many snapshots are looked at in each transaction when compacting the history
+var priceHistoryCompactBatch = 10000
+
 // PriceDB stores the market prices for items
 type PriceDB struct {
-	db *bolt.DB
+	db           *bolt.DB
+	historyTiers []PriceHistoryTier
+
+	wg   *sync.WaitGroup
+	stop chan bool
 }
 
 // NewPriceDB returns a new PriceDB instance
-func NewPriceDB(filename string) (evepraisal.PriceDB, error) {
+func NewPriceDB(filename string, historyTiers []PriceHistoryTier) (evepraisal.PriceDB, error) {
 	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
 		return nil, err
@@ -28,10 +55,37 @@ func NewPriceDB(filename string) (evepraisal.PriceDB, error) {
 		if err != nil {
 			return fmt.Errorf("create prices bucket: %s", err)
 		}
+		_, err = tx.CreateBucketIfNotExists([]byte("price_history"))
+		if err != nil {
+			return fmt.Errorf("create price_history bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte("order_books"))
+		if err != nil {
+			return fmt.Errorf("create order_books bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte("market_history"))
+		if err != nil {
+			return fmt.Errorf("create market_history bucket: %s", err)
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	historyTiers = append([]PriceHistoryTier{}, historyTiers...)
+	sort.Slice(historyTiers, func(i, j int) bool { return historyTiers[i].MaxAge < historyTiers[j].MaxAge })
 
-	return &PriceDB{db: db}, err
+	priceDB := &PriceDB{
+		db:           db,
+		historyTiers: historyTiers,
+		wg:           &sync.WaitGroup{},
+		stop:         make(chan bool),
+	}
+
+	priceDB.wg.Add(1)
+	go priceDB.startHistoryCompactor()
+	return priceDB, nil
 }
 
 // GetPrice returns the price for a type given a market name and typeID
@@ -62,10 +116,45 @@ func (db *PriceDB) GetPrice(market string, typeID int64) (evepraisal.Prices, boo
 
 }
 
-// UpdatePrices updates the price for the given typeID in the given market
+// GetPrices returns the prices of every given type that has prices in the given market. Every price is read in a
+// single transaction.
+func (db *PriceDB) GetPrices(market string, typeIDs []int64) (map[int64]evepraisal.Prices, error) {
+	prices := make(map[int64]evepraisal.Prices, len(typeIDs))
+	err := db.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("prices"))
+		for _, typeID := range typeIDs {
+			buf := b.Get([]byte(fmt.Sprintf("%s|%d", market, typeID)))
+			if buf == nil {
+				continue
+			}
+
+			buf, err := snappy.Decode(nil, buf)
+			if err != nil {
+				return fmt.Errorf("Error when decoding: %s", err)
+			}
+
+			var p evepraisal.Prices
+			err = json.Unmarshal(buf, &p)
+			if err != nil {
+				return err
+			}
+			prices[typeID] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// UpdatePrices updates the price for the given typeID in the given market. A snapshot of each price is also added
+// to the price history.
 func (db *PriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
+	now := time.Now()
 	return db.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("prices"))
+		historyBucket := tx.Bucket([]byte("price_history"))
 
 		for _, item := range items {
 			priceBytes, err := json.Marshal(item.Prices)
@@ -73,7 +162,135 @@ func (db *PriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
 				return err
 			}
 
-			err = b.Put([]byte(fmt.Sprintf("%s|%d", item.Market, item.TypeID)), snappy.Encode(nil, priceBytes))
+			encoded := snappy.Encode(nil, priceBytes)
+			err = b.Put([]byte(fmt.Sprintf("%s|%d", item.Market, item.TypeID)), encoded)
+			if err != nil {
+				return err
+			}
+
+			updated := item.Prices.Updated
+			if updated.IsZero() {
+				updated = now
+			}
+			err = historyBucket.Put(priceHistoryKey(item.Market, item.TypeID, updated), encoded)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPriceHistory returns the price snapshots for a type in the given market between from and to, oldest first
+func (db *PriceDB) GetPriceHistory(market string, typeID int64, from time.Time, to time.Time) ([]evepraisal.Prices, error) {
+	history := make([]evepraisal.Prices, 0)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("price_history")).Cursor()
+		end := priceHistoryKey(market, typeID, to)
+		for key, val := c.Seek(priceHistoryKey(market, typeID, from)); key != nil && bytes.Compare(key, end) <= 0; key, val = c.Next() {
+			buf, err := snappy.Decode(nil, val)
+			if err != nil {
+				return fmt.Errorf("Error when decoding: %s", err)
+			}
+
+			var prices evepraisal.Prices
+			err = json.Unmarshal(buf, &prices)
+			if err != nil {
+				return err
+			}
+			history = append(history, prices)
+		}
+		return nil
+	})
+	return history, err
+}
+
+// GetOrderBook returns the order book for a type given a market name and typeID
+func (db *PriceDB) GetOrderBook(market string, typeID int64) (evepraisal.OrderBook, bool) {
+	book := &evepraisal.OrderBook{}
+
+	var err error
+	err = db.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("order_books"))
+		buf := b.Get([]byte(fmt.Sprintf("%s|%d", market, typeID)))
+		if buf == nil {
+			return errors.New("Order book not found")
+		}
+
+		buf, err = snappy.Decode(nil, buf)
+		if err != nil {
+			return fmt.Errorf("Error when decoding: %s", err)
+		}
+
+		return json.Unmarshal(buf, book)
+	})
+
+	if err != nil {
+		return *book, false
+	}
+
+	return *book, true
+}
+
+// UpdateOrderBooks replaces the order books for the given typeIDs in the given markets
+func (db *PriceDB) UpdateOrderBooks(items []evepraisal.MarketItemOrderBook) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("order_books"))
+
+		for _, item := range items {
+			bookBytes, err := json.Marshal(item.OrderBook)
+			if err != nil {
+				return err
+			}
+
+			err = b.Put([]byte(fmt.Sprintf("%s|%d", item.Market, item.TypeID)), snappy.Encode(nil, bookBytes))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMarketHistory returns the daily market history for a type in a region
+func (db *PriceDB) GetMarketHistory(regionID int64, typeID int64) (evepraisal.MarketHistory, bool) {
+	history := &evepraisal.MarketHistory{}
+
+	var err error
+	err = db.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("market_history"))
+		buf := b.Get([]byte(fmt.Sprintf("%d|%d", regionID, typeID)))
+		if buf == nil {
+			return errors.New("Market history not found")
+		}
+
+		buf, err = snappy.Decode(nil, buf)
+		if err != nil {
+			return fmt.Errorf("Error when decoding: %s", err)
+		}
+
+		return json.Unmarshal(buf, history)
+	})
+
+	if err != nil {
+		return *history, false
+	}
+
+	return *history, true
+}
+
+// UpdateMarketHistory replaces the market history for the given typeIDs in the given regions
+func (db *PriceDB) UpdateMarketHistory(items []evepraisal.RegionItemMarketHistory) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("market_history"))
+
+		for _, item := range items {
+			historyBytes, err := json.Marshal(item.History)
+			if err != nil {
+				return err
+			}
+
+			err = b.Put([]byte(fmt.Sprintf("%d|%d", item.RegionID, item.TypeID)), snappy.Encode(nil, historyBytes))
 			if err != nil {
 				return err
 			}
@@ -84,5 +301,143 @@ func (db *PriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
 
 // Close cleans up the PriceDB
 func (db *PriceDB) Close() error {
+	close(db.stop)
+	db.wg.Wait()
 	return db.db.Close()
 }
+
+// priceHistoryKey is "market|typeID|" followed by a big endian unix timestamp so keys sort by time for each type
+func priceHistoryKey(market string, typeID int64, t time.Time) []byte {
+	key := []byte(fmt.Sprintf("%s|%d|", market, typeID))
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(t.Unix()))
+	return append(key, ts...)
+}
+
+// historyTierFor returns the index of the tier that a snapshot of the given age falls into or -1 if it's too old
+func (db *PriceDB) historyTierFor(age time.Duration) int {
+	for i, tier := range db.historyTiers {
+		if age <= tier.MaxAge {
+			return i
+		}
+	}
+	return -1
+}
+
+// compactHistory removes snapshots that are too old or too dense for their history tier. The history is compacted in
+// batches of whole market|type prefixes, each in its own transaction, so neither the keys that are removed nor the
+// write lock are held for the whole bucket.
+func (db *PriceDB) compactHistory(now time.Time) (int, error) {
+	var (
+		removed int
+		next    []byte
+	)
+	for {
+		select {
+		case <-db.stop:
+			return removed, nil
+		default:
+		}
+
+		err := db.db.Update(func(tx *bolt.Tx) error {
+			var (
+				n   int
+				err error
+			)
+			n, next, err = db.compactHistoryBatch(tx, now, next)
+			removed += n
+			return err
+		})
+		if err != nil {
+			return removed, err
+		}
+		if next == nil {
+			return removed, nil
+		}
+	}
+}
+
+// compactHistoryBatch compacts the market|type prefixes from start (or from the first key when start is nil) until at
+// least priceHistoryCompactBatch snapshots have been looked at. It returns the number of snapshots removed and the key
+// that the next batch starts at, which is nil once the end of the history is reached.
+func (db *PriceDB) compactHistoryBatch(tx *bolt.Tx, now time.Time, start []byte) (int, []byte, error) {
+	b := tx.Bucket([]byte("price_history"))
+	c := b.Cursor()
+
+	var key []byte
+	if start == nil {
+		key, _ = c.First()
+	} else {
+		key, _ = c.Seek(start)
+	}
+
+	var (
+		deletable  [][]byte
+		next       []byte
+		scanned    int
+		lastPrefix []byte
+		lastTier   = -1
+		lastSlot   int64
+	)
+	for ; key != nil; key, _ = c.Next() {
+		if len(key) <= 8 {
+			continue
+		}
+		prefix := key[:len(key)-8]
+		if !bytes.Equal(prefix, lastPrefix) {
+			if scanned >= priceHistoryCompactBatch {
+				next = append([]byte{}, key...)
+				break
+			}
+			lastPrefix = append(lastPrefix[:0], prefix...)
+			lastTier = -1
+		}
+		scanned++
+
+		t := time.Unix(int64(binary.BigEndian.Uint64(key[len(key)-8:])), 0)
+		tier := db.historyTierFor(now.Sub(t))
+		if tier == -1 {
+			deletable = append(deletable, append([]byte{}, key...))
+			continue
+		}
+
+		resolution := db.historyTiers[tier].Resolution
+		if resolution <= 0 {
+			lastTier = -1
+			continue
+		}
+
+		slot := t.Unix() / int64(resolution.Seconds())
+		if tier == lastTier && slot == lastSlot {
+			deletable = append(deletable, append([]byte{}, key...))
+			continue
+		}
+		lastTier = tier
+		lastSlot = slot
+	}
+
+	for _, key := range deletable {
+		err := b.Delete(key)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(deletable), next, nil
+}
+
+func (db *PriceDB) startHistoryCompactor() {
+	defer db.wg.Done()
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-time.After(priceHistoryCompactInterval):
+		}
+
+		removed, err := db.compactHistory(time.Now())
+		if err != nil {
+			log.Printf("ERROR: Problem compacting price history: %s", err)
+		}
+		log.Printf("Done compacting price history, removed %d snapshots", removed)
+	}
+}
@@ -0,0 +1,156 @@
+package bolt
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPriceDB(t *testing.T, tiers []PriceHistoryTier) *PriceDB {
+	db, err := NewPriceDB(filepath.Join(t.TempDir(), "prices"), tiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	return db.(*PriceDB)
+}
+
+func testHistoryPrices(market string, typeID int64, price float64, updated time.Time) evepraisal.MarketItemPrices {
+	prices := evepraisal.Prices{Updated: updated}.Set(price)
+	return evepraisal.MarketItemPrices{Market: market, TypeID: typeID, Prices: prices}
+}
+
+func TestGetPriceHistory(t *testing.T) {
+	db := newTestPriceDB(t, DefaultPriceHistoryTiers)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		updated := start.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, db.UpdatePrices([]evepraisal.MarketItemPrices{
+			testHistoryPrices("jita", 34, float64(i+1), updated),
+			testHistoryPrices("jita", 35, 100, updated),
+			testHistoryPrices("amarr", 34, 100, updated),
+		}))
+	}
+
+	prices, ok := db.GetPrice("jita", 34)
+	assert.True(t, ok)
+	assert.Equal(t, 5.0, prices.Sell.Min)
+
+	history, err := db.GetPriceHistory("jita", 34, start.Add(time.Hour), start.Add(3*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, history, 3) {
+		// Oldest first, and only the asked for type and market
+		assert.Equal(t, 2.0, history[0].Sell.Min)
+		assert.Equal(t, 3.0, history[1].Sell.Min)
+		assert.Equal(t, 4.0, history[2].Sell.Min)
+	}
+
+	history, err = db.GetPriceHistory("jita", 36, start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestCompactHistory(t *testing.T) {
+	defer func(batch int) { priceHistoryCompactBatch = batch }(priceHistoryCompactBatch)
+	// Small batches make the compaction cross transactions in the middle of the history
+	priceHistoryCompactBatch = 3
+
+	db := newTestPriceDB(t, []PriceHistoryTier{
+		{MaxAge: 24 * time.Hour, Resolution: 0},
+		{MaxAge: 7 * 24 * time.Hour, Resolution: 24 * time.Hour},
+	})
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+	var items []evepraisal.MarketItemPrices
+	for _, typeID := range []int64{34, 35, 36} {
+		items = append(items,
+			// Every snapshot of the last day is kept
+			testHistoryPrices("jita", typeID, 1, now.Add(-1*time.Hour)),
+			testHistoryPrices("jita", typeID, 2, now.Add(-2*time.Hour)),
+			// One snapshot a day is kept for the last week
+			testHistoryPrices("jita", typeID, 3, time.Date(2020, 1, 7, 1, 0, 0, 0, time.UTC)),
+			testHistoryPrices("jita", typeID, 4, time.Date(2020, 1, 7, 5, 0, 0, 0, time.UTC)),
+			testHistoryPrices("jita", typeID, 5, time.Date(2020, 1, 7, 9, 0, 0, 0, time.UTC)),
+			testHistoryPrices("jita", typeID, 6, time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)),
+			// Anything older is removed
+			testHistoryPrices("jita", typeID, 7, time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)),
+		)
+	}
+	assert.NoError(t, db.UpdatePrices(items))
+
+	removed, err := db.compactHistory(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, removed)
+
+	for _, typeID := range []int64{34, 35, 36} {
+		history, err := db.GetPriceHistory("jita", typeID, now.AddDate(-1, 0, 0), now)
+		assert.NoError(t, err)
+		kept := make([]float64, len(history))
+		for i, prices := range history {
+			kept[i] = prices.Sell.Min
+		}
+		assert.Equal(t, []float64{6, 3, 2, 1}, kept, typeID)
+	}
+
+	// Compacting again doesn't remove anything else
+	removed, err = db.compactHistory(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
+
+type testTypeDB struct {
+	typedb.TypeDB
+	types map[int64]typedb.EveType
+}
+
+func (db testTypeDB) GetTypeByID(typeID int64) (typedb.EveType, bool) {
+	t, ok := db.types[typeID]
+	return t, ok
+}
+
+func benchmarkPopulateItems(b *testing.B, cached bool) {
+	db, err := NewPriceDB(filepath.Join(b.TempDir(), "prices"), DefaultPriceHistoryTiers)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	types := make(map[int64]typedb.EveType)
+	items := make([]evepraisal.AppraisalItem, 0)
+	prices := make([]evepraisal.MarketItemPrices, 0)
+	for typeID := int64(1); typeID <= 200; typeID++ {
+		types[typeID] = typedb.EveType{ID: typeID, Name: fmt.Sprintf("Type %d", typeID), Volume: 1}
+		prices = append(prices,
+			evepraisal.MarketItemPrices{Market: "jita", TypeID: typeID, Prices: evepraisal.Prices{Strategy: "orders"}.Set(float64(typeID))},
+			evepraisal.MarketItemPrices{Market: evepraisal.UniverseMarketName, TypeID: typeID, Prices: evepraisal.Prices{}.Set(float64(typeID))},
+		)
+		items = append(items, evepraisal.AppraisalItem{TypeID: typeID, Quantity: 10})
+	}
+	err = db.UpdatePrices(prices)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	app := &evepraisal.App{
+		TypeDB:  testTypeDB{types: types},
+		PriceDB: db,
+	}
+	if cached {
+		app.PriceDB = evepraisal.NewPriceCache(db, 0)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		appraisal := &evepraisal.Appraisal{MarketName: "jita", Items: append([]evepraisal.AppraisalItem(nil), items...)}
+		app.PopulateItems(appraisal)
+	}
+}
+
+func BenchmarkPopulateItems(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) { benchmarkPopulateItems(b, false) })
+	b.Run("cached", func(b *testing.B) { benchmarkPopulateItems(b, true) })
+}
@@ -0,0 +1,86 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/golang/snappy"
+)
+
+// PriceSheetDB stores administrator price sheets
+type PriceSheetDB struct {
+	db *bolt.DB
+}
+
+// NewPriceSheetDB returns a new PriceSheetDB instance
+func NewPriceSheetDB(filename string) (evepraisal.PriceSheetDB, error) {
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err = tx.CreateBucketIfNotExists([]byte("price_sheets"))
+		if err != nil {
+			return fmt.Errorf("create price_sheets bucket: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PriceSheetDB{db: db}, nil
+}
+
+// ListPriceSheets returns every price sheet, ordered by name
+func (db *PriceSheetDB) ListPriceSheets() ([]evepraisal.PriceSheet, error) {
+	sheets := make([]evepraisal.PriceSheet, 0)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("price_sheets")).ForEach(func(k, v []byte) error {
+			buf, err := snappy.Decode(nil, v)
+			if err != nil {
+				return fmt.Errorf("Error when decoding: %s", err)
+			}
+
+			var sheet evepraisal.PriceSheet
+			err = json.Unmarshal(buf, &sheet)
+			if err != nil {
+				return err
+			}
+			sheets = append(sheets, sheet)
+			return nil
+		})
+	})
+	return sheets, err
+}
+
+// PutPriceSheet creates or replaces the price sheet with the sheet's name
+func (db *PriceSheetDB) PutPriceSheet(sheet evepraisal.PriceSheet) error {
+	buf, err := json.Marshal(sheet)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("price_sheets")).Put([]byte(sheet.Name), snappy.Encode(nil, buf))
+	})
+}
+
+// DeletePriceSheet deletes the price sheet with the given name
+func (db *PriceSheetDB) DeletePriceSheet(name string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("price_sheets"))
+		if b.Get([]byte(name)) == nil {
+			return evepraisal.ErrPriceSheetNotFound
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// Close closes the database
+func (db *PriceSheetDB) Close() error {
+	return db.db.Close()
+}
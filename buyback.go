@@ -0,0 +1,155 @@
+package evepraisal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// Sides of the market that a buyback rule can pay from
+const (
+	BuybackSideBuy  = "buy"
+	BuybackSideSell = "sell"
+)
+
+var (
+	// ErrBuybackProgramNotFound is returned whenever a buyback program by a given name can't be found
+	ErrBuybackProgramNotFound = errors.New("Buyback program not found")
+
+	// managedNameRe matches the names that can be given to things that are managed through the management server
+	managedNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+)
+
+// BuybackProgram is a named set of rules that decide what a corporation pays for each item. The first rule that
+// matches an item is used. Items that no rule matches aren't bought.
+type BuybackProgram struct {
+	Name  string        `json:"name"`
+	Rules []BuybackRule `json:"rules"`
+}
+
+// BuybackRule pays a percentage of a market price, or a fixed price, for the items it matches. A rule matches an item
+// if every ID that is set matches; a rule without any IDs matches everything.
+type BuybackRule struct {
+	// Label is shown next to the items that the rule priced
+	Label         string `json:"label,omitempty"`
+	TypeID        int64  `json:"type_id,omitempty"`
+	GroupID       int64  `json:"group_id,omitempty"`
+	MarketGroupID int64  `json:"market_group_id,omitempty"`
+	CategoryID    int64  `json:"category_id,omitempty"`
+	// Market is where the price comes from. The appraisal's market is used when this is empty.
+	Market string `json:"market,omitempty"`
+	// Side is either "buy" (the highest buy order) or "sell" (the lowest sell order)
+	Side       string  `json:"side"`
+	Percentage float64 `json:"percentage"`
+	// FixedPrice is paid per unit instead of a market price when it is set
+	FixedPrice float64 `json:"fixed_price,omitempty"`
+}
+
+// Matches returns true if the rule applies to the given type
+func (rule BuybackRule) Matches(t typedb.EveType) bool {
+	if rule.TypeID != 0 && rule.TypeID != t.ID {
+		return false
+	}
+	if rule.GroupID != 0 && rule.GroupID != t.GroupID {
+		return false
+	}
+	if rule.MarketGroupID != 0 && rule.MarketGroupID != t.MarketGroupID {
+		return false
+	}
+	if rule.CategoryID != 0 && rule.CategoryID != t.CategoryID {
+		return false
+	}
+	return true
+}
+
+// Description is the label of the rule or, if there isn't one, a summary of what it pays
+func (rule BuybackRule) Description() string {
+	if rule.Label != "" {
+		return rule.Label
+	}
+	if rule.FixedPrice > 0 {
+		return fmt.Sprintf("%.2f ISK each", rule.FixedPrice)
+	}
+	market := rule.Market
+	if market == "" {
+		market = "market"
+	}
+	return fmt.Sprintf("%g%% of %s %s", rule.Percentage, market, rule.Side)
+}
+
+// Validate returns an error if the program can't be used
+func (program BuybackProgram) Validate() error {
+	if !managedNameRe.MatchString(program.Name) {
+		return fmt.Errorf("name must be 1-64 letters, numbers, dashes or underscores")
+	}
+	for i, rule := range program.Rules {
+		if rule.FixedPrice < 0 {
+			return fmt.Errorf("rule %d: fixed_price can't be negative", i)
+		}
+		if rule.FixedPrice > 0 {
+			continue
+		}
+		if rule.Side != BuybackSideBuy && rule.Side != BuybackSideSell {
+			return fmt.Errorf("rule %d: side must be %q or %q", i, BuybackSideBuy, BuybackSideSell)
+		}
+		if rule.Percentage < 0 || rule.Percentage > 1000 {
+			return fmt.Errorf("rule %d: percentage must be between 0 and 1000", i)
+		}
+	}
+	return nil
+}
+
+// ItemBuyback is what a buyback program pays for an item. Rule is nil if the program doesn't buy the item.
+type ItemBuyback struct {
+	Rule  *BuybackRule `json:"rule"`
+	Price float64      `json:"price"`
+	Total float64      `json:"total"`
+}
+
+// RuleDescription describes the rule that priced the item
+func (b ItemBuyback) RuleDescription() string {
+	if b.Rule == nil {
+		return "Not bought"
+	}
+	return b.Rule.Description()
+}
+
+// BuybackForItem prices an item with the first rule of the program that matches it
+func (app *App) BuybackForItem(market string, item AppraisalItem, program BuybackProgram) *ItemBuyback {
+	t, ok := app.TypeDB.GetTypeByID(item.TypeID)
+	if !ok {
+		return &ItemBuyback{}
+	}
+
+	for i := range program.Rules {
+		rule := program.Rules[i]
+		if !rule.Matches(t) {
+			continue
+		}
+
+		buyback := &ItemBuyback{Rule: &rule}
+		if rule.FixedPrice > 0 {
+			buyback.Price = rule.FixedPrice
+		} else {
+			ruleMarket := rule.Market
+			if ruleMarket == "" {
+				ruleMarket = market
+			}
+			prices, err := app.PricesForItem(ruleMarket, item)
+			if err != nil {
+				return buyback
+			}
+			if rule.Side == BuybackSideSell {
+				buyback.Price = prices.Sell.Min
+			} else {
+				buyback.Price = prices.Buy.Max
+			}
+			buyback.Price *= rule.Percentage / 100
+		}
+		buyback.Total = buyback.Price * float64(item.Quantity)
+		return buyback
+	}
+	return &ItemBuyback{}
+}
@@ -0,0 +1,80 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuybackForItem(t *testing.T) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			1230:  {ID: 1230, GroupID: 462, CategoryID: CategoryAsteroid},
+			2048:  {ID: 2048, GroupID: 8, MarketGroupID: 100, CategoryID: 7},
+			21894: {ID: 21894, GroupID: 85, MarketGroupID: 994, CategoryID: 8},
+			44992: {ID: 44992, GroupID: 1875, CategoryID: 2100},
+			34:    {ID: 34, GroupID: 18, CategoryID: 4},
+		}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+			"jita": {
+				1230:  {Strategy: "orders", Buy: PriceStats{Max: 20}, Sell: PriceStats{Min: 22}},
+				2048:  {Strategy: "orders", Buy: PriceStats{Max: 1000}, Sell: PriceStats{Min: 1200}},
+				21894: {Strategy: "orders", Buy: PriceStats{Max: 300}, Sell: PriceStats{Min: 350}},
+			},
+			"amarr": {
+				2048: {Strategy: "orders", Buy: PriceStats{Max: 900}, Sell: PriceStats{Min: 1100}},
+			},
+		}},
+	}
+
+	program := BuybackProgram{
+		Name: "corp",
+		Rules: []BuybackRule{
+			{Label: "Ore", CategoryID: CategoryAsteroid, Market: "jita", Side: BuybackSideBuy, Percentage: 90},
+			{Label: "Faction ammo", MarketGroupID: 994, Side: BuybackSideBuy, Percentage: 0},
+			{TypeID: 44992, FixedPrice: 3000000},
+			{CategoryID: 7, Side: BuybackSideSell, Percentage: 80},
+			{CategoryID: 8, Side: BuybackSideBuy, Percentage: 50},
+		},
+	}
+	assert.NoError(t, program.Validate())
+
+	buyback := app.BuybackForItem("jita", AppraisalItem{TypeID: 1230, Quantity: 100}, program)
+	assert.Equal(t, "Ore", buyback.RuleDescription())
+	assert.Equal(t, 18.0, buyback.Price)
+	assert.Equal(t, 1800.0, buyback.Total)
+
+	// The rule's market is used when it has one, otherwise the appraisal's
+	buyback = app.BuybackForItem("amarr", AppraisalItem{TypeID: 1230, Quantity: 100}, program)
+	assert.Equal(t, 1800.0, buyback.Total)
+	buyback = app.BuybackForItem("amarr", AppraisalItem{TypeID: 2048, Quantity: 2}, program)
+	assert.Equal(t, "80% of market sell", buyback.RuleDescription())
+	assert.Equal(t, 880.0, buyback.Price)
+
+	// The first matching rule wins
+	buyback = app.BuybackForItem("jita", AppraisalItem{TypeID: 21894, Quantity: 1000}, program)
+	assert.Equal(t, "Faction ammo", buyback.RuleDescription())
+	assert.Equal(t, 0.0, buyback.Total)
+
+	buyback = app.BuybackForItem("jita", AppraisalItem{TypeID: 44992, Quantity: 2}, program)
+	assert.Equal(t, "3000000.00 ISK each", buyback.RuleDescription())
+	assert.Equal(t, 6000000.0, buyback.Total)
+
+	// Items without a rule aren't bought
+	item := AppraisalItem{TypeID: 34, Quantity: 1000, Prices: Prices{}.Set(5)}
+	item.Buyback = app.BuybackForItem("jita", item, program)
+	assert.Nil(t, item.Buyback.Rule)
+	assert.Equal(t, "Not bought", item.Buyback.RuleDescription())
+	assert.Equal(t, 0.0, item.SellTotal())
+	assert.Equal(t, 0.0, item.BuyTotal())
+}
+
+func TestBuybackProgramValidate(t *testing.T) {
+	assert.Error(t, BuybackProgram{Name: ""}.Validate())
+	assert.Error(t, BuybackProgram{Name: "has spaces"}.Validate())
+	assert.Error(t, BuybackProgram{Name: "corp", Rules: []BuybackRule{{Side: "middle", Percentage: 90}}}.Validate())
+	assert.Error(t, BuybackProgram{Name: "corp", Rules: []BuybackRule{{Side: BuybackSideBuy, Percentage: -1}}}.Validate())
+	assert.Error(t, BuybackProgram{Name: "corp", Rules: []BuybackRule{{FixedPrice: -1}}}.Validate())
+	assert.NoError(t, BuybackProgram{Name: "corp", Rules: []BuybackRule{{FixedPrice: 10}}}.Validate())
+}
@@ -0,0 +1,148 @@
+package esi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/sethgrid/pester"
+)
+
+// CCPPriceFetcher is the price source for CCP's average prices. They are written to the "ccp" market and to any
+// other markets that it's given.
+type CCPPriceFetcher struct {
+	db      evepraisal.PriceDB
+	markets []string
+	client  *pester.Client
+	baseURL string
+	page    esiPage
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan bool
+	wg     *sync.WaitGroup
+}
+
+// NewCCPPriceFetcher returns a new CCPPriceFetcher. Nothing is fetched until it is started.
+func NewCCPPriceFetcher(ctx context.Context, markets []string, baseURL string, client *pester.Client) *CCPPriceFetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	return &CCPPriceFetcher{
+		markets: markets,
+		client:  client,
+		baseURL: baseURL,
+
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan bool),
+		wg:     &sync.WaitGroup{},
+	}
+}
+
+// Start starts fetching CCP's prices whenever they expire. Prices are written to db.
+func (p *CCPPriceFetcher) Start(db evepraisal.PriceDB) error {
+	p.db = db
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			next := p.runOnce(time.Now())
+			select {
+			case <-time.After(time.Until(next)):
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close should be called to stop the fetcher worker. Any request that is in flight is cancelled.
+func (p *CCPPriceFetcher) Close() error {
+	close(p.stop)
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// runOnce refetches CCP's prices if they have expired, saves them if they have changed and returns when it should
+// run next
+func (p *CCPPriceFetcher) runOnce(now time.Time) time.Time {
+	if p.page.expired(now) {
+		prices, changed, err := p.FetchPriceData()
+		if err != nil {
+			if p.ctx.Err() == nil {
+				log.Println("ERROR: fetching CCP price data: ", err)
+			}
+			p.page.expires = now.Add(retryInterval)
+		} else if changed {
+			p.updatePrices(prices)
+		}
+	}
+
+	next := p.page.expires
+	if next.Before(now.Add(minRefreshInterval)) {
+		return now.Add(minRefreshInterval)
+	}
+	return next
+}
+
+func (p *CCPPriceFetcher) updatePrices(prices map[int64]evepraisal.Prices) {
+	markets := []string{evepraisal.CCPMarketName}
+	for _, market := range p.markets {
+		if market != evepraisal.CCPMarketName {
+			markets = append(markets, market)
+		}
+	}
+	for _, market := range markets {
+		items := make([]evepraisal.MarketItemPrices, 0, len(prices))
+		for typeID, price := range prices {
+			items = append(items, evepraisal.MarketItemPrices{Market: market, TypeID: typeID, Prices: price})
+		}
+		err := p.db.UpdatePrices(items)
+		if err != nil {
+			log.Printf("Error when updating CCP prices: %s", err)
+		}
+	}
+}
+
+// FetchPriceData fetches CCP's pricing information for every type if it has changed since the last time it was
+// fetched. Returns true if the prices changed.
+func (p *CCPPriceFetcher) FetchPriceData() (map[int64]evepraisal.Prices, bool, error) {
+	start := time.Now()
+	url := fmt.Sprintf("%s/markets/prices/?datasource=tranquility", p.baseURL)
+	esiPrices := make([]struct {
+		TypeID        int64   `json:"type_id"`
+		AveragePrice  float64 `json:"average_price"`
+		AdjustedPrice float64 `json:"adjusted_price"`
+	}, 0)
+	changed, err := fetchURLIfChanged(p.ctx, p.client, url, &p.page, &esiPrices)
+	if err != nil || !changed {
+		return nil, false, err
+	}
+
+	allPrices := make(map[int64]evepraisal.Prices, len(esiPrices))
+	for _, p := range esiPrices {
+		priceToUse := p.AveragePrice
+		if priceToUse == 0 {
+			priceToUse = p.AdjustedPrice
+		}
+		stats := evepraisal.PriceStats{
+			Average:    p.AveragePrice,
+			Max:        priceToUse,
+			Median:     priceToUse,
+			Min:        priceToUse,
+			Percentile: p.AdjustedPrice,
+		}
+		allPrices[p.TypeID] = evepraisal.Prices{
+			All:      stats,
+			Buy:      stats,
+			Sell:     stats,
+			Updated:  start,
+			Strategy: "ccp",
+		}
+	}
+	return allPrices, true, nil
+}
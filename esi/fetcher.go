@@ -2,8 +2,6 @@ package esi
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -26,327 +24,401 @@ type MarketOrder struct {
 	Issued        string  `json:"issued"`
 	VolumeEntered int64   `json:"volumeEntered"`
 	Range         string  `json:"range"`
+	RegionID      int64   `json:"-"`
 }
 
-// SpecialRegions defines which regions we care about
-var SpecialRegions = []struct {
-	name     string
-	stations []int64
-	systems  []int64
-}{
-	{
-		// regionID: 10000002
-		name:    "jita",
-		systems: []int64{30000142},
-	}, {
-		// regionID: 10000002
-		name:    "perimeter",
-		systems: []int64{30000144},
-	}, {
-		// regionID: 10000043
-		name:     "amarr",
-		stations: []int64{60008950, 60002569, 60008494},
-		systems:  []int64{30003491},
-	}, {
-		// regionID: 10000032
-		name:     "dodixie",
-		stations: []int64{60011866, 60001867},
-		systems:  []int64{30002661},
-	}, {
-		// regionID: 10000042
-		name:     "hek",
-		stations: []int64{60005236, 60004516, 60015140, 60005686, 60011287, 60005236},
-	}, {
-		// regionID: 10000030
-		name:    "rens",
-		systems: []int64{30002510, 30002526},
-	},
-}
+// minRefreshInterval and maxRefreshInterval bound how long the fetcher sleeps between looking for expired ESI
+// responses
+var (
+	minRefreshInterval = 30 * time.Second
+	maxRefreshInterval = 30 * time.Minute
+)
 
-// PriceFetcher fetches prices and populates the given priceDB
+// PriceFetcher is the price source for market orders. Market orders are kept in memory for each region and only the
+// pages that ESI says have expired are refetched. Prices are only recalculated for types whose orders changed, so
+// OrdersFetched says how current the prices of the others are.
 type PriceFetcher struct {
-	db      evepraisal.PriceDB
-	client  *pester.Client
-	baseURL string
-
-	ctx  context.Context
-	stop chan bool
-	wg   *sync.WaitGroup
+	db          evepraisal.PriceDB
+	markets     []evepraisal.Market
+	orderFilter OrderFilter
+	client      *pester.Client
+	baseURL     string
+
+	// structureClient authenticates as the service character. Structure markets aren't fetched without it.
+	structureClient   *pester.Client
+	structureStatuses map[int64]*evepraisal.StructureMarketStatus
+	structuresLock    sync.RWMutex
+
+	regions []*regionOrders
+
+	costIndices     map[int64]map[string]float64
+	costIndicesPage esiPage
+	costIndicesLock sync.RWMutex
+
+	routes     map[routeKey]int64
+	routesLock sync.RWMutex
+
+	loyaltyStores     map[int64]loyaltyStore
+	loyaltyStoresLock sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan bool
+	wg     *sync.WaitGroup
+	// ordersLoaded is closed once the orders have been fetched for the first time
+	ordersLoaded chan bool
 }
 
-// NewPriceFetcher returns a new PriceFetcher
-func NewPriceFetcher(ctx context.Context, priceDB evepraisal.PriceDB, baseURL string, client *pester.Client) (*PriceFetcher, error) {
-
+// NewPriceFetcher returns a new PriceFetcher. structureClient is used for player structure markets, which need an
+// authenticated character; it can be nil if no market uses structures. Nothing is fetched until it is started.
+func NewPriceFetcher(ctx context.Context, markets []evepraisal.Market, orderFilter OrderFilter, baseURL string, client *pester.Client, structureClient *pester.Client) (*PriceFetcher, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	p := &PriceFetcher{
-		db:      priceDB,
-		client:  client,
-		baseURL: baseURL,
+		markets:         markets,
+		orderFilter:     orderFilter,
+		client:          client,
+		baseURL:         baseURL,
+		structureClient: structureClient,
 
-		ctx:  ctx,
-		stop: make(chan bool),
-		wg:   &sync.WaitGroup{},
+		costIndices:       make(map[int64]map[string]float64),
+		structureStatuses: make(map[int64]*evepraisal.StructureMarketStatus),
+
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan bool),
+		wg:     &sync.WaitGroup{},
+
+		ordersLoaded: make(chan bool),
 	}
 
+	for _, regionID := range p.regionIDs() {
+		p.regions = append(p.regions, newRegionOrders(regionID))
+	}
+	for _, structureID := range p.structureIDs() {
+		if structureClient == nil {
+			log.Printf("WARN: structure %d is skipped because there is no service character", structureID)
+			continue
+		}
+		p.regions = append(p.regions, newStructureOrders(structureID))
+	}
+	return p, nil
+}
+
+// Start starts fetching orders, market history and cost indices. Prices and order books are written to db.
+func (p *PriceFetcher) Start(db evepraisal.PriceDB) error {
+	p.db = db
+
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
-		for {
-			start := time.Now()
-			p.runOnce()
+		for loaded := false; ; loaded = true {
+			next := p.runOnce(time.Now())
+			if !loaded {
+				close(p.ordersLoaded)
+			}
 			select {
-			case <-time.After((30 * time.Minute) - time.Since(start)):
+			case <-time.After(time.Until(next)):
 			case <-p.stop:
 				return
 			}
 		}
 	}()
 
-	return p, nil
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.runMarketHistoryLoop()
+	}()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.runCostIndexLoop()
+	}()
+
+	return nil
 }
 
-// Close should be called to stop the fetcher worker(s)
+// Close should be called to stop the fetcher worker(s). Any requests that are in flight are cancelled.
 func (p *PriceFetcher) Close() error {
 	close(p.stop)
+	p.cancel()
 	p.wg.Wait()
 	return nil
 }
 
-func regionNames() []string {
-	regions := make([]string, len(SpecialRegions)+1)
-	regions[0] = "universe"
-	for i, region := range SpecialRegions {
-		regions[i+1] = region.name
+// regionIDs returns every region that one of the markets needs orders from
+func (p *PriceFetcher) regionIDs() []int64 {
+	seen := make(map[int64]bool)
+	regionIDs := make([]int64, 0)
+	for _, market := range p.markets {
+		for _, regionID := range market.RegionIDs {
+			if !seen[regionID] {
+				seen[regionID] = true
+				regionIDs = append(regionIDs, regionID)
+			}
+		}
 	}
-	return regions
+	return regionIDs
 }
 
-func (p *PriceFetcher) runOnce() {
-	log.Println("Fetch market data")
-	priceMap, err := p.FetchOrderData(p.client, p.baseURL, []int{10000002, 10000027, 10000030, 10000032, 10000042, 10000043})
-	if err != nil {
-		log.Println("ERROR: fetching market data: ", err)
-		return
+// OrdersFetched returns when the orders in every region and structure of the given market were last fetched. Prices
+// are only rewritten when their orders change, so this is how old an unchanged price really is. It returns false if
+// the market isn't fetched or part of it hasn't been fetched yet.
+func (p *PriceFetcher) OrdersFetched(marketName string) (time.Time, bool) {
+	var market evepraisal.Market
+	found := false
+	for _, m := range p.markets {
+		if m.Name == marketName {
+			market, found = m, true
+			break
+		}
 	}
-
-	pricesFromCCP, err := p.FetchPriceData(p.client, p.baseURL)
-	if err != nil {
-		log.Println("ERROR: fetching CCP price data: ", err)
-		return
+	if !found {
+		return time.Time{}, false
 	}
 
-	for _, regionName := range regionNames() {
-		// Use CCP's price if our regional price is too low
-		for typeID, prices := range pricesFromCCP {
-			p, ok := priceMap[regionName][typeID]
-			if !ok || p.Sell.Volume < 10 {
-				priceMap[regionName][typeID] = prices
-			}
+	var oldest time.Time
+	covered := false
+	for _, region := range p.regions {
+		if !market.IsUniverse() && !marketHasRegion(market, region) {
+			continue
 		}
+		fetched := region.lastFetched()
+		if fetched.IsZero() {
+			return time.Time{}, false
+		}
+		if !covered || fetched.Before(oldest) {
+			oldest = fetched
+		}
+		covered = true
+	}
+	return oldest, covered
+}
 
-		// Use the universe price if our regional price is too low (override CCP's price)
-		for typeID, p := range priceMap[regionName] {
-			if p.Sell.Volume < 2 {
-				universePrice, ok := priceMap["universe"][typeID]
-				if ok && universePrice.Sell.Volume >= 2 {
-					universePrice.Strategy = "orders_universe"
-					priceMap[regionName][typeID] = universePrice
-				}
-			}
-
-			if regionName != "universe" && p.Buy.Volume > 0 && p.Sell.Volume > 0 && p.Buy.Max > p.Sell.Min {
-				delta := p.Buy.Max - p.Sell.Min
-				if delta > 1000000 {
-					log.Printf("MARKET: Prices are wack for %d in %s", typeID, regionName)
-				}
+// marketHasRegion returns true if the market has orders from the given region or structure
+func marketHasRegion(market evepraisal.Market, region *regionOrders) bool {
+	if region.structureID != 0 {
+		for _, structureID := range market.StructureIDs {
+			if structureID == region.structureID {
+				return true
 			}
 		}
+		return false
 	}
-
-	for market, pmap := range priceMap {
-		// this takes awhile, so let's check to see if we should stop between markets
-		select {
-		case <-p.stop:
-			return
-		default:
+	for _, regionID := range market.RegionIDs {
+		if regionID == region.regionID {
+			return true
 		}
+	}
+	return false
+}
 
-		items := make([]evepraisal.MarketItemPrices, len(pmap))
-		i := 0
-		for typeID, prices := range pmap {
-			items[i] = evepraisal.MarketItemPrices{
-				Market: market,
-				TypeID: typeID,
-				Prices: prices,
+// marketHasOrder returns true if the given order belongs to the given market. Composite markets have the orders of
+// each of their parts.
+func marketHasOrder(market evepraisal.Market, order MarketOrder) bool {
+	if market.IsComposite() {
+		for _, part := range market.Parts() {
+			if marketHasOrder(part, order) {
+				return true
 			}
-			i++
 		}
+		return false
+	}
 
-		err = p.db.UpdatePrices(items)
-		if err != nil {
-			log.Printf("Error when updating prices: %s", err)
+	for _, structureID := range market.StructureIDs {
+		if structureID == order.StationID {
+			return true
 		}
 	}
-	log.Println("Done fetching market data")
-}
 
-func (p *PriceFetcher) freshPriceMap() map[string]map[int64]evepraisal.Prices {
-	priceMap := make(map[string]map[int64]evepraisal.Prices)
-	for _, region := range SpecialRegions {
-		priceMap[region.name] = make(map[int64]evepraisal.Prices)
+	if market.IsUniverse() {
+		return true
 	}
-	priceMap["universe"] = make(map[int64]evepraisal.Prices)
-	return priceMap
-}
 
-// FetchPriceData fetches CCP's pricing information for every type
-func (p *PriceFetcher) FetchPriceData(client *pester.Client, baseURL string) (map[int64]evepraisal.Prices, error) {
-	start := time.Now()
-	url := fmt.Sprintf("%s/markets/prices/?datasource=tranquility", baseURL)
-	esiPrices := make([]struct {
-		TypeID        int64   `json:"type_id"`
-		AveragePrice  float64 `json:"average_price"`
-		AdjustedPrice float64 `json:"adjusted_price"`
-	}, 0)
-	err := fetchURL(p.ctx, client, url, &esiPrices)
-	if err != nil {
-		return nil, err
+	inRegion := false
+	for _, regionID := range market.RegionIDs {
+		if regionID == order.RegionID {
+			inRegion = true
+			break
+		}
+	}
+	if !inRegion {
+		return false
 	}
 
-	allPrices := make(map[int64]evepraisal.Prices, len(esiPrices))
-	for _, p := range esiPrices {
-		priceToUse := p.AveragePrice
-		if priceToUse == 0 {
-			priceToUse = p.AdjustedPrice
-		}
-		stats := evepraisal.PriceStats{
-			Average:    p.AveragePrice,
-			Max:        priceToUse,
-			Median:     priceToUse,
-			Min:        priceToUse,
-			Percentile: p.AdjustedPrice,
+	if len(market.StationIDs) == 0 && len(market.SystemIDs) == 0 {
+		return true
+	}
+	for _, station := range market.StationIDs {
+		if station == order.StationID {
+			return true
 		}
-		allPrices[p.TypeID] = evepraisal.Prices{
-			All:      stats,
-			Buy:      stats,
-			Sell:     stats,
-			Updated:  start,
-			Strategy: "ccp",
+	}
+	for _, system := range market.SystemIDs {
+		if system == order.SystemID {
+			return true
 		}
 	}
-	return allPrices, nil
+	return false
 }
 
-// FetchOrderData concurrently fetches from each region that we care about
-func (p *PriceFetcher) FetchOrderData(client *pester.Client, baseURL string, regionIDs []int) (map[string]map[int64]evepraisal.Prices, error) {
-	allOrdersByType := make(map[int64][]MarketOrder)
-	finished := make(chan bool, 1)
-	workerStop := make(chan bool, 1)
-	errChannel := make(chan error, 1)
-	fetchStart := time.Now()
+// runOnce refetches whatever has expired, updates the prices of every type that changed and returns when it should
+// run next
+func (p *PriceFetcher) runOnce(now time.Time) time.Time {
+	changedTypes := p.refreshOrders(now)
 
-	l := &sync.Mutex{}
-	requestAndProcess := func(url string) (bool, error) {
-		var orders []MarketOrder
-		err := fetchURL(p.ctx, client, url, &orders)
-		if err != nil {
-			return false, err
-		}
+	if len(changedTypes) > 0 {
+		log.Printf("Updating prices for %d types", len(changedTypes))
+		p.updatePrices(changedTypes, now)
+		log.Println("Done updating prices")
+	}
 
-		if len(orders) == 0 {
-			return false, nil
-		}
+	return p.nextRefresh(now)
+}
 
-		l.Lock()
-		for _, order := range orders {
-			allOrdersByType[order.Type] = append(allOrdersByType[order.Type], order)
+// refreshOrders concurrently refreshes every region that has expired. It returns the type IDs of every order that
+// changed.
+func (p *PriceFetcher) refreshOrders(now time.Time) map[int64]bool {
+	changedTypes := make(map[int64]bool)
+	l := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for _, region := range p.regions {
+		if now.Before(region.expires) {
+			continue
 		}
-		l.Unlock()
-		return true, nil
-	}
 
-	wg := &sync.WaitGroup{}
-	for _, regionID := range regionIDs {
 		wg.Add(1)
-		go func(regionID int) {
+		go func(region *regionOrders) {
 			defer wg.Done()
-			page := 1
-			for {
-				select {
-				case <-workerStop:
-					return
-				default:
+			regionChangedTypes := make(map[int64]bool)
+			if region.structureID != 0 {
+				err := p.refreshStructure(region, now, regionChangedTypes)
+				if err != nil && p.ctx.Err() == nil {
+					log.Printf("ERROR: fetching market orders for structure %d: %s", region.structureID, err)
 				}
-
-				url := fmt.Sprintf("%s/markets/%d/orders/?datasource=tranquility&order_type=all&page=%d", baseURL, regionID, page)
-				hasMore, err := requestAndProcess(url)
-				if err != nil {
-					errChannel <- fmt.Errorf("Failed to fetch market orders: %s (%s)", err, url)
-					return
+			} else {
+				err := p.refreshRegion(region, now, regionChangedTypes)
+				if err != nil && p.ctx.Err() == nil {
+					log.Printf("ERROR: fetching market orders for region %d: %s", region.regionID, err)
 				}
+			}
 
-				if !hasMore {
-					break
-				}
-				page++
+			l.Lock()
+			for typeID := range regionChangedTypes {
+				changedTypes[typeID] = true
 			}
-		}(regionID)
+			l.Unlock()
+		}(region)
 	}
+	wg.Wait()
+	return changedTypes
+}
 
-	go func() {
-		wg.Wait()
-		close(finished)
-	}()
+// nextRefresh returns when the next region expires
+func (p *PriceFetcher) nextRefresh(now time.Time) time.Time {
+	var next time.Time
+	for i, region := range p.regions {
+		if i == 0 || region.expires.Before(next) {
+			next = region.expires
+		}
+	}
+
+	if next.Before(now.Add(minRefreshInterval)) {
+		return now.Add(minRefreshInterval)
+	}
+	if next.After(now.Add(maxRefreshInterval)) {
+		return now.Add(maxRefreshInterval)
+	}
+	return next
+}
+
+// updatePrices recalculates prices and order books for the given types in every market and saves them
+func (p *PriceFetcher) updatePrices(changedTypes map[int64]bool, now time.Time) {
+	priceItems := make(map[string][]evepraisal.MarketItemPrices)
+	bookItems := make(map[string][]evepraisal.MarketItemOrderBook)
+	for typeID := range changedTypes {
+		var orders []MarketOrder
+		for _, region := range p.regions {
+			orders = append(orders, region.ordersForType(typeID)...)
+		}
+
+		prices, books := p.aggregateType(typeID, orders, now)
+		for market, price := range prices {
+			priceItems[market] = append(priceItems[market], evepraisal.MarketItemPrices{Market: market, TypeID: typeID, Prices: price})
+		}
+		for market, book := range books {
+			bookItems[market] = append(bookItems[market], evepraisal.MarketItemOrderBook{Market: market, TypeID: typeID, OrderBook: book})
+		}
+	}
+
+	for _, market := range p.markets {
+		// this takes awhile, so let's check to see if we should stop between markets
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
 
-	select {
-	case <-finished:
-	case <-p.stop:
-		close(workerStop)
-		return nil, errors.New("Stopping during price fetch")
-	case err := <-errChannel:
+		err := p.db.UpdatePrices(priceItems[market.Name])
 		if err != nil {
-			close(workerStop)
-			return nil, err
+			log.Printf("Error when updating prices: %s", err)
 		}
+
+		err = p.db.UpdateOrderBooks(bookItems[market.Name])
+		if err != nil {
+			log.Printf("Error when updating order books: %s", err)
+		}
+	}
+}
+
+// aggregateType calculates the prices and order books of a single type for every market. The universe price is used
+// when a market has almost no orders.
+func (p *PriceFetcher) aggregateType(typeID int64, orders []MarketOrder, now time.Time) (map[string]evepraisal.Prices, map[string]evepraisal.OrderBook) {
+	prices := make(map[string]evepraisal.Prices)
+	books := make(map[string]evepraisal.OrderBook)
+	if len(orders) == 0 {
+		return prices, books
 	}
 
-	log.Println("Performing aggregates on order data")
-	// Calculate aggregates that we care about:
-	newPriceMap := p.freshPriceMap()
-	for k, orders := range allOrdersByType {
-		for _, region := range SpecialRegions {
-			filteredOrders := make([]MarketOrder, 0)
-			ordercount := 0
+	universePrice := getPriceAggregatesForOrders(orders, p.orderFilter)
+	universePrice.Updated = now
+	universePrice.Strategy = "orders_universe"
+
+	for _, market := range p.markets {
+		filteredOrders := orders
+		if !market.IsUniverse() {
+			filteredOrders = make([]MarketOrder, 0)
 			for _, order := range orders {
-				matched := false
-				for _, station := range region.stations {
-					if station == order.StationID {
-						matched = true
-						ordercount++
-						break
-					}
-				}
-				for _, system := range region.systems {
-					if system == order.SystemID {
-						matched = true
-						ordercount++
-						break
-					}
-				}
-				if matched {
+				if marketHasOrder(market, order) {
 					filteredOrders = append(filteredOrders, order)
 				}
 			}
-			agg := getPriceAggregatesForOrders(filteredOrders)
-			agg.Updated = fetchStart
+		}
+
+		book := getOrderBookForOrders(filteredOrders)
+		book.Updated = now
+		books[market.Name] = book
+
+		agg := getPriceAggregatesForOrders(filteredOrders, p.orderFilter)
+		agg.Updated = now
+		if !market.IsUniverse() {
 			agg.Strategy = "orders"
-			newPriceMap[region.name][k] = agg
 		}
-		agg := getPriceAggregatesForOrders(orders)
-		agg.Updated = fetchStart
-		newPriceMap["universe"][k] = agg
-	}
 
-	log.Println("Finished performing aggregates on order data")
+		// Use the universe price if our regional price is too low
+		if !market.IsUniverse() && agg.Sell.Volume < 2 && universePrice.Sell.Volume >= 2 {
+			agg = universePrice
+		}
 
-	return newPriceMap, nil
+		if !market.IsUniverse() && agg.Buy.Volume > 0 && agg.Sell.Volume > 0 && agg.Buy.Max > agg.Sell.Min {
+			delta := agg.Buy.Max - agg.Sell.Min
+			if delta > 1000000 {
+				log.Printf("MARKET: Prices are wack for %d in %s", typeID, market.Name)
+			}
+		}
+
+		prices[market.Name] = agg
+	}
+	return prices, books
 }
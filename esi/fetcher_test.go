@@ -0,0 +1,657 @@
+package esi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/sethgrid/pester"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePriceDB records every price, order book and market history that is written to it
+type fakePriceDB struct {
+	l       sync.Mutex
+	prices  []evepraisal.MarketItemPrices
+	books   []evepraisal.MarketItemOrderBook
+	history map[string]evepraisal.MarketHistory
+}
+
+func (db *fakePriceDB) GetPrice(market string, typeID int64) (evepraisal.Prices, bool) {
+	db.l.Lock()
+	defer db.l.Unlock()
+	for i := len(db.prices) - 1; i >= 0; i-- {
+		if db.prices[i].Market == market && db.prices[i].TypeID == typeID {
+			return db.prices[i].Prices, true
+		}
+	}
+	return evepraisal.Prices{}, false
+}
+
+func (db *fakePriceDB) GetPrices(market string, typeIDs []int64) (map[int64]evepraisal.Prices, error) {
+	return map[int64]evepraisal.Prices{}, nil
+}
+
+func (db *fakePriceDB) GetPriceHistory(market string, typeID int64, from time.Time, to time.Time) ([]evepraisal.Prices, error) {
+	return nil, nil
+}
+
+func (db *fakePriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
+	db.l.Lock()
+	defer db.l.Unlock()
+	db.prices = append(db.prices, items...)
+	return nil
+}
+
+func (db *fakePriceDB) GetOrderBook(market string, typeID int64) (evepraisal.OrderBook, bool) {
+	return evepraisal.OrderBook{}, false
+}
+
+func (db *fakePriceDB) UpdateOrderBooks(items []evepraisal.MarketItemOrderBook) error {
+	db.l.Lock()
+	defer db.l.Unlock()
+	db.books = append(db.books, items...)
+	return nil
+}
+
+func (db *fakePriceDB) GetMarketHistory(regionID int64, typeID int64) (evepraisal.MarketHistory, bool) {
+	db.l.Lock()
+	defer db.l.Unlock()
+	history, ok := db.history[fmt.Sprintf("%d|%d", regionID, typeID)]
+	return history, ok
+}
+
+func (db *fakePriceDB) UpdateMarketHistory(items []evepraisal.RegionItemMarketHistory) error {
+	db.l.Lock()
+	defer db.l.Unlock()
+	if db.history == nil {
+		db.history = make(map[string]evepraisal.MarketHistory)
+	}
+	for _, item := range items {
+		db.history[fmt.Sprintf("%d|%d", item.RegionID, item.TypeID)] = item.History
+	}
+	return nil
+}
+
+func (db *fakePriceDB) Close() error {
+	return nil
+}
+
+func (db *fakePriceDB) reset() map[int64]evepraisal.Prices {
+	db.l.Lock()
+	defer db.l.Unlock()
+	prices := make(map[int64]evepraisal.Prices)
+	for _, item := range db.prices {
+		if item.Market == "jita" {
+			prices[item.TypeID] = item.Prices
+		}
+	}
+	db.prices = nil
+	db.books = nil
+	return prices
+}
+
+// fakeESI serves versioned JSON documents with ETags and counts the requests that actually returned a body
+type fakeESI struct {
+	l        sync.Mutex
+	docs     map[string]interface{}
+	versions map[string]int
+	pages    int
+	fetched  map[string]int
+	// denied paths are answered with 403 Forbidden
+	denied map[string]bool
+}
+
+func newFakeESI() *fakeESI {
+	return &fakeESI{
+		docs:     make(map[string]interface{}),
+		versions: make(map[string]int),
+		fetched:  make(map[string]int),
+		denied:   make(map[string]bool),
+	}
+}
+
+func (esi *fakeESI) set(path string, doc interface{}) {
+	esi.l.Lock()
+	defer esi.l.Unlock()
+	esi.docs[path] = doc
+	esi.versions[path]++
+}
+
+func (esi *fakeESI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	esi.l.Lock()
+	defer esi.l.Unlock()
+
+	path := r.URL.Path
+	if page := r.URL.Query().Get("page"); page != "" {
+		path += "?page=" + page
+	}
+	if typeID := r.URL.Query().Get("type_id"); typeID != "" {
+		path += "?type_id=" + typeID
+	}
+	if esi.denied[path] {
+		http.Error(w, `{"error":"Forbidden"}`, http.StatusForbidden)
+		return
+	}
+	doc, ok := esi.docs[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, path, esi.versions[path])
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Pages", fmt.Sprintf("%d", esi.pages))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	esi.fetched[path]++
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func newTestPriceFetcher(db evepraisal.PriceDB, baseURL string) *PriceFetcher {
+	client := pester.New()
+	client.MaxRetries = 1
+	p := &PriceFetcher{
+		db: db,
+		markets: evepraisal.NormalizeMarkets([]evepraisal.Market{
+			{Name: "jita", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000142}},
+		}),
+		client:            client,
+		baseURL:           baseURL,
+		structureStatuses: make(map[int64]*evepraisal.StructureMarketStatus),
+		ctx:               context.Background(),
+		stop:              make(chan bool),
+		wg:                &sync.WaitGroup{},
+	}
+	for _, regionID := range p.regionIDs() {
+		p.regions = append(p.regions, newRegionOrders(regionID))
+	}
+	return p
+}
+
+func testMarketOrders(typeID int64, price float64) []MarketOrder {
+	return []MarketOrder{
+		{ID: typeID*10 + 1, Type: typeID, SystemID: 30000142, Price: price, Volume: 100},
+		{ID: typeID*10 + 2, Type: typeID, SystemID: 30000142, Price: price * 0.9, Volume: 100, Buy: true},
+	}
+}
+
+func TestPriceFetcherOnlyRefetchesChangedPages(t *testing.T) {
+	esi := newFakeESI()
+	esi.pages = 2
+	esi.set("/markets/10000002/orders/?page=1", testMarketOrders(34, 5))
+	esi.set("/markets/10000002/orders/?page=2", testMarketOrders(35, 10))
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := newTestPriceFetcher(db, ts.URL)
+
+	now := time.Now()
+	next := p.runOnce(now)
+	assert.True(t, next.After(now))
+
+	prices := db.reset()
+	assert.Len(t, prices, 2)
+	assert.Equal(t, 5.0, prices[34].Sell.Min)
+	assert.Equal(t, 10.0, prices[35].Sell.Min)
+	assert.Equal(t, "orders", prices[35].Strategy)
+
+	// Nothing has expired yet so nothing is fetched
+	p.runOnce(now)
+	assert.Len(t, db.reset(), 0)
+
+	// Only page 2 changes, so only the type on that page gets new prices
+	esi.set("/markets/10000002/orders/?page=2", testMarketOrders(35, 12))
+	p.runOnce(now.Add(2 * time.Hour))
+	prices = db.reset()
+	assert.Len(t, prices, 1)
+	assert.Equal(t, 12.0, prices[35].Sell.Min)
+	assert.Equal(t, 1, esi.fetched["/markets/10000002/orders/?page=1"])
+	assert.Equal(t, 2, esi.fetched["/markets/10000002/orders/?page=2"])
+
+	// Page 2 disappears so the orders on it are removed
+	esi.pages = 1
+	esi.set("/markets/10000002/orders/?page=1", testMarketOrders(34, 5))
+	p.runOnce(now.Add(4 * time.Hour))
+	prices = db.reset()
+	assert.Len(t, prices, 1)
+	assert.Equal(t, 5.0, prices[34].Sell.Min)
+	assert.Len(t, p.regions[0].ordersForType(35), 0)
+}
+
+func TestPriceFetcherUnchangedPricesStayFresh(t *testing.T) {
+	esi := newFakeESI()
+	esi.pages = 1
+	esi.set("/markets/10000002/orders/?page=1", testMarketOrders(34, 5))
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := newTestPriceFetcher(db, ts.URL)
+	_, ok := p.OrdersFetched("jita")
+	assert.False(t, ok)
+
+	now := time.Now()
+	p.runOnce(now)
+	prices := db.reset()
+	assert.Equal(t, now, prices[34].Updated)
+
+	// ESI says nothing changed, so the prices aren't rewritten but the orders were still fetched
+	later := now.Add(3 * time.Hour)
+	p.runOnce(later)
+	assert.Len(t, db.reset(), 0)
+	assert.Equal(t, 1, esi.fetched["/markets/10000002/orders/?page=1"])
+
+	fetched, ok := p.OrdersFetched("jita")
+	assert.True(t, ok)
+	assert.Equal(t, later, fetched)
+	fetched, ok = p.OrdersFetched(evepraisal.UniverseMarketName)
+	assert.True(t, ok)
+	assert.Equal(t, later, fetched)
+	_, ok = p.OrdersFetched("amarr")
+	assert.False(t, ok)
+
+	app := &evepraisal.App{StalePriceAge: 2 * time.Hour, OrdersFetched: p}
+	item := evepraisal.AppraisalItem{Quantity: 1, Prices: prices[34]}
+	for _, warning := range app.WarningsForItem("jita", item, later) {
+		assert.NotEqual(t, evepraisal.WarningStalePrice, warning.Code)
+	}
+
+	// Orders that can't be fetched anymore do go stale
+	esi.denied["/markets/10000002/orders/?page=1"] = true
+	p.runOnce(later.Add(2 * time.Hour))
+	warnings := app.WarningsForItem("jita", item, later.Add(3*time.Hour))
+	assert.Contains(t, warnings, evepraisal.ItemWarning{Code: evepraisal.WarningStalePrice, Message: "Prices were last updated 3 hours ago"})
+}
+
+func TestPriceFetcherCloseCancelsRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	p, err := NewPriceFetcher(context.Background(), evepraisal.NormalizeMarkets(nil), OrderFilter{}, ts.URL, pester.New(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Start(&fakePriceDB{}))
+
+	// Give the fetcher a chance to start its requests
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan bool)
+	go func() {
+		_ = p.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not return")
+	}
+}
+
+func TestPriceFetcherMarketHistory(t *testing.T) {
+	esi := newFakeESI()
+	esi.pages = 1
+	esi.set("/markets/10000002/orders/?page=1", testMarketOrders(34, 5))
+	esi.set("/markets/10000002/history/?type_id=34", []evepraisal.MarketHistoryDay{
+		{Date: "2020-01-02", Average: 5, Highest: 6, Lowest: 4, OrderCount: 10, Volume: 2000},
+		{Date: "2020-01-01", Average: 5, Highest: 6, Lowest: 4, OrderCount: 10, Volume: 1000},
+	})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := newTestPriceFetcher(db, ts.URL)
+
+	now := time.Now()
+	p.runOnce(now)
+	assert.NoError(t, p.refreshMarketHistory(now))
+
+	history, ok := db.GetMarketHistory(10000002, 34)
+	assert.True(t, ok)
+	assert.Len(t, history.Days, 2)
+	assert.Equal(t, "2020-01-01", history.Days[0].Date)
+	assert.True(t, history.Expires.After(now))
+
+	// History that hasn't expired isn't fetched again
+	assert.NoError(t, p.refreshMarketHistory(now))
+	assert.Equal(t, 1, esi.fetched["/markets/10000002/history/?type_id=34"])
+
+	// Expired history that hasn't changed is revalidated but not downloaded again
+	assert.NoError(t, p.refreshMarketHistory(now.Add(2*time.Hour)))
+	assert.Equal(t, 1, esi.fetched["/markets/10000002/history/?type_id=34"])
+	history, _ = db.GetMarketHistory(10000002, 34)
+	assert.Len(t, history.Days, 2)
+}
+
+func TestPriceFetcherMarketHistoryConcurrently(t *testing.T) {
+	esi := newFakeESI()
+	esi.pages = 1
+	var orders []MarketOrder
+	for typeID := int64(1); typeID <= 50; typeID++ {
+		orders = append(orders, testMarketOrders(typeID, 5)...)
+		esi.set(fmt.Sprintf("/markets/10000002/history/?type_id=%d", typeID), []evepraisal.MarketHistoryDay{
+			{Date: "2020-01-01", Average: 5, Highest: 6, Lowest: 4, OrderCount: 10, Volume: typeID},
+		})
+	}
+	esi.set("/markets/10000002/orders/?page=1", orders)
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := newTestPriceFetcher(db, ts.URL)
+	now := time.Now()
+	p.runOnce(now)
+	assert.NoError(t, p.refreshMarketHistory(now))
+	for typeID := int64(1); typeID <= 50; typeID++ {
+		history, ok := db.GetMarketHistory(10000002, typeID)
+		if assert.True(t, ok, typeID) && assert.Len(t, history.Days, 1) {
+			assert.Equal(t, typeID, history.Days[0].Volume)
+		}
+	}
+
+	// Errors stop the pass and are returned
+	esi.denied["/markets/10000002/history/?type_id=7"] = true
+	assert.Error(t, p.refreshMarketHistory(now.Add(2*time.Hour)))
+}
+
+func TestPriceFetcherCostIndices(t *testing.T) {
+	esi := newFakeESI()
+	esi.set("/industry/systems/", []map[string]interface{}{
+		{"solar_system_id": 30000142, "cost_indices": []map[string]interface{}{
+			{"activity": "manufacturing", "cost_index": 0.0512},
+			{"activity": "invention", "cost_index": 0.03},
+		}},
+	})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	p := newTestPriceFetcher(&fakePriceDB{}, ts.URL)
+	assert.NoError(t, p.refreshCostIndices())
+
+	costIndex, ok := p.CostIndex(30000142, "manufacturing")
+	assert.True(t, ok)
+	assert.InDelta(t, 5.12, costIndex, 0.00001)
+
+	_, ok = p.CostIndex(30000144, "manufacturing")
+	assert.False(t, ok)
+
+	// Unchanged cost indices aren't downloaded again
+	p.costIndicesPage.expires = time.Time{}
+	assert.NoError(t, p.refreshCostIndices())
+	assert.Equal(t, 1, esi.fetched["/industry/systems/"])
+}
+
+func TestPriceFetcherJumps(t *testing.T) {
+	esi := newFakeESI()
+	esi.set("/route/30000142/30002187/", []int64{30000142, 30000144, 30002187})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	p := newTestPriceFetcher(&fakePriceDB{}, ts.URL)
+	jumps, ok := p.Jumps(30000142, 30002187)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), jumps)
+
+	// Routes are only fetched once
+	jumps, _ = p.Jumps(30000142, 30002187)
+	assert.Equal(t, int64(2), jumps)
+	assert.Equal(t, 1, esi.fetched["/route/30000142/30002187/"])
+
+	jumps, ok = p.Jumps(30000142, 30000142)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), jumps)
+
+	// ESI doesn't know a route to wormhole space
+	_, ok = p.Jumps(30000142, 31000005)
+	assert.False(t, ok)
+}
+
+func TestPriceFetcherLoyaltyStoreOffers(t *testing.T) {
+	esi := newFakeESI()
+	esi.set("/loyalty/stores/1000180/offers/", []map[string]interface{}{
+		{"offer_id": 3584, "type_id": 17715, "quantity": 1, "lp_cost": 100000, "isk_cost": 60000000, "ak_cost": 0,
+			"required_items": []map[string]interface{}{{"type_id": 24698, "quantity": 1}}},
+		{"offer_id": 3585, "type_id": 31932, "quantity": 5000, "lp_cost": 1000, "isk_cost": 0, "required_items": []interface{}{}},
+	})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	p := newTestPriceFetcher(&fakePriceDB{}, ts.URL)
+	offers, err := p.LoyaltyStoreOffers(1000180)
+	assert.NoError(t, err)
+	assert.Equal(t, []evepraisal.LoyaltyOffer{
+		{OfferID: 3584, TypeID: 17715, Quantity: 1, LPCost: 100000, ISKCost: 60000000,
+			RequiredItems: []evepraisal.LoyaltyOfferItem{{TypeID: 24698, Quantity: 1}}},
+		{OfferID: 3585, TypeID: 31932, Quantity: 5000, LPCost: 1000, RequiredItems: []evepraisal.LoyaltyOfferItem{}},
+	}, offers)
+
+	// Stores are kept until they expire
+	_, err = p.LoyaltyStoreOffers(1000180)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, esi.fetched["/loyalty/stores/1000180/offers/"])
+
+	// Expired stores are fetched again, and the last offers are used when ESI fails
+	p.loyaltyStores[1000180] = loyaltyStore{page: esiPage{}, offers: offers}
+	esi.denied["/loyalty/stores/1000180/offers/"] = true
+	offers, err = p.LoyaltyStoreOffers(1000180)
+	assert.NoError(t, err)
+	assert.Len(t, offers, 2)
+
+	// Corporations without a store have no offers
+	offers, err = p.LoyaltyStoreOffers(1000181)
+	assert.NoError(t, err)
+	assert.Empty(t, offers)
+
+	esi.denied["/loyalty/stores/1000182/offers/"] = true
+	_, err = p.LoyaltyStoreOffers(1000182)
+	assert.Error(t, err)
+}
+
+func TestPriceFetcherCompositeMarkets(t *testing.T) {
+	p := newTestPriceFetcher(&fakePriceDB{}, "")
+	p.markets = evepraisal.NormalizeMarkets([]evepraisal.Market{
+		{Name: "jita", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000142}},
+		{Name: "perimeter", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000144}},
+		{Name: "jita+perimeter", Markets: []string{"jita", "perimeter"}},
+	})
+	assert.Equal(t, []int64{10000002}, p.regionIDs())
+
+	orders := []MarketOrder{
+		{ID: 1, Type: 34, RegionID: 10000002, SystemID: 30000142, Price: 5, Volume: 1},
+		{ID: 2, Type: 34, RegionID: 10000002, SystemID: 30000144, Price: 6, Volume: 1},
+		{ID: 3, Type: 34, RegionID: 10000002, SystemID: 30000145, Price: 4, Volume: 1},
+		{ID: 4, Type: 34, RegionID: 10000002, SystemID: 30000144, Price: 3, Volume: 10, Buy: true},
+	}
+	prices, books := p.aggregateType(34, orders, time.Now())
+
+	composite := prices["jita+perimeter"]
+	assert.Equal(t, "orders", composite.Strategy)
+	assert.Equal(t, 5.0, composite.Sell.Min)
+	assert.Equal(t, int64(2), composite.Sell.Volume)
+	assert.Equal(t, 3.0, composite.Buy.Max)
+	assert.Len(t, books["jita+perimeter"].Sell, 2)
+
+	// Jita alone is too thin and falls back to the universe price
+	assert.Equal(t, "orders_universe", prices["jita"].Strategy)
+}
+
+func TestPriceFetcherStructureMarkets(t *testing.T) {
+	structureOrders := []MarketOrder{
+		{ID: 1, Type: 34, StationID: 1022734985679, Price: 4, Volume: 100},
+		{ID: 2, Type: 34, StationID: 1022734985679, Price: 3, Volume: 100, Buy: true},
+	}
+	esi := newFakeESI()
+	esi.pages = 1
+	esi.set("/markets/10000002/orders/?page=1", testMarketOrders(34, 5))
+	esi.set("/markets/structures/1022734985679/?page=1", structureOrders)
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := newTestPriceFetcher(db, ts.URL)
+	p.structureClient = p.client
+	p.markets = evepraisal.NormalizeMarkets([]evepraisal.Market{
+		{Name: "jita", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000142}},
+		{Name: "keepstar", StructureIDs: []int64{1022734985679}},
+	})
+	assert.Equal(t, []int64{1022734985679}, p.structureIDs())
+	p.regions = append(p.regions, newStructureOrders(1022734985679))
+
+	structurePrices := func() evepraisal.Prices {
+		db.l.Lock()
+		defer db.l.Unlock()
+		var prices evepraisal.Prices
+		for _, item := range db.prices {
+			if item.Market == "keepstar" && item.TypeID == 34 {
+				prices = item.Prices
+			}
+		}
+		db.prices = nil
+		return prices
+	}
+
+	now := time.Now()
+	p.runOnce(now)
+	prices := structurePrices()
+	assert.Equal(t, "orders", prices.Strategy)
+	assert.Equal(t, 4.0, prices.Sell.Min)
+	assert.Equal(t, 3.0, prices.Buy.Max)
+
+	statuses := p.StructureMarketStatuses()
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, 2, statuses[0].OrderCount)
+	assert.Equal(t, now, statuses[0].LastFetched)
+	assert.False(t, statuses[0].AccessDenied)
+
+	// The service character loses access, so the structure's orders are dropped and it falls back to other prices
+	esi.denied["/markets/structures/1022734985679/?page=1"] = true
+	p.runOnce(now.Add(2 * time.Hour))
+	prices = structurePrices()
+	assert.Equal(t, "orders_universe", prices.Strategy)
+	assert.Equal(t, 5.0, prices.Sell.Min)
+
+	statuses = p.StructureMarketStatuses()
+	assert.Len(t, statuses, 1)
+	assert.True(t, statuses[0].AccessDenied)
+	assert.Equal(t, 0, statuses[0].OrderCount)
+	assert.Contains(t, statuses[0].Error, "403")
+	assert.Equal(t, now, statuses[0].LastFetched)
+	assert.True(t, p.regions[1].expires.After(now.Add(2*time.Hour)))
+}
+
+func TestCCPPriceFetcher(t *testing.T) {
+	esi := newFakeESI()
+	esi.set("/markets/prices/", []map[string]interface{}{
+		{"type_id": 34, "average_price": 5.5, "adjusted_price": 5.2},
+		{"type_id": 35, "adjusted_price": 9.1},
+	})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	p := NewCCPPriceFetcher(context.Background(), []string{"jita"}, ts.URL, pester.New())
+	p.db = db
+
+	now := time.Now()
+	next := p.runOnce(now)
+	assert.True(t, next.After(now))
+
+	// Every type is written to the ccp market and to jita
+	assert.Len(t, db.prices, 4)
+	prices := db.reset()
+	assert.Len(t, prices, 2)
+	assert.Equal(t, "ccp", prices[34].Strategy)
+	assert.Equal(t, 5.5, prices[34].Sell.Min)
+	assert.Equal(t, 9.1, prices[35].Buy.Max)
+
+	// Nothing has expired so nothing is fetched
+	p.runOnce(now)
+	assert.Len(t, db.reset(), 0)
+	assert.Equal(t, 1, esi.fetched["/markets/prices/"])
+
+	// Without any markets the prices only go to the ccp market
+	db = &fakePriceDB{}
+	p = NewCCPPriceFetcher(context.Background(), nil, ts.URL, pester.New())
+	p.db = db
+	p.runOnce(now)
+	if assert.Len(t, db.prices, 2) {
+		assert.Equal(t, evepraisal.CCPMarketName, db.prices[0].Market)
+		assert.Equal(t, evepraisal.CCPMarketName, db.prices[1].Market)
+	}
+}
+
+// fakeTypeDB doesn't know any types
+type fakeTypeDB struct {
+	typedb.TypeDB
+}
+
+func (db fakeTypeDB) GetTypeByID(typeID int64) (typedb.EveType, bool) {
+	return typedb.EveType{}, false
+}
+
+func TestFallbackOrdersOnlyUsesMarketOrders(t *testing.T) {
+	esi := newFakeESI()
+	esi.pages = 1
+	esi.set("/markets/10000002/orders/?page=1", append(testMarketOrders(34, 5),
+		// Jita barely has any of type 36, so the fetcher stores the price of the whole region under jita
+		MarketOrder{ID: 361, Type: 36, SystemID: 30000142, Price: 100, Volume: 1},
+		MarketOrder{ID: 362, Type: 36, SystemID: 30000144, Price: 90, Volume: 100},
+	))
+	// Type 37 has no orders at all, so the merger stores CCP's price under jita when esi_ccp is used for jita
+	esi.set("/markets/prices/", []map[string]interface{}{
+		{"type_id": 36, "average_price": 95},
+		{"type_id": 37, "average_price": 50},
+	})
+	ts := httptest.NewServer(esi)
+	defer ts.Close()
+
+	db := &fakePriceDB{}
+	merger := evepraisal.NewPriceMerger(db, evepraisal.NormalizePriceSources(nil))
+	ordersDB, err := merger.SourceDB(evepraisal.PriceSourceESIOrders)
+	assert.NoError(t, err)
+	ccpDB, err := merger.SourceDB(evepraisal.PriceSourceESICCP)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	newTestPriceFetcher(ordersDB, ts.URL).runOnce(now)
+	ccp := NewCCPPriceFetcher(context.Background(), []string{"jita"}, ts.URL, pester.New())
+	ccp.db = ccpDB
+	ccp.runOnce(now)
+
+	app := &evepraisal.App{TypeDB: fakeTypeDB{}, PriceDB: db, PriceFallbacks: []string{evepraisal.FallbackOrders}}
+	pricesFor := func(typeID int64) evepraisal.Prices {
+		prices, err := app.PricesForItem("jita", evepraisal.AppraisalItem{TypeID: typeID})
+		assert.NoError(t, err)
+		return prices
+	}
+
+	prices := pricesFor(34)
+	assert.Equal(t, "orders", prices.Strategy)
+	assert.Equal(t, 5.0, prices.Sell.Min)
+
+	// Neither copy is an order in jita
+	assert.Equal(t, evepraisal.Prices{}, pricesFor(36))
+	assert.Equal(t, evepraisal.Prices{}, pricesFor(37))
+
+	// The rest of the chain says where the price came from
+	app.PriceFallbacks = evepraisal.DefaultPriceFallbacks
+	prices = pricesFor(36)
+	assert.Equal(t, "orders_universe", prices.Strategy)
+	assert.Equal(t, 90.0, prices.Sell.Min)
+	prices = pricesFor(37)
+	assert.Equal(t, "ccp", prices.Strategy)
+	assert.Equal(t, 50.0, prices.Sell.Min)
+}
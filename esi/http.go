@@ -4,33 +4,90 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/sethgrid/pester"
 )
 
-func fetchURL(ctx context.Context, client *pester.Client, url string, r interface{}) error {
+// defaultExpiry is used when ESI doesn't say how long a response is good for
+var defaultExpiry = 5 * time.Minute
+
+// esiPage keeps the cache details of an ESI response so it can be refetched only when it changes
+type esiPage struct {
+	etag    string
+	expires time.Time
+	pages   int
+}
+
+// expired returns true if ESI may have a newer version of the page
+func (page esiPage) expired(now time.Time) bool {
+	return !now.Before(page.expires)
+}
+
+// esiError is returned when ESI responds with an error status
+type esiError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err esiError) Error() string {
+	return fmt.Sprintf("Error talking to esi: %s", err.Status)
+}
+
+// fetchURLIfChanged fetches the given URL and decodes it into r, but only if its ETag is different from the one on
+// the given page. The page is updated with the new cache details. Returns true if r was decoded. A 404 is treated as
+// an empty (but changed) response.
+func fetchURLIfChanged(ctx context.Context, client *pester.Client, url string, page *esiPage, r interface{}) (bool, error) {
 	// log.Printf("Fetching %s", url)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	req.Header.Add("User-Agent", "go-evepraisal")
+	if page.etag != "" {
+		req.Header.Add("If-None-Match", page.etag)
+	}
 	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return false, err
 	}
+	defer resp.Body.Close()
 
 	switch resp.StatusCode {
-	case 200:
+	case 200, 304:
 	case 404:
-		return nil
+		_, _ = io.Copy(io.Discard, resp.Body)
+		page.etag = ""
+		page.expires = time.Now().Add(defaultExpiry)
+		return true, nil
 	default:
-		return fmt.Errorf("Error talking to esi: %s", resp.Status)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, esiError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	page.expires = time.Now().Add(defaultExpiry)
+	if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		page.expires = expires
+	}
+	if pages, err := strconv.Atoi(resp.Header.Get("X-Pages")); err == nil {
+		page.pages = pages
+	}
+
+	etag := resp.Header.Get("ETag")
+	// The cache transport turns 304s into the cached response so the ETag is compared as well
+	if resp.StatusCode == 304 || (etag != "" && etag == page.etag) {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(r)
-	defer resp.Body.Close()
-	return err
+	if err != nil {
+		return false, err
+	}
+	page.etag = etag
+	return true, nil
 }
@@ -0,0 +1,70 @@
+package esi
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// IndustrySystem is the cost indices of a solar system from ESI
+type IndustrySystem struct {
+	SolarSystemID int64 `json:"solar_system_id"`
+	CostIndices   []struct {
+		Activity  string  `json:"activity"`
+		CostIndex float64 `json:"cost_index"`
+	} `json:"cost_indices"`
+}
+
+func (p *PriceFetcher) runCostIndexLoop() {
+	for {
+		err := p.refreshCostIndices()
+		if err != nil && p.ctx.Err() == nil {
+			log.Printf("ERROR: fetching cost indices: %s", err)
+		}
+
+		wait := time.Until(p.costIndicesPage.expires)
+		if err != nil || wait < minRefreshInterval {
+			wait = minRefreshInterval
+		}
+		select {
+		case <-time.After(wait):
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// refreshCostIndices fetches the cost indices of every system if they have changed
+func (p *PriceFetcher) refreshCostIndices() error {
+	url := fmt.Sprintf("%s/industry/systems/?datasource=tranquility", p.baseURL)
+	var systems []IndustrySystem
+	changed, err := fetchURLIfChanged(p.ctx, p.client, url, &p.costIndicesPage, &systems)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch cost indices: %s (%s)", err, url)
+	}
+	if !changed {
+		return nil
+	}
+
+	costIndices := make(map[int64]map[string]float64, len(systems))
+	for _, system := range systems {
+		activities := make(map[string]float64, len(system.CostIndices))
+		for _, costIndex := range system.CostIndices {
+			activities[costIndex.Activity] = costIndex.CostIndex
+		}
+		costIndices[system.SolarSystemID] = activities
+	}
+
+	p.costIndicesLock.Lock()
+	p.costIndices = costIndices
+	p.costIndicesLock.Unlock()
+	return nil
+}
+
+// CostIndex returns the cost index of an industry activity in a system as a percentage
+func (p *PriceFetcher) CostIndex(systemID int64, activity string) (float64, bool) {
+	p.costIndicesLock.RLock()
+	defer p.costIndicesLock.RUnlock()
+	costIndex, ok := p.costIndices[systemID][activity]
+	return costIndex * 100, ok
+}
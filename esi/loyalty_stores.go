@@ -0,0 +1,69 @@
+package esi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// loyaltyStoreTimeout is how long fetching a loyalty store can take. Stores are fetched while a page is being made.
+var loyaltyStoreTimeout = 30 * time.Second
+
+// loyaltyStore is the offers of a corporation's loyalty store along with the cache details of the ESI response
+type loyaltyStore struct {
+	page   esiPage
+	offers []evepraisal.LoyaltyOffer
+}
+
+// LoyaltyStoreOffers returns the offers in a corporation's loyalty point store. Stores are fetched from ESI the first
+// time they're asked for and fetched again once ESI says they've expired. The last offers that were fetched are
+// returned if ESI can't be reached.
+func (p *PriceFetcher) LoyaltyStoreOffers(corporationID int64) ([]evepraisal.LoyaltyOffer, error) {
+	p.loyaltyStoresLock.RLock()
+	store, ok := p.loyaltyStores[corporationID]
+	p.loyaltyStoresLock.RUnlock()
+	if ok && !store.page.expired(time.Now()) {
+		return store.offers, nil
+	}
+
+	updated, err := p.fetchLoyaltyStore(corporationID, store)
+	if err != nil {
+		if ok {
+			log.Printf("WARN: using the last loyalty store offers of corporation %d: %s", corporationID, err)
+			return store.offers, nil
+		}
+		return nil, err
+	}
+
+	p.loyaltyStoresLock.Lock()
+	if p.loyaltyStores == nil {
+		p.loyaltyStores = make(map[int64]loyaltyStore)
+	}
+	p.loyaltyStores[corporationID] = updated
+	p.loyaltyStoresLock.Unlock()
+	return updated.offers, nil
+}
+
+// fetchLoyaltyStore fetches the offers of a corporation's loyalty store from ESI. The given store's offers are kept if
+// they haven't changed. Corporations without a store have no offers.
+func (p *PriceFetcher) fetchLoyaltyStore(corporationID int64, store loyaltyStore) (loyaltyStore, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, loyaltyStoreTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/loyalty/stores/%d/offers/?datasource=tranquility", p.baseURL, corporationID)
+	var offers []evepraisal.LoyaltyOffer
+	changed, err := fetchURLIfChanged(ctx, p.client, url, &store.page, &offers)
+	if err != nil {
+		return store, fmt.Errorf("Failed to fetch loyalty store: %s (%s)", err, url)
+	}
+	if changed {
+		store.offers = offers
+	}
+	if store.offers == nil {
+		store.offers = make([]evepraisal.LoyaltyOffer, 0)
+	}
+	return store, nil
+}
@@ -0,0 +1,176 @@
+package esi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+var (
+	// marketHistoryCheckInterval is how often the history worker looks for market history that has expired
+	marketHistoryCheckInterval = 10 * time.Minute
+	// marketHistoryKeepDays is how many days of history are kept for each type
+	marketHistoryKeepDays = 90
+	// marketHistoryBatchSize is how many types are saved to the database at once
+	marketHistoryBatchSize = 100
+	// marketHistoryConcurrency is how many market history requests are made at once
+	marketHistoryConcurrency = 8
+)
+
+// marketHistoryJob is the market history of a type in a region that has expired
+type marketHistoryJob struct {
+	regionID int64
+	typeID   int64
+	history  evepraisal.MarketHistory
+}
+
+// historyRegions returns the regions that market history is fetched for. Only the regions of the hubs are used
+// because fetching history is one request per type.
+func (p *PriceFetcher) historyRegions() []*regionOrders {
+	regionIDs := make(map[int64]bool)
+	for _, market := range p.markets {
+		if market.IsUniverse() {
+			continue
+		}
+		for _, regionID := range market.RegionIDs {
+			regionIDs[regionID] = true
+		}
+	}
+
+	regions := make([]*regionOrders, 0, len(regionIDs))
+	for _, region := range p.regions {
+		if regionIDs[region.regionID] {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+func (p *PriceFetcher) runMarketHistoryLoop() {
+	// History is only fetched for types with orders, so there's nothing to do until the orders are loaded
+	select {
+	case <-p.ordersLoaded:
+	case <-p.stop:
+		return
+	}
+
+	for {
+		err := p.refreshMarketHistory(time.Now())
+		if err != nil && p.ctx.Err() == nil {
+			log.Printf("ERROR: fetching market history: %s", err)
+		}
+
+		select {
+		case <-time.After(marketHistoryCheckInterval):
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// refreshMarketHistory refetches the market history of every type with orders in a hub region whose history has
+// expired, marketHistoryConcurrency requests at a time. ESI only updates history once a day so most passes don't fetch
+// anything. The first error stops the pass.
+func (p *PriceFetcher) refreshMarketHistory(now time.Time) error {
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	jobs := make(chan marketHistoryJob)
+	go func() {
+		defer close(jobs)
+		for _, region := range p.historyRegions() {
+			for _, typeID := range region.typeIDs() {
+				history, _ := p.db.GetMarketHistory(region.regionID, typeID)
+				if now.Before(history.Expires) {
+					continue
+				}
+
+				select {
+				case jobs <- marketHistoryJob{regionID: region.regionID, typeID: typeID, history: history}:
+				case <-ctx.Done():
+					return
+				case <-p.stop:
+					return
+				}
+			}
+		}
+	}()
+
+	var (
+		fetchErr error
+		errOnce  sync.Once
+		wg       = &sync.WaitGroup{}
+		results  = make(chan evepraisal.RegionItemMarketHistory)
+		setErr   = func(err error) {
+			errOnce.Do(func() {
+				fetchErr = err
+				cancel()
+			})
+		}
+	)
+	for i := 0; i < marketHistoryConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				history, err := p.fetchMarketHistory(ctx, job.regionID, job.typeID, job.history)
+				if err != nil {
+					setErr(err)
+					continue
+				}
+				results <- evepraisal.RegionItemMarketHistory{RegionID: job.regionID, TypeID: job.typeID, History: history}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	batch := make([]evepraisal.RegionItemMarketHistory, 0, marketHistoryBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := p.db.UpdateMarketHistory(batch)
+		if err != nil {
+			setErr(fmt.Errorf("saving market history: %s", err))
+		}
+		batch = batch[:0]
+	}
+	for result := range results {
+		batch = append(batch, result)
+		if len(batch) >= marketHistoryBatchSize {
+			flush()
+		}
+	}
+	flush()
+	return fetchErr
+}
+
+// fetchMarketHistory fetches the daily history for a type in a region if it has changed since the given history
+func (p *PriceFetcher) fetchMarketHistory(ctx context.Context, regionID int64, typeID int64, history evepraisal.MarketHistory) (evepraisal.MarketHistory, error) {
+	url := fmt.Sprintf("%s/markets/%d/history/?datasource=tranquility&type_id=%d", p.baseURL, regionID, typeID)
+	page := esiPage{etag: history.ETag}
+	var days []evepraisal.MarketHistoryDay
+	changed, err := fetchURLIfChanged(ctx, p.client, url, &page, &days)
+	if err != nil {
+		return history, fmt.Errorf("Failed to fetch market history: %s (%s)", err, url)
+	}
+
+	history.ETag = page.etag
+	history.Expires = page.expires
+	if changed {
+		sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+		if len(days) > marketHistoryKeepDays {
+			days = days[len(days)-marketHistoryKeepDays:]
+		}
+		history.Days = days
+	}
+	return history, nil
+}
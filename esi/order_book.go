@@ -0,0 +1,41 @@
+package esi
+
+import (
+	"sort"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// maxOrderBookDepth limits how many orders are kept on each side of an order book
+var maxOrderBookDepth = 1000
+
+func getOrderBookForOrders(orders []MarketOrder) evepraisal.OrderBook {
+	var book evepraisal.OrderBook
+	for _, order := range orders {
+		entry := evepraisal.OrderBookEntry{
+			Price:     order.Price,
+			Volume:    order.Volume,
+			MinVolume: order.MinVolume,
+		}
+		if entry.MinVolume <= 1 {
+			entry.MinVolume = 0
+		}
+
+		if order.Buy {
+			book.Buy = append(book.Buy, entry)
+		} else {
+			book.Sell = append(book.Sell, entry)
+		}
+	}
+
+	sort.SliceStable(book.Buy, func(i, j int) bool { return book.Buy[i].Price > book.Buy[j].Price })
+	sort.SliceStable(book.Sell, func(i, j int) bool { return book.Sell[i].Price < book.Sell[j].Price })
+
+	if len(book.Buy) > maxOrderBookDepth {
+		book.Buy = book.Buy[:maxOrderBookDepth]
+	}
+	if len(book.Sell) > maxOrderBookDepth {
+		book.Sell = book.Sell[:maxOrderBookDepth]
+	}
+	return book
+}
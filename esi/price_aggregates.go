@@ -16,8 +16,101 @@ func nanToZero(f float64) float64 {
 	return f
 }
 
-func getPriceAggregatesForOrders(orders []MarketOrder) evepraisal.Prices {
+// OrderFilter describes which orders are ignored when calculating aggregate prices. This is used to keep bait and
+// troll orders from skewing the prices. The zero value doesn't filter anything.
+type OrderFilter struct {
+	// MinVolume ignores orders with less than this many items remaining
+	MinVolume int64 `mapstructure:"min_volume"`
+	// MaxMedianDeviation ignores orders that are more than this percentage away from the median order price. Every order
+	// counts once so that a bait order can't move the median onto itself with a huge volume.
+	MaxMedianDeviation float64 `mapstructure:"max_median_deviation"`
+	// MaxStddevs ignores orders that are more than this many standard deviations away from the volume-weighted mean
+	MaxStddevs float64 `mapstructure:"max_stddevs"`
+}
+
+// filterOrders returns the orders that pass the filter. Buy and sell orders are looked at separately because they
+// have different price distributions. The number of excluded buy and sell orders are also returned.
+func (f OrderFilter) filterOrders(orders []MarketOrder) ([]MarketOrder, int64, int64) {
+	buyOrders := make([]MarketOrder, 0)
+	sellOrders := make([]MarketOrder, 0)
+	for _, order := range orders {
+		if order.Buy {
+			buyOrders = append(buyOrders, order)
+		} else {
+			sellOrders = append(sellOrders, order)
+		}
+	}
+
+	filteredBuy := f.filterSide(buyOrders)
+	filteredSell := f.filterSide(sellOrders)
+	excludedBuy := int64(len(buyOrders) - len(filteredBuy))
+	excludedSell := int64(len(sellOrders) - len(filteredSell))
+	return append(filteredBuy, filteredSell...), excludedBuy, excludedSell
+}
+
+func (f OrderFilter) filterSide(orders []MarketOrder) []MarketOrder {
+	filtered := orders
+	if f.MinVolume > 0 {
+		filtered = keepOrders(filtered, func(order MarketOrder) bool {
+			return order.Volume >= f.MinVolume
+		})
+	}
+
+	if f.MaxMedianDeviation > 0 && len(filtered) > 0 {
+		prices, _ := orderPricesAndWeights(filtered)
+		median := stat.Quantile(0.5, stat.Empirical, prices, nil)
+		maxDelta := median * f.MaxMedianDeviation / 100
+		filtered = keepOrders(filtered, func(order MarketOrder) bool {
+			return math.Abs(order.Price-median) <= maxDelta
+		})
+	}
+
+	if f.MaxStddevs > 0 && len(filtered) > 1 {
+		prices, weights := orderPricesAndWeights(filtered)
+		mean, stddev := stat.MeanStdDev(prices, weights)
+		if !math.IsNaN(stddev) {
+			maxDelta := stddev * f.MaxStddevs
+			filtered = keepOrders(filtered, func(order MarketOrder) bool {
+				return math.Abs(order.Price-mean) <= maxDelta
+			})
+		}
+	}
+
+	// Never throw out everything; a filtered price is only useful if there's something left to price with
+	if len(filtered) == 0 {
+		return orders
+	}
+	return filtered
+}
+
+func keepOrders(orders []MarketOrder, keep func(order MarketOrder) bool) []MarketOrder {
+	kept := make([]MarketOrder, 0, len(orders))
+	for _, order := range orders {
+		if keep(order) {
+			kept = append(kept, order)
+		}
+	}
+	return kept
+}
+
+// orderPricesAndWeights returns the prices of the orders sorted along with their volumes to be used as weights
+func orderPricesAndWeights(orders []MarketOrder) ([]float64, []float64) {
+	prices := make([]float64, len(orders))
+	weights := make([]float64, len(orders))
+	for i, order := range orders {
+		prices[i] = order.Price
+		weights[i] = float64(order.Volume)
+	}
+	stat.SortWeighted(prices, weights)
+	return prices, weights
+}
+
+func getPriceAggregatesForOrders(orders []MarketOrder, filter OrderFilter) evepraisal.Prices {
 	var prices evepraisal.Prices
+	orders, excludedBuy, excludedSell := filter.filterOrders(orders)
+	prices.Buy.ExcludedOrderCount = excludedBuy
+	prices.Sell.ExcludedOrderCount = excludedSell
+	prices.All.ExcludedOrderCount = excludedBuy + excludedSell
 	buyPrices := make([]float64, 0)
 	buyWeights := make([]float64, 0)
 	sellPrices := make([]float64, 0)
@@ -0,0 +1,86 @@
+package esi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSellOrders = []MarketOrder{
+	{Price: 100, Volume: 50},
+	{Price: 101, Volume: 50},
+	{Price: 102, Volume: 50},
+	{Price: 1000, Volume: 1},
+}
+
+var testBuyOrders = []MarketOrder{
+	{Price: 95, Volume: 50, Buy: true},
+	{Price: 96, Volume: 50, Buy: true},
+	{Price: 0.01, Volume: 1000000, Buy: true},
+	{Price: 99, Volume: 1, Buy: true},
+}
+
+func testOrders() []MarketOrder {
+	orders := append([]MarketOrder{}, testSellOrders...)
+	return append(orders, testBuyOrders...)
+}
+
+func TestGetPriceAggregatesForOrdersWithoutFilter(t *testing.T) {
+	prices := getPriceAggregatesForOrders(testOrders(), OrderFilter{})
+	assert.Equal(t, 100.0, prices.Sell.Min)
+	assert.Equal(t, 1000.0, prices.Sell.Max)
+	assert.Equal(t, 99.0, prices.Buy.Max)
+	assert.Equal(t, 0.01, prices.Buy.Min)
+	assert.Equal(t, int64(0), prices.All.ExcludedOrderCount)
+}
+
+func TestGetPriceAggregatesForOrdersMinVolume(t *testing.T) {
+	prices := getPriceAggregatesForOrders(testOrders(), OrderFilter{MinVolume: 2})
+	assert.Equal(t, 102.0, prices.Sell.Max)
+	assert.Equal(t, 96.0, prices.Buy.Max)
+	assert.Equal(t, int64(1), prices.Sell.ExcludedOrderCount)
+	assert.Equal(t, int64(1), prices.Buy.ExcludedOrderCount)
+	assert.Equal(t, int64(2), prices.All.ExcludedOrderCount)
+	assert.Equal(t, int64(3), prices.Sell.OrderCount)
+}
+
+func TestGetPriceAggregatesForOrdersMedianDeviation(t *testing.T) {
+	prices := getPriceAggregatesForOrders(testSellOrders, OrderFilter{MaxMedianDeviation: 50})
+	assert.Equal(t, 102.0, prices.Sell.Max)
+	assert.Equal(t, int64(1), prices.Sell.ExcludedOrderCount)
+
+	prices = getPriceAggregatesForOrders([]MarketOrder{
+		{Price: 95, Volume: 50, Buy: true},
+		{Price: 96, Volume: 50, Buy: true},
+		{Price: 0.01, Volume: 10, Buy: true},
+	}, OrderFilter{MaxMedianDeviation: 50})
+	assert.Equal(t, 95.0, prices.Buy.Min)
+	assert.Equal(t, int64(1), prices.Buy.ExcludedOrderCount)
+
+	// The bait order has most of the volume but is still only one order
+	prices = getPriceAggregatesForOrders([]MarketOrder{
+		{Price: 95, Volume: 50, Buy: true},
+		{Price: 96, Volume: 50, Buy: true},
+		{Price: 0.01, Volume: 1000000, Buy: true},
+	}, OrderFilter{MaxMedianDeviation: 50})
+	assert.Equal(t, 95.0, prices.Buy.Min)
+	assert.Equal(t, 96.0, prices.Buy.Max)
+	assert.Equal(t, int64(100), prices.Buy.Volume)
+	assert.Equal(t, int64(1), prices.Buy.ExcludedOrderCount)
+}
+
+func TestGetPriceAggregatesForOrdersStddevs(t *testing.T) {
+	orders := append([]MarketOrder{}, testSellOrders...)
+	for i := 0; i < 20; i++ {
+		orders = append(orders, MarketOrder{Price: 101, Volume: 1})
+	}
+	prices := getPriceAggregatesForOrders(orders, OrderFilter{MaxStddevs: 3})
+	assert.Equal(t, 102.0, prices.Sell.Max)
+	assert.Equal(t, int64(1), prices.Sell.ExcludedOrderCount)
+}
+
+func TestOrderFilterNeverExcludesEverything(t *testing.T) {
+	prices := getPriceAggregatesForOrders(testSellOrders, OrderFilter{MinVolume: 1000})
+	assert.Equal(t, int64(4), prices.Sell.OrderCount)
+	assert.Equal(t, int64(0), prices.Sell.ExcludedOrderCount)
+}
@@ -0,0 +1,193 @@
+package esi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// retryInterval is how long to wait before trying a region again after it failed to fetch
+var retryInterval = time.Minute
+
+// regionOrders holds every order of a region, page by page, as they were last fetched from ESI. The orders of a
+// player structure's market are kept the same way, with a structureID instead of a regionID.
+type regionOrders struct {
+	regionID    int64
+	structureID int64
+	pages       map[int]*orderPage
+	expires     time.Time
+
+	// byType and fetched are read by other workers so they are only replaced while holding the lock
+	l      sync.RWMutex
+	byType map[int64][]MarketOrder
+	// fetched is when every page was last confirmed to be current, whether or not any of them changed
+	fetched time.Time
+}
+
+type orderPage struct {
+	esiPage
+	orders []MarketOrder
+}
+
+func newRegionOrders(regionID int64) *regionOrders {
+	return &regionOrders{
+		regionID: regionID,
+		pages:    make(map[int]*orderPage),
+		byType:   make(map[int64][]MarketOrder),
+	}
+}
+
+func newStructureOrders(structureID int64) *regionOrders {
+	region := newRegionOrders(0)
+	region.structureID = structureID
+	return region
+}
+
+// refreshRegion refetches every page of the region that has expired. Pages that haven't changed since they were last
+// fetched are skipped. The type IDs of every order that was added, changed or removed are added to changedTypes.
+func (p *PriceFetcher) refreshRegion(region *regionOrders, now time.Time, changedTypes map[int64]bool) error {
+	changed := false
+	defer func() {
+		if changed {
+			region.rebuildByType()
+		}
+	}()
+
+	// The first page is always fetched because it says how many pages there are
+	pageCount := 1
+	for pageNum := 1; pageNum <= pageCount; pageNum++ {
+		page, ok := region.pages[pageNum]
+		if !ok {
+			page = &orderPage{}
+			region.pages[pageNum] = page
+		}
+
+		if pageNum == 1 || page.expired(now) {
+			oldOrders := page.orders
+			pageChanged, err := p.fetchOrderPage(region, pageNum, page)
+			if err != nil {
+				region.expires = now.Add(retryInterval)
+				return err
+			}
+			if pageChanged {
+				changed = true
+				addChangedTypes(changedTypes, oldOrders)
+				addChangedTypes(changedTypes, page.orders)
+			}
+		}
+
+		if pageNum == 1 && page.pages > 1 {
+			pageCount = page.pages
+		}
+	}
+
+	// Remove pages that ESI doesn't have anymore
+	for pageNum, page := range region.pages {
+		if pageNum > pageCount {
+			changed = true
+			addChangedTypes(changedTypes, page.orders)
+			delete(region.pages, pageNum)
+		}
+	}
+
+	region.expires = time.Time{}
+	for _, page := range region.pages {
+		if region.expires.IsZero() || page.expires.Before(region.expires) {
+			region.expires = page.expires
+		}
+	}
+
+	region.l.Lock()
+	region.fetched = now
+	region.l.Unlock()
+	return nil
+}
+
+// fetchOrderPage fetches a single page of orders and replaces the orders on the page if they have changed
+func (p *PriceFetcher) fetchOrderPage(region *regionOrders, pageNum int, page *orderPage) (bool, error) {
+	client := p.client
+	url := fmt.Sprintf("%s/markets/%d/orders/?datasource=tranquility&order_type=all&page=%d", p.baseURL, region.regionID, pageNum)
+	if region.structureID != 0 {
+		client = p.structureClient
+		url = fmt.Sprintf("%s/markets/structures/%d/?datasource=tranquility&page=%d", p.baseURL, region.structureID, pageNum)
+	}
+
+	var orders []MarketOrder
+	changed, err := fetchURLIfChanged(p.ctx, client, url, &page.esiPage, &orders)
+	if err != nil {
+		return false, fmt.Errorf("Failed to fetch market orders: %w (%s)", err, url)
+	}
+	if !changed {
+		return false, nil
+	}
+
+	for i := range orders {
+		orders[i].RegionID = region.regionID
+	}
+	page.orders = orders
+	return true, nil
+}
+
+// dropOrders removes every order, adding their types to changedTypes
+func (region *regionOrders) dropOrders(changedTypes map[int64]bool) {
+	for _, page := range region.pages {
+		addChangedTypes(changedTypes, page.orders)
+	}
+	region.pages = make(map[int]*orderPage)
+	region.rebuildByType()
+}
+
+func addChangedTypes(changedTypes map[int64]bool, orders []MarketOrder) {
+	for _, order := range orders {
+		changedTypes[order.Type] = true
+	}
+}
+
+func (region *regionOrders) rebuildByType() {
+	byType := make(map[int64][]MarketOrder)
+	for _, page := range region.pages {
+		for _, order := range page.orders {
+			byType[order.Type] = append(byType[order.Type], order)
+		}
+	}
+
+	region.l.Lock()
+	region.byType = byType
+	region.l.Unlock()
+}
+
+// ordersForType returns every order for the given type in the region
+func (region *regionOrders) ordersForType(typeID int64) []MarketOrder {
+	region.l.RLock()
+	defer region.l.RUnlock()
+	return region.byType[typeID]
+}
+
+// orderCount returns how many orders the region has
+func (region *regionOrders) orderCount() int {
+	region.l.RLock()
+	defer region.l.RUnlock()
+	count := 0
+	for _, orders := range region.byType {
+		count += len(orders)
+	}
+	return count
+}
+
+// typeIDs returns every type that has orders in the region
+func (region *regionOrders) typeIDs() []int64 {
+	region.l.RLock()
+	defer region.l.RUnlock()
+	typeIDs := make([]int64, 0, len(region.byType))
+	for typeID := range region.byType {
+		typeIDs = append(typeIDs, typeID)
+	}
+	return typeIDs
+}
+
+// lastFetched returns when the region's orders were last fetched. It is zero until the region has been fetched.
+func (region *regionOrders) lastFetched() time.Time {
+	region.l.RLock()
+	defer region.l.RUnlock()
+	return region.fetched
+}
@@ -0,0 +1,61 @@
+package esi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// routeTimeout is how long a route lookup can take. Routes are looked up while an appraisal is being made.
+var routeTimeout = 10 * time.Second
+
+type routeKey struct {
+	origin      int64
+	destination int64
+}
+
+// Jumps returns the number of jumps on the shortest route between two systems. Routes are fetched from ESI the first
+// time they're asked for and kept in memory after that.
+func (p *PriceFetcher) Jumps(origin int64, destination int64) (int64, bool) {
+	if origin == destination {
+		return 0, true
+	}
+
+	key := routeKey{origin: origin, destination: destination}
+	p.routesLock.RLock()
+	jumps, ok := p.routes[key]
+	p.routesLock.RUnlock()
+	if ok {
+		return jumps, jumps >= 0
+	}
+
+	jumps, err := p.fetchJumps(origin, destination)
+	if err != nil {
+		log.Printf("ERROR: fetching route: %s", err)
+		return 0, false
+	}
+
+	p.routesLock.Lock()
+	if p.routes == nil {
+		p.routes = make(map[routeKey]int64)
+	}
+	p.routes[key] = jumps
+	p.routesLock.Unlock()
+	return jumps, jumps >= 0
+}
+
+// fetchJumps fetches the route between two systems from ESI. -1 is returned if there is no route.
+func (p *PriceFetcher) fetchJumps(origin int64, destination int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, routeTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/route/%d/%d/?datasource=tranquility", p.baseURL, origin, destination)
+	var route []int64
+	_, err := fetchURLIfChanged(ctx, p.client, url, &esiPage{}, &route)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to fetch route: %s (%s)", err, url)
+	}
+	// The route includes the origin
+	return int64(len(route)) - 1, nil
+}
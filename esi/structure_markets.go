@@ -0,0 +1,81 @@
+package esi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"golang.org/x/oauth2"
+)
+
+// accessDeniedRetryInterval is how long to wait before trying a structure again after ESI denied access to its market
+var accessDeniedRetryInterval = time.Hour
+
+// structureIDs returns every player structure that one of the markets needs orders from
+func (p *PriceFetcher) structureIDs() []int64 {
+	seen := make(map[int64]bool)
+	structureIDs := make([]int64, 0)
+	for _, market := range p.markets {
+		for _, structureID := range market.StructureIDs {
+			if !seen[structureID] {
+				seen[structureID] = true
+				structureIDs = append(structureIDs, structureID)
+			}
+		}
+	}
+	return structureIDs
+}
+
+// isAccessDenied returns true if the error means that the service character can't see the structure's market, either
+// because ESI refused or because the refresh token was revoked
+func isAccessDenied(err error) bool {
+	var esiErr esiError
+	if errors.As(err, &esiErr) {
+		return esiErr.StatusCode == http.StatusUnauthorized || esiErr.StatusCode == http.StatusForbidden
+	}
+	var tokenErr *oauth2.RetrieveError
+	return errors.As(err, &tokenErr)
+}
+
+// refreshStructure refreshes the orders of a player structure's market and records how it went. The orders are
+// dropped if access to the market was denied so that the structure doesn't keep stale prices.
+func (p *PriceFetcher) refreshStructure(structure *regionOrders, now time.Time, changedTypes map[int64]bool) error {
+	err := p.refreshRegion(structure, now, changedTypes)
+	if isAccessDenied(err) {
+		structure.dropOrders(changedTypes)
+		structure.expires = now.Add(accessDeniedRetryInterval)
+	}
+
+	p.structuresLock.Lock()
+	defer p.structuresLock.Unlock()
+	status, ok := p.structureStatuses[structure.structureID]
+	if !ok {
+		status = &evepraisal.StructureMarketStatus{StructureID: structure.structureID}
+		p.structureStatuses[structure.structureID] = status
+	}
+	if err == nil {
+		status.LastFetched = now
+		status.Error = ""
+		status.AccessDenied = false
+	} else {
+		status.Error = err.Error()
+		status.ErrorTime = now
+		status.AccessDenied = isAccessDenied(err)
+	}
+	status.OrderCount = structure.orderCount()
+	return err
+}
+
+// StructureMarketStatuses returns the state of every player structure market, ordered by structure ID
+func (p *PriceFetcher) StructureMarketStatuses() []evepraisal.StructureMarketStatus {
+	p.structuresLock.RLock()
+	defer p.structuresLock.RUnlock()
+	statuses := make([]evepraisal.StructureMarketStatus, 0, len(p.structureStatuses))
+	for _, status := range p.structureStatuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StructureID < statuses[j].StructureID })
+	return statuses
+}
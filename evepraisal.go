@@ -3,6 +3,7 @@ package evepraisal
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/evepraisal/go-evepraisal/parsers"
 	"github.com/evepraisal/go-evepraisal/typedb"
@@ -14,9 +15,37 @@ type App struct {
 	AppraisalDB         AppraisalDB
 	TypeDB              typedb.TypeDB
 	PriceDB             PriceDB
+	BuybackDB           BuybackDB
 	Parser              parsers.Parser
 	WebContext          WebContext
 	NewRelicApplication *newrelic.Application
+	Markets             []Market
+	// DefaultMarket is the market that is used when none is chosen. The first market is used when this is empty.
+	DefaultMarket string
+	// StalePriceAge is how old prices can be before items are flagged as stale. 0 disables the warning.
+	StalePriceAge time.Duration
+	// Industry is used to value blueprint copies
+	Industry IndustryOptions
+	// PriceFallbacks are the steps that are tried in order to find a price for an item. DefaultPriceFallbacks is used
+	// when this is empty.
+	PriceFallbacks []string
+	// CostIndices are the live system cost indices. The cost index in Industry is used when this is nil.
+	CostIndices CostIndexSource
+	// Jumps is used to limit best price appraisals to nearby hubs. Jumps can't be limited when this is nil.
+	Jumps JumpSource
+	// StructureMarkets reports on the player structure markets that are fetched. May be nil.
+	StructureMarkets StructureMarketSource
+	// OrdersFetched says when the market orders that prices come from were last fetched. Prices are only considered
+	// stale by when they were last updated when this is nil.
+	OrdersFetched OrdersFetchedSource
+	// PriceSheets are the administrator price sheets, which are also served through PriceDB. May be nil.
+	PriceSheets *PriceSheets
+	// FreightServices are the hauling services that appraisals can be quoted for
+	FreightServices []FreightService
+	// Arbitrage lists the price spreads between hubs. May be nil.
+	Arbitrage *ArbitrageFinder
+	// LoyaltyStores provides the offers in NPC loyalty point stores. May be nil.
+	LoyaltyStores LoyaltyStoreSource
 }
 
 // AppraisalDB allows for creating, deleting and retreiving appraisals
@@ -39,10 +68,54 @@ var (
 // PriceDB holds prices for eve online items. Something else should update them
 type PriceDB interface {
 	GetPrice(market string, typeID int64) (Prices, bool)
+	// GetPrices returns the prices of every given type that has prices in the market, keyed by type ID
+	GetPrices(market string, typeIDs []int64) (map[int64]Prices, error)
+	GetPriceHistory(market string, typeID int64, from time.Time, to time.Time) ([]Prices, error)
 	UpdatePrices([]MarketItemPrices) error
+	GetOrderBook(market string, typeID int64) (OrderBook, bool)
+	UpdateOrderBooks([]MarketItemOrderBook) error
+	GetMarketHistory(regionID int64, typeID int64) (MarketHistory, bool)
+	UpdateMarketHistory([]RegionItemMarketHistory) error
 	Close() error
 }
 
+// BuybackDB stores the buyback programs that appraisals can be priced with
+type BuybackDB interface {
+	GetBuybackProgram(name string) (BuybackProgram, error)
+	ListBuybackPrograms() ([]BuybackProgram, error)
+	PutBuybackProgram(program BuybackProgram) error
+	DeleteBuybackProgram(name string) error
+	Close() error
+}
+
+// PriceSheetDB stores the price sheets that administrators upload
+type PriceSheetDB interface {
+	ListPriceSheets() ([]PriceSheet, error)
+	PutPriceSheet(sheet PriceSheet) error
+	DeletePriceSheet(name string) error
+	Close() error
+}
+
+// CostIndexSource provides system cost indices for industry activities, as percentages
+type CostIndexSource interface {
+	CostIndex(systemID int64, activity string) (float64, bool)
+}
+
+// JumpSource gives the number of jumps on the shortest route between two solar systems
+type JumpSource interface {
+	Jumps(origin int64, destination int64) (int64, bool)
+}
+
+// StructureMarketSource reports the state of every player structure market that is fetched
+type StructureMarketSource interface {
+	StructureMarketStatuses() []StructureMarketStatus
+}
+
+// OrdersFetchedSource reports when the market orders of a market were last fetched, even if none of them changed
+type OrdersFetchedSource interface {
+	OrdersFetched(market string) (time.Time, bool)
+}
+
 // TransactionLogger is used to log general events and HTTP requests
 type TransactionLogger interface {
 	StartTransaction(identifier string) Transaction
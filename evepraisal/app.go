@@ -17,6 +17,7 @@ import (
 	"github.com/evepraisal/go-evepraisal/esi"
 	"github.com/evepraisal/go-evepraisal/management"
 	"github.com/evepraisal/go-evepraisal/parsers"
+	"github.com/evepraisal/go-evepraisal/pricesource"
 	"github.com/evepraisal/go-evepraisal/staticdump"
 	"github.com/evepraisal/go-evepraisal/typedb"
 	"github.com/evepraisal/go-evepraisal/web"
@@ -35,7 +36,15 @@ func appMain() {
 	signal.Notify(stop, os.Interrupt)
 
 	log.Println("Starting price DB")
-	priceDB, err := bolt.NewPriceDB(filepath.Join(viper.GetString("db_path"), "prices"))
+	var priceHistoryTiers []bolt.PriceHistoryTier
+	err := viper.UnmarshalKey("price_history", &priceHistoryTiers)
+	if err != nil {
+		log.Fatalf("Couldn't parse price history settings: %s", err)
+	}
+	if len(priceHistoryTiers) == 0 {
+		priceHistoryTiers = bolt.DefaultPriceHistoryTiers
+	}
+	priceDB, err := bolt.NewPriceDB(filepath.Join(viper.GetString("db_path"), "prices"), priceHistoryTiers)
 	if err != nil {
 		log.Fatalf("Couldn't start price database: %s", err)
 	}
@@ -47,6 +56,8 @@ func appMain() {
 		}
 	}()
 
+	priceCache := evepraisal.NewPriceCache(priceDB, viper.GetInt("price_cache_size"))
+
 	httpCache, err := bolt.NewHTTPCache(filepath.Join(viper.GetString("db_path"), "httpcache"))
 	if err != nil {
 		log.Fatalf("Couldn't start httpCache: %s", err)
@@ -68,20 +79,64 @@ func appMain() {
 	httpClient.MaxRetries = 10
 	httpClient.LogHook = func(e pester.ErrEntry) { log.Println(httpClient.FormatError(e)) }
 
-	fetcherCtx, fetcherCancel := context.WithCancel(context.Background())
-	priceFetcher, err := esi.NewPriceFetcher(fetcherCtx, priceDB, viper.GetString("esi_baseurl"), httpClient)
+	var markets []evepraisal.Market
+	err = viper.UnmarshalKey("markets", &markets)
 	if err != nil {
-		log.Fatalf("Couldn't start price fetcher: %s", err)
+		log.Fatalf("Couldn't parse markets: %s", err)
 	}
-	defer func() {
-		log.Println("Stopping price fetcher")
-		fetcherCancel()
-		// TODO: don't wait forever or fix the bug that causes it to hang
-		derr := priceFetcher.Close()
-		if derr != nil {
-			log.Fatalf("Problem closing priceDB: %s", derr)
+	err = evepraisal.CheckMarkets(markets, viper.GetString("default_market"))
+	if err != nil {
+		log.Fatalf("Couldn't parse markets: %s", err)
+	}
+	markets = evepraisal.NormalizeMarkets(markets)
+
+	var orderFilter esi.OrderFilter
+	err = viper.UnmarshalKey("order_filter", &orderFilter)
+	if err != nil {
+		log.Fatalf("Couldn't parse order_filter: %s", err)
+	}
+
+	// Player structure markets are fetched as a service character, using a refresh token that it granted
+	var structureClient *pester.Client
+	if viper.GetString("sso-refresh-token") != "" {
+		oauthConfig := &oauth2.Config{
+			ClientID:     viper.GetString("sso-client-id"),
+			ClientSecret: viper.GetString("sso-client-secret"),
+			Endpoint:     oauth2.Endpoint{TokenURL: viper.GetString("sso-token-url")},
 		}
-	}()
+		tokenSource := oauthConfig.TokenSource(context.Background(), &oauth2.Token{RefreshToken: viper.GetString("sso-refresh-token")})
+
+		structureClient = pester.New()
+		structureClient.Transport = &oauth2.Transport{Source: tokenSource, Base: httpcache.NewTransport(httpCache)}
+		structureClient.Concurrency = 1
+		structureClient.Timeout = 30 * time.Second
+		structureClient.Backoff = pester.ExponentialJitterBackoff
+		structureClient.MaxRetries = 3
+		structureClient.LogHook = func(e pester.ErrEntry) { log.Println(structureClient.FormatError(e)) }
+	}
+
+	var priceSourceConfigs []evepraisal.PriceSourceConfig
+	err = viper.UnmarshalKey("price_sources", &priceSourceConfigs)
+	if err != nil {
+		log.Fatalf("Couldn't parse price_sources: %s", err)
+	}
+	priceSourceConfigs = evepraisal.NormalizePriceSources(priceSourceConfigs)
+	err = evepraisal.CheckPriceSources(priceSourceConfigs, markets)
+	if err != nil {
+		log.Fatalf("Couldn't parse price_sources: %s", err)
+	}
+
+	arbitrage := evepraisal.DefaultArbitrageOptions
+	err = viper.UnmarshalKey("arbitrage", &arbitrage)
+	if err != nil {
+		log.Fatalf("Couldn't parse arbitrage settings: %s", err)
+	}
+	err = evepraisal.CheckArbitrageOptions(arbitrage, markets)
+	if err != nil {
+		log.Fatalf("Couldn't parse arbitrage settings: %s", err)
+	}
+	arbitrageFinder := evepraisal.NewArbitrageFinder(priceCache, evepraisal.NormalizeArbitrageOptions(arbitrage, markets))
+	priceMerger := evepraisal.NewPriceMerger(arbitrageFinder, priceSourceConfigs)
 
 	log.Println("Starting appraisal DB")
 	appraisalDB, err := bolt.NewAppraisalDB(filepath.Join(viper.GetString("db_path"), "appraisals"))
@@ -96,9 +151,123 @@ func appMain() {
 		}
 	}()
 
+	log.Println("Starting buyback DB")
+	buybackDB, err := bolt.NewBuybackDB(filepath.Join(viper.GetString("db_path"), "buyback"))
+	if err != nil {
+		log.Fatalf("Couldn't start buyback database: %s", err)
+	}
+	defer func() {
+		log.Println("Stopping buyback DB")
+		derr := buybackDB.Close()
+		if derr != nil {
+			log.Fatalf("Problem closing buybackDB: %s", derr)
+		}
+	}()
+
+	log.Println("Starting price sheet DB")
+	priceSheetDB, err := bolt.NewPriceSheetDB(filepath.Join(viper.GetString("db_path"), "price_sheets"))
+	if err != nil {
+		log.Fatalf("Couldn't start price sheet database: %s", err)
+	}
+	defer func() {
+		log.Println("Stopping price sheet DB")
+		derr := priceSheetDB.Close()
+		if derr != nil {
+			log.Fatalf("Problem closing priceSheetDB: %s", derr)
+		}
+	}()
+
+	priceSheets, err := evepraisal.NewPriceSheets(priceCache, priceSheetDB)
+	if err != nil {
+		log.Fatalf("Couldn't load price sheets: %s", err)
+	}
+
+	industry := evepraisal.DefaultIndustryOptions
+	err = viper.UnmarshalKey("industry", &industry)
+	if err != nil {
+		log.Fatalf("Couldn't parse industry settings: %s", err)
+	}
+
+	priceFallbacks := viper.GetStringSlice("price_fallbacks")
+	err = evepraisal.CheckPriceFallbacks(priceFallbacks)
+	if err != nil {
+		log.Fatalf("Couldn't parse price_fallbacks: %s", err)
+	}
+
+	var freightServices []evepraisal.FreightService
+	err = viper.UnmarshalKey("freight_services", &freightServices)
+	if err != nil {
+		log.Fatalf("Couldn't parse freight_services: %s", err)
+	}
+	err = evepraisal.CheckFreightServices(freightServices)
+	if err != nil {
+		log.Fatalf("Couldn't parse freight_services: %s", err)
+	}
+
 	app := &evepraisal.App{
-		AppraisalDB: appraisalDB,
-		PriceDB:     priceDB,
+		AppraisalDB:     appraisalDB,
+		PriceDB:         priceSheets,
+		BuybackDB:       buybackDB,
+		PriceSheets:     priceSheets,
+		Markets:         markets,
+		DefaultMarket:   viper.GetString("default_market"),
+		StalePriceAge:   viper.GetDuration("stale_price_age"),
+		Industry:        industry,
+		PriceFallbacks:  priceFallbacks,
+		FreightServices: evepraisal.NormalizeFreightServices(freightServices),
+		Arbitrage:       arbitrageFinder,
+	}
+	arbitrageFinder.Types = func(typeID int64) (typedb.EveType, bool) {
+		if app.TypeDB == nil {
+			return typedb.EveType{}, false
+		}
+		return app.TypeDB.GetTypeByID(typeID)
+	}
+
+	sourcesCtx, sourcesCancel := context.WithCancel(context.Background())
+	defer sourcesCancel()
+	for _, config := range priceSourceConfigs {
+		var source evepraisal.PriceSource
+		switch config.Type {
+		case evepraisal.PriceSourceESIOrders:
+			priceFetcher, err := esi.NewPriceFetcher(sourcesCtx, markets, orderFilter, viper.GetString("esi_baseurl"), httpClient, structureClient)
+			if err != nil {
+				log.Fatalf("Couldn't start price fetcher: %s", err)
+			}
+			app.CostIndices = priceFetcher
+			app.Jumps = priceFetcher
+			app.StructureMarkets = priceFetcher
+			app.OrdersFetched = priceFetcher
+			app.LoyaltyStores = priceFetcher
+			source = priceFetcher
+		case evepraisal.PriceSourceESICCP:
+			// CCP's price only stands in for a market's orders when the source is limited to that market
+			source = esi.NewCCPPriceFetcher(sourcesCtx, config.Markets, viper.GetString("esi_baseurl"), httpClient)
+		case evepraisal.PriceSourceStatic:
+			source = pricesource.NewStaticSource(sourcesCtx, config.Path, config.MarketNames(markets), config.Interval)
+		case evepraisal.PriceSourceJSONHTTP:
+			jsonSource := pricesource.NewJSONHTTPSource(sourcesCtx, config.URL, config.MarketNames(markets), config.Interval, httpClient)
+			jsonSource.TypeIDs = func() []int64 { return marketTypeIDs(app) }
+			source = jsonSource
+		}
+
+		log.Printf("Starting price source %s", config.Name)
+		sourceDB, err := priceMerger.SourceDB(config.Name)
+		if err != nil {
+			log.Fatalf("Couldn't start price source %s: %s", config.Name, err)
+		}
+		err = source.Start(sourceDB)
+		if err != nil {
+			log.Fatalf("Couldn't start price source %s: %s", config.Name, err)
+		}
+		name := config.Name
+		defer func() {
+			log.Printf("Stopping price source %s", name)
+			derr := source.Close()
+			if derr != nil {
+				log.Fatalf("Problem closing price source %s: %s", name, derr)
+			}
+		}()
 	}
 
 	log.Println("Starting type fetcher")
@@ -347,3 +516,28 @@ func NewRoundTripper(newrelicApp *newrelic.Application, original http.RoundTripp
 type roundTripperFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// marketTypeIDs returns the ID of every type that can be bought and sold on the market
+func marketTypeIDs(app *evepraisal.App) []int64 {
+	if app.TypeDB == nil {
+		return nil
+	}
+	typeIDs := make([]int64, 0)
+	var last int64
+	for {
+		types, err := app.TypeDB.ListTypes(last, 1000)
+		if err != nil {
+			log.Printf("ERROR: listing types: %s", err)
+			return typeIDs
+		}
+		if len(types) == 0 {
+			return typeIDs
+		}
+		for _, t := range types {
+			if t.MarketGroupID != 0 {
+				typeIDs = append(typeIDs, t.ID)
+			}
+		}
+		last = types[len(types)-1].ID
+	}
+}
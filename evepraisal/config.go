@@ -1,6 +1,9 @@
 package main
 
-import "github.com/spf13/viper"
+import (
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/spf13/viper"
+)
 
 func init() {
 	viper.SetDefault("base-url", "http://127.0.0.1:8080")
@@ -12,6 +15,10 @@ func init() {
 	viper.SetDefault("db_path", "db/")
 	viper.SetDefault("backup_path", "db/backups/")
 	viper.SetDefault("esi_baseurl", "https://esi.evetech.net/latest")
+	viper.SetDefault("stale_price_age", "2h")
+	viper.SetDefault("default_market", "")
+	viper.SetDefault("price_cache_size", evepraisal.DefaultPriceCacheSize)
+	viper.SetDefault("price_fallbacks", []string{"orders", "universe", "ccp", "components", "base_price"})
 	viper.SetDefault("newrelic_app-name", "Evepraisal")
 	viper.SetDefault("newrelic_license-key", "")
 	viper.SetDefault("management_addr", "127.0.0.1:8090")
@@ -0,0 +1,173 @@
+package evepraisal
+
+import (
+	"fmt"
+	"math"
+)
+
+// FreightService is a hauling service that quotes a reward from the volume and the collateral of a contract. The
+// reward is never less than MinReward.
+type FreightService struct {
+	Name        string  `mapstructure:"name" json:"name"`
+	DisplayName string  `mapstructure:"display_name" json:"display_name"`
+	RatePerM3   float64 `mapstructure:"rate_per_m3" json:"rate_per_m3"`
+	// CollateralPercentage is the part of the collateral that is added to the reward
+	CollateralPercentage float64 `mapstructure:"collateral_percentage" json:"collateral_percentage"`
+	MinReward            float64 `mapstructure:"min_reward" json:"min_reward"`
+	// ShipClasses are the ships that the service flies, smallest first. A haul goes in the first one that it fits in.
+	// There are no caps when this is empty.
+	ShipClasses []FreightShipClass `mapstructure:"ship_classes" json:"ship_classes,omitempty"`
+}
+
+// FreightShipClass is a kind of ship that a freight service flies and the most that it takes in one contract. A cap
+// of 0 means that there isn't one.
+type FreightShipClass struct {
+	Name          string  `mapstructure:"name" json:"name"`
+	MaxVolume     float64 `mapstructure:"max_volume" json:"max_volume,omitempty"`
+	MaxCollateral float64 `mapstructure:"max_collateral" json:"max_collateral,omitempty"`
+}
+
+// Fits returns true if a haul with the given volume and collateral fits in the ship class
+func (class FreightShipClass) Fits(volume float64, collateral float64) bool {
+	if class.MaxVolume > 0 && volume > class.MaxVolume {
+		return false
+	}
+	if class.MaxCollateral > 0 && collateral > class.MaxCollateral {
+		return false
+	}
+	return true
+}
+
+// CheckFreightServices returns an error if any of the freight services can't be used
+func CheckFreightServices(services []FreightService) error {
+	seen := make(map[string]bool, len(services))
+	for _, service := range services {
+		if !managedNameRe.MatchString(service.Name) {
+			return fmt.Errorf("freight service %q: name must be 1-64 letters, numbers, dashes or underscores", service.Name)
+		}
+		if seen[service.Name] {
+			return fmt.Errorf("freight service %q is listed more than once", service.Name)
+		}
+		seen[service.Name] = true
+		if service.RatePerM3 < 0 || service.MinReward < 0 {
+			return fmt.Errorf("freight service %q: rate_per_m3 and min_reward can't be negative", service.Name)
+		}
+		if service.CollateralPercentage < 0 || service.CollateralPercentage > 100 {
+			return fmt.Errorf("freight service %q: collateral_percentage must be between 0 and 100", service.Name)
+		}
+		for _, class := range service.ShipClasses {
+			if class.MaxVolume < 0 || class.MaxCollateral < 0 {
+				return fmt.Errorf("freight service %q: the caps of ship class %q can't be negative", service.Name, class.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeFreightServices fills in defaults for the given freight services
+func NormalizeFreightServices(services []FreightService) []FreightService {
+	normalized := make([]FreightService, 0, len(services))
+	for _, service := range services {
+		if service.DisplayName == "" {
+			service.DisplayName = service.Name
+		}
+		normalized = append(normalized, service)
+	}
+	return normalized
+}
+
+// FreightQuote is what a freight service charges to haul an appraisal's items. ShipClass is empty when the haul is
+// too big for every ship class that the service flies.
+type FreightQuote struct {
+	Service     string  `json:"service"`
+	DisplayName string  `json:"display_name"`
+	Reward      float64 `json:"reward"`
+	ShipClass   string  `json:"ship_class,omitempty"`
+	ExceedsCaps bool    `json:"exceeds_caps"`
+	// NetValue is the value of the items at the destination after the reward is paid. Gain is how much more that is
+	// than the value of the items where they are.
+	NetValue float64 `json:"net_value"`
+	Gain     float64 `json:"gain"`
+}
+
+// Quote returns what the service charges to haul the given volume with the given collateral
+func (service FreightService) Quote(volume float64, collateral float64) FreightQuote {
+	quote := FreightQuote{
+		Service:     service.Name,
+		DisplayName: service.DisplayName,
+		Reward:      math.Max(service.MinReward, volume*service.RatePerM3+collateral*service.CollateralPercentage/100),
+	}
+	for _, class := range service.ShipClasses {
+		if class.Fits(volume, collateral) {
+			quote.ShipClass = class.Name
+			break
+		}
+	}
+	quote.ExceedsCaps = len(service.ShipClasses) > 0 && quote.ShipClass == ""
+	return quote
+}
+
+// FreightOptions describe where an appraisal's items are hauled to. The appraisal's market is used when Destination
+// is empty.
+type FreightOptions struct {
+	Destination string `json:"destination,omitempty"`
+}
+
+// Freight is what every freight service charges to haul an appraisal's items to a destination market. The collateral
+// is the sell value of the items in the appraisal's market.
+type Freight struct {
+	Destination string  `json:"destination"`
+	Volume      float64 `json:"volume"`
+	Collateral  float64 `json:"collateral"`
+	// OriginTotals and DestinationTotals are the values of the items in the appraisal's market and in the destination
+	// market. Both are worked out from the market prices of the items with the appraisal's pricing policy, so buyback,
+	// best price, depth and reprocessing values in the appraisal's totals don't skew the comparison.
+	OriginTotals      Totals         `json:"origin_totals"`
+	DestinationTotals Totals         `json:"destination_totals"`
+	Quotes            []FreightQuote `json:"quotes"`
+}
+
+// QuoteFreight adds freight quotes from every freight service to the appraisal
+func (app *App) QuoteFreight(appraisal *Appraisal, options FreightOptions) {
+	freight := &Freight{
+		Destination: options.Destination,
+		Volume:      appraisal.Totals.Volume,
+		Quotes:      make([]FreightQuote, 0, len(app.FreightServices)),
+	}
+	if freight.Destination == "" {
+		freight.Destination = appraisal.MarketName
+	}
+
+	freight.OriginTotals = app.marketTotals(appraisal, appraisal.MarketName)
+	freight.DestinationTotals = freight.OriginTotals
+	if freight.Destination != appraisal.MarketName {
+		freight.DestinationTotals = app.marketTotals(appraisal, freight.Destination)
+	}
+	freight.Collateral = freight.OriginTotals.Sell
+
+	for _, service := range app.FreightServices {
+		quote := service.Quote(freight.Volume, freight.Collateral)
+		quote.NetValue = freight.DestinationTotals.Sell - quote.Reward
+		quote.Gain = quote.NetValue - freight.OriginTotals.Sell
+		freight.Quotes = append(freight.Quotes, quote)
+	}
+	appraisal.Freight = freight
+}
+
+// marketTotals returns the value of an appraisal's items in the given market using the appraisal's pricing policy
+func (app *App) marketTotals(appraisal *Appraisal, market string) Totals {
+	totals := Totals{Volume: appraisal.Totals.Volume}
+	policy := appraisal.PricingPolicy()
+	for _, item := range appraisal.Items {
+		if item.TypeID == 0 {
+			continue
+		}
+		prices, err := app.pricesInMarket(appraisal, market, item)
+		if err != nil {
+			continue
+		}
+		totals.Buy += policy.Buy(prices) * float64(item.Quantity)
+		totals.Sell += policy.Sell(prices) * float64(item.Quantity)
+	}
+	return totals
+}
@@ -0,0 +1,98 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFreightService = FreightService{
+	Name:                 "pushx",
+	DisplayName:          "PushX",
+	RatePerM3:            300,
+	CollateralPercentage: 1,
+	MinReward:            5000000,
+	ShipClasses: []FreightShipClass{
+		{Name: "Blockade Runner", MaxVolume: 12500, MaxCollateral: 1000000000},
+		{Name: "Freighter", MaxVolume: 845000, MaxCollateral: 10000000000},
+	},
+}
+
+func TestFreightQuote(t *testing.T) {
+	quote := testFreightService.Quote(10000, 500000000)
+	assert.InDelta(t, 8000000, quote.Reward, 0.001)
+	assert.Equal(t, "Blockade Runner", quote.ShipClass)
+	assert.False(t, quote.ExceedsCaps)
+
+	// Small hauls pay the minimum reward
+	quote = testFreightService.Quote(10, 1000000)
+	assert.InDelta(t, 5000000, quote.Reward, 0.001)
+
+	// Too much collateral for a blockade runner
+	quote = testFreightService.Quote(10000, 2000000000)
+	assert.Equal(t, "Freighter", quote.ShipClass)
+
+	quote = testFreightService.Quote(900000, 1000000)
+	assert.Equal(t, "", quote.ShipClass)
+	assert.True(t, quote.ExceedsCaps)
+
+	// Services without ship classes don't have caps
+	quote = FreightService{Name: "anything", RatePerM3: 1}.Quote(900000, 1000000)
+	assert.False(t, quote.ExceedsCaps)
+}
+
+func TestCheckFreightServices(t *testing.T) {
+	assert.NoError(t, CheckFreightServices([]FreightService{testFreightService}))
+	for _, services := range [][]FreightService{
+		{{Name: ""}},
+		{{Name: "pushx"}, {Name: "pushx"}},
+		{{Name: "pushx", RatePerM3: -1}},
+		{{Name: "pushx", CollateralPercentage: 101}},
+		{{Name: "pushx", ShipClasses: []FreightShipClass{{Name: "DST", MaxVolume: -1}}}},
+	} {
+		assert.Error(t, CheckFreightServices(services), "%v", services)
+	}
+	assert.Equal(t, "pushx", NormalizeFreightServices([]FreightService{{Name: "pushx"}})[0].DisplayName)
+}
+
+func TestQuoteFreight(t *testing.T) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{34: {ID: 34}}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+			"amarr": {34: Prices{Strategy: "orders"}.Set(6)},
+		}},
+		FreightServices: []FreightService{testFreightService},
+	}
+	appraisal := &Appraisal{
+		MarketName: "jita",
+		Items: []AppraisalItem{
+			{TypeID: 34, Quantity: 100000000, Prices: Prices{Buy: PriceStats{Max: 4.5}, Sell: PriceStats{Min: 5}}},
+			{Name: "Unknown", Quantity: 1},
+		},
+		// A buyback total isn't what the items are worth in the market
+		Totals: Totals{Buy: 400000000, Sell: 400000000, Volume: 1000000},
+	}
+
+	app.QuoteFreight(appraisal, FreightOptions{Destination: "amarr"})
+	freight := appraisal.Freight
+	assert.Equal(t, "amarr", freight.Destination)
+	assert.InDelta(t, 500000000, freight.Collateral, 0.001)
+	assert.InDelta(t, 450000000, freight.OriginTotals.Buy, 0.001)
+	assert.InDelta(t, 500000000, freight.OriginTotals.Sell, 0.001)
+	assert.InDelta(t, 600000000, freight.DestinationTotals.Sell, 0.001)
+	assert.Len(t, freight.Quotes, 1)
+
+	quote := freight.Quotes[0]
+	// 1,000,000 m3 at 300 ISK plus 1% of the collateral
+	assert.InDelta(t, 305000000, quote.Reward, 0.001)
+	assert.True(t, quote.ExceedsCaps)
+	assert.InDelta(t, 295000000, quote.NetValue, 0.001)
+	assert.InDelta(t, -205000000, quote.Gain, 0.001)
+
+	// The appraisal's market is the destination by default
+	app.QuoteFreight(appraisal, FreightOptions{})
+	assert.Equal(t, "jita", appraisal.Freight.Destination)
+	assert.Equal(t, appraisal.Freight.OriginTotals, appraisal.Freight.DestinationTotals)
+	assert.InDelta(t, -appraisal.Freight.Quotes[0].Reward, appraisal.Freight.Quotes[0].Gain, 0.001)
+}
@@ -0,0 +1,314 @@
+package evepraisal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// What a haul plan loads the cargo for
+const (
+	// HaulGoalValue loads the items that are worth the most in the market that they're hauled from
+	HaulGoalValue = "value"
+	// HaulGoalProfit loads the items that sell for the most over their price in the market that they're hauled from
+	HaulGoalProfit = "profit"
+)
+
+// haulVolumeSlack keeps rounding errors in the volumes from leaving out a unit that exactly fills the cargo
+const haulVolumeSlack = 1e-6
+
+// MaxHaulCapacity is the largest cargo, in m3, that a haul can be planned for. It is well above the cargo of any ship.
+const MaxHaulCapacity = 10000000
+
+// HaulOptions describe the ship that an appraisal's items are hauled with. Profit is planned when To is set,
+// otherwise the value that is moved is. The appraisal's market is used when From is empty.
+type HaulOptions struct {
+	Capacity float64 `json:"capacity"`
+	From     string  `json:"from"`
+	To       string  `json:"to,omitempty"`
+}
+
+// Validate returns an error if the options can't be used
+func (o HaulOptions) Validate() error {
+	if o.Capacity <= 0 || math.IsInf(o.Capacity, 0) || math.IsNaN(o.Capacity) {
+		return fmt.Errorf("capacity must be more than 0")
+	}
+	if o.Capacity > MaxHaulCapacity {
+		return fmt.Errorf("capacity can't be more than %d", MaxHaulCapacity)
+	}
+	if o.To != "" && o.To == o.From {
+		return fmt.Errorf("to must be a different market than from")
+	}
+	return nil
+}
+
+// HaulItem is an item that a haul plan loads. Value is for the whole quantity.
+type HaulItem struct {
+	TypeID   int64   `json:"typeID"`
+	TypeName string  `json:"typeName"`
+	Quantity int64   `json:"quantity"`
+	Volume   float64 `json:"volume"`
+	Value    float64 `json:"value"`
+}
+
+// HaulPlan is what to load to move the most value, or make the most profit, with a ship's cargo. Value is the total
+// that the plan is made for: the value in From or the profit of selling in To.
+type HaulPlan struct {
+	Goal     string     `json:"goal"`
+	Capacity float64    `json:"capacity"`
+	From     string     `json:"from"`
+	To       string     `json:"to,omitempty"`
+	Volume   float64    `json:"volume"`
+	Value    float64    `json:"value"`
+	Items    []HaulItem `json:"items"`
+}
+
+// Multibuy returns the items of the plan in the "name quantity" form that the multibuy window takes
+func (plan HaulPlan) Multibuy() string {
+	var b strings.Builder
+	for _, item := range plan.Items {
+		fmt.Fprintf(&b, "%s\t%d\n", item.TypeName, item.Quantity)
+	}
+	return b.String()
+}
+
+type haulCandidate struct {
+	item  AppraisalItem
+	value float64
+}
+
+// PlanHaul chooses the items of an appraisal, and how many of each, that fit in the cargo and are worth the most. Items
+// that aren't worth anything (or that don't make a profit) are left behind.
+func (app *App) PlanHaul(appraisal *Appraisal, options HaulOptions) HaulPlan {
+	if options.From == "" {
+		options.From = appraisal.MarketName
+	}
+	plan := HaulPlan{
+		Goal:     HaulGoalValue,
+		Capacity: options.Capacity,
+		From:     options.From,
+		To:       options.To,
+		Items:    make([]HaulItem, 0),
+	}
+	if options.To != "" {
+		plan.Goal = HaulGoalProfit
+	}
+
+	policy := appraisal.PricingPolicy()
+	candidates := make([]haulCandidate, 0, len(appraisal.Items))
+	for _, item := range appraisal.Items {
+		if item.TypeID == 0 || item.Quantity <= 0 {
+			continue
+		}
+		prices, err := app.pricesInMarket(appraisal, options.From, item)
+		if err != nil {
+			continue
+		}
+		value := policy.Sell(prices)
+		if options.To != "" {
+			toPrices, err := app.pricesInMarket(appraisal, options.To, item)
+			if err != nil {
+				continue
+			}
+			value = policy.Sell(toPrices) - value
+		}
+		if value <= 0 {
+			continue
+		}
+		candidates = append(candidates, haulCandidate{item: item, value: value})
+	}
+
+	// The plan lists the items that are worth the most per m3 first. Items without volume always fit so they go first.
+	density := func(c haulCandidate) float64 {
+		if c.item.TypeVolume <= 0 {
+			return math.Inf(1)
+		}
+		return c.value / c.item.TypeVolume
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := density(candidates[i]), density(candidates[j])
+		if di != dj {
+			return di > dj
+		}
+		return candidates[i].item.TypeID < candidates[j].item.TypeID
+	})
+
+	for i, quantity := range loadHaul(candidates, options.Capacity) {
+		if quantity <= 0 {
+			continue
+		}
+		c := candidates[i]
+		volume := c.item.TypeVolume * float64(quantity)
+		plan.Volume += volume
+		plan.Value += c.value * float64(quantity)
+		plan.Items = append(plan.Items, HaulItem{
+			TypeID:   c.item.TypeID,
+			TypeName: c.item.TypeName,
+			Quantity: quantity,
+			Volume:   volume,
+			Value:    c.value * float64(quantity),
+		})
+	}
+	return plan
+}
+
+// haulVolumeUnit is the resolution, in m3, that volumes are rounded up to when a load is worked out exactly
+const haulVolumeUnit = 0.01
+
+// haulMaxCells limits how much work (item groups times volume units) an exact load can take. Larger loads are only
+// approximated.
+var haulMaxCells int64 = 20000000
+
+// loadHaul returns how many of each candidate to load. This is a bounded knapsack, which is solved exactly when it is
+// small enough. Otherwise the better of loading the items that are worth the most per m3 first and filling the cargo
+// with a single type is used, which is never worse than half of the best load.
+func loadHaul(candidates []haulCandidate, capacity float64) []int64 {
+	if load, ok := loadHaulExactly(candidates, capacity); ok {
+		return load
+	}
+	greedy := loadHaulGreedily(candidates, capacity)
+	single := loadHaulSingleType(candidates, capacity)
+	if haulLoadValue(candidates, single) > haulLoadValue(candidates, greedy) {
+		return single
+	}
+	return greedy
+}
+
+// haulFits returns how many units of the item fit in the given volume, up to the quantity in the appraisal
+func haulFits(item AppraisalItem, volume float64) int64 {
+	if item.TypeVolume <= 0 {
+		return item.Quantity
+	}
+	fits := int64(math.Floor((volume + haulVolumeSlack) / item.TypeVolume))
+	if fits < item.Quantity {
+		return fits
+	}
+	return item.Quantity
+}
+
+func haulLoadValue(candidates []haulCandidate, load []int64) float64 {
+	var value float64
+	for i, quantity := range load {
+		value += candidates[i].value * float64(quantity)
+	}
+	return value
+}
+
+// loadHaulGreedily loads the candidates in order, as many of each as still fit
+func loadHaulGreedily(candidates []haulCandidate, capacity float64) []int64 {
+	load := make([]int64, len(candidates))
+	remaining := capacity
+	for i, c := range candidates {
+		load[i] = haulFits(c.item, remaining)
+		remaining -= c.item.TypeVolume * float64(load[i])
+	}
+	return load
+}
+
+// loadHaulSingleType fills the cargo with the one type that is worth the most on its own, plus everything that takes
+// no space
+func loadHaulSingleType(candidates []haulCandidate, capacity float64) []int64 {
+	load := make([]int64, len(candidates))
+	best, bestValue := -1, 0.0
+	for i, c := range candidates {
+		if c.item.TypeVolume <= 0 {
+			load[i] = c.item.Quantity
+			continue
+		}
+		value := c.value * float64(haulFits(c.item, capacity))
+		if value > bestValue {
+			best, bestValue = i, value
+		}
+	}
+	if best >= 0 {
+		load[best] = haulFits(candidates[best].item, capacity)
+	}
+	return load
+}
+
+// haulGroup is a number of units of a candidate that are loaded together. Splitting a quantity into groups of 1, 2, 4,
+// ... units (and the rest) lets any quantity up to it be loaded while treating each group as a single item.
+type haulGroup struct {
+	candidate int
+	quantity  int64
+	units     int64
+	value     float64
+}
+
+// loadHaulExactly solves the knapsack with dynamic programming over the cargo in haulVolumeUnit steps. Volumes are
+// rounded up so that the load always fits. Returns false when that would be too much work.
+func loadHaulExactly(candidates []haulCandidate, capacity float64) ([]int64, bool) {
+	if capacity/haulVolumeUnit > float64(haulMaxCells) {
+		return nil, false
+	}
+	load := make([]int64, len(candidates))
+	cells := int64(math.Floor(capacity/haulVolumeUnit + haulVolumeSlack))
+
+	groups := make([]haulGroup, 0)
+	for i, c := range candidates {
+		if c.item.TypeVolume <= 0 {
+			load[i] = c.item.Quantity
+			continue
+		}
+		units := int64(math.Ceil(c.item.TypeVolume/haulVolumeUnit - haulVolumeSlack))
+		if units > cells {
+			continue
+		}
+		remaining := c.item.Quantity
+		if fits := cells / units; fits < remaining {
+			remaining = fits
+		}
+		for size := int64(1); remaining > 0; size *= 2 {
+			if size > remaining {
+				size = remaining
+			}
+			groups = append(groups, haulGroup{candidate: i, quantity: size, units: size * units, value: c.value * float64(size)})
+			remaining -= size
+		}
+	}
+	if len(groups) == 0 {
+		return load, true
+	}
+	if int64(len(groups))*(cells+1) > haulMaxCells {
+		return nil, false
+	}
+
+	// best[v] is the most that fits in v units. taken[g] marks the volumes where group g is part of the best load.
+	best := make([]float64, cells+1)
+	taken := make([][]uint64, len(groups))
+	for g, group := range groups {
+		taken[g] = make([]uint64, cells/64+1)
+		for v := cells; v >= group.units; v-- {
+			if value := best[v-group.units] + group.value; value > best[v] {
+				best[v] = value
+				taken[g][v/64] |= 1 << uint(v%64)
+			}
+		}
+	}
+
+	v := cells
+	for g := len(groups) - 1; g >= 0; g-- {
+		if taken[g][v/64]&(1<<uint(v%64)) != 0 {
+			load[groups[g].candidate] += groups[g].quantity
+			v -= groups[g].units
+		}
+	}
+	return load, true
+}
+
+// pricesInMarket returns the prices of an appraisal item in the given market, with the appraisal's price percentage
+// applied. The item's own prices are used for the appraisal's market.
+func (app *App) pricesInMarket(appraisal *Appraisal, market string, item AppraisalItem) (Prices, error) {
+	if market == appraisal.MarketName {
+		return item.Prices, nil
+	}
+	prices, err := app.PricesForItem(market, item)
+	if err != nil {
+		return prices, err
+	}
+	if appraisal.PricePercentage > 0 {
+		prices = prices.Mul(appraisal.PricePercentage / 100)
+	}
+	return prices, nil
+}
@@ -0,0 +1,119 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHaulApp() (*App, *Appraisal) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			34: {ID: 34}, 35: {ID: 35}, 36: {ID: 36}, 37: {ID: 37},
+		}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+			"amarr": {
+				34: Prices{Strategy: "orders"}.Set(5),
+				35: Prices{Strategy: "orders"}.Set(900),
+				36: Prices{Strategy: "orders"}.Set(1450),
+				37: Prices{Strategy: "orders"}.Set(100),
+			},
+		}},
+	}
+	appraisal := &Appraisal{
+		MarketName: "jita",
+		Items: []AppraisalItem{
+			// 400 ISK/m3
+			{TypeID: 34, TypeName: "Tritanium", TypeVolume: 0.01, Quantity: 1000, Prices: Prices{}.Set(4)},
+			// 100 ISK/m3
+			{TypeID: 35, TypeName: "Cargo Container", TypeVolume: 10, Quantity: 5, Prices: Prices{}.Set(1000)},
+			// 200 ISK/m3
+			{TypeID: 36, TypeName: "Drone", TypeVolume: 5, Quantity: 3, Prices: Prices{}.Set(1000)},
+			// Takes no space
+			{TypeID: 37, TypeName: "Skin", TypeVolume: 0, Quantity: 2, Prices: Prices{}.Set(50)},
+			{Name: "Unknown", Quantity: 1},
+		},
+	}
+	return app, appraisal
+}
+
+func TestPlanHaulValue(t *testing.T) {
+	app, appraisal := testHaulApp()
+	plan := app.PlanHaul(appraisal, HaulOptions{Capacity: 35})
+	assert.Equal(t, HaulGoalValue, plan.Goal)
+	assert.Equal(t, "jita", plan.From)
+
+	// Only one of the cargo containers still fits. The densest items are listed first.
+	assert.Equal(t, []HaulItem{
+		{TypeID: 37, TypeName: "Skin", Quantity: 2, Volume: 0, Value: 100},
+		{TypeID: 34, TypeName: "Tritanium", Quantity: 1000, Volume: 10, Value: 4000},
+		{TypeID: 36, TypeName: "Drone", Quantity: 3, Volume: 15, Value: 3000},
+		{TypeID: 35, TypeName: "Cargo Container", Quantity: 1, Volume: 10, Value: 1000},
+	}, plan.Items)
+	assert.InDelta(t, 35, plan.Volume, 0.0001)
+	assert.InDelta(t, 8100, plan.Value, 0.0001)
+	assert.Equal(t, "Skin\t2\nTritanium\t1000\nDrone\t3\nCargo Container\t1\n", plan.Multibuy())
+}
+
+func TestPlanHaulProfit(t *testing.T) {
+	app, appraisal := testHaulApp()
+	plan := app.PlanHaul(appraisal, HaulOptions{Capacity: 12, To: "amarr"})
+	assert.Equal(t, HaulGoalProfit, plan.Goal)
+
+	// Tritanium makes 100 ISK/m3, drones 90 ISK/m3 and the skins make 50 ISK each without taking any space. Cargo
+	// containers lose money in amarr. Loading all of the tritanium first would leave 2 m3 empty, so a drone is worth
+	// more than the last of it.
+	assert.Equal(t, []HaulItem{
+		{TypeID: 37, TypeName: "Skin", Quantity: 2, Volume: 0, Value: 100},
+		{TypeID: 34, TypeName: "Tritanium", Quantity: 700, Volume: 7, Value: 700},
+		{TypeID: 36, TypeName: "Drone", Quantity: 1, Volume: 5, Value: 450},
+	}, plan.Items)
+	assert.InDelta(t, 12, plan.Volume, 0.0001)
+	assert.InDelta(t, 1250, plan.Value, 0.0001)
+}
+
+func TestPlanHaulLeftoverSpace(t *testing.T) {
+	app := &App{}
+	appraisal := &Appraisal{
+		MarketName: "jita",
+		Items: []AppraisalItem{
+			{TypeID: 1, TypeName: "A", TypeVolume: 1, Quantity: 1, Prices: Prices{}.Set(2)},
+			{TypeID: 2, TypeName: "B", TypeVolume: 10, Quantity: 1, Prices: Prices{}.Set(10)},
+		},
+	}
+
+	// A is worth more per m3 but B is worth more in the cargo
+	plan := app.PlanHaul(appraisal, HaulOptions{Capacity: 10})
+	assert.Equal(t, []HaulItem{{TypeID: 2, TypeName: "B", Quantity: 1, Volume: 10, Value: 10}}, plan.Items)
+
+	// Loads that are too big to work out exactly still do as well as the best single type
+	defer func(cells int64) { haulMaxCells = cells }(haulMaxCells)
+	haulMaxCells = 0
+	plan = app.PlanHaul(appraisal, HaulOptions{Capacity: 10})
+	assert.Equal(t, []HaulItem{{TypeID: 2, TypeName: "B", Quantity: 1, Volume: 10, Value: 10}}, plan.Items)
+	plan = app.PlanHaul(appraisal, HaulOptions{Capacity: 11})
+	assert.InDelta(t, 12, plan.Value, 0.0001)
+}
+
+func TestHaulOptionsValidate(t *testing.T) {
+	assert.NoError(t, HaulOptions{Capacity: 1}.Validate())
+	assert.Error(t, HaulOptions{}.Validate())
+	assert.Error(t, HaulOptions{Capacity: -5}.Validate())
+	assert.NoError(t, HaulOptions{Capacity: MaxHaulCapacity}.Validate())
+	assert.Error(t, HaulOptions{Capacity: 1e10}.Validate())
+	assert.Error(t, HaulOptions{Capacity: 1, From: "jita", To: "jita"}.Validate())
+}
+
+func TestPlanHaulHugeCapacity(t *testing.T) {
+	// The cargo isn't split into volume units when there is nothing to load
+	plan := (&App{}).PlanHaul(&Appraisal{MarketName: "jita"}, HaulOptions{Capacity: 5e7})
+	assert.Empty(t, plan.Items)
+	assert.Equal(t, 0.0, plan.Value)
+
+	// Or when it would take too long, and everything fits anyway
+	app, appraisal := testHaulApp()
+	plan = app.PlanHaul(appraisal, HaulOptions{Capacity: 1e12})
+	assert.Len(t, plan.Items, 4)
+	assert.InDelta(t, 12100, plan.Value, 0.0001)
+}
@@ -0,0 +1,131 @@
+package evepraisal
+
+import (
+	"log"
+	"math"
+	"strings"
+)
+
+// IndustryOptions describe the character and facility that blueprint copies are valued with
+type IndustryOptions struct {
+	// Skill is the level of the Industry skill, which makes jobs 4% faster per level
+	Skill int64 `mapstructure:"skill" json:"skill"`
+	// StructureBonus is the percentage of materials that the facility saves
+	StructureBonus float64 `mapstructure:"structure_bonus" json:"structure_bonus"`
+	// CostIndex is the system cost index, as a percentage of the estimated item value of the job
+	CostIndex float64 `mapstructure:"cost_index" json:"cost_index"`
+	// SystemID is the system whose live cost index is used instead of CostIndex, if it is known
+	SystemID int64 `mapstructure:"system_id" json:"system_id,omitempty"`
+}
+
+// DefaultIndustryOptions are a character with Industry V in an NPC station in a quiet system
+var DefaultIndustryOptions = IndustryOptions{
+	Skill:     5,
+	CostIndex: 1,
+}
+
+// ItemBPC is the value of a blueprint copy: the profit of building everything it can build. Values are per run unless
+// noted.
+type ItemBPC struct {
+	Runs         int64   `json:"runs"`
+	ME           int64   `json:"me"`
+	TE           int64   `json:"te"`
+	ProductValue float64 `json:"product_value"`
+	MaterialCost float64 `json:"material_cost"`
+	JobCost      float64 `json:"job_cost"`
+	ProfitPerRun float64 `json:"profit_per_run"`
+	// TimePerRun is how long one run takes, in seconds
+	TimePerRun float64 `json:"time_per_run"`
+	// Value is what a single copy with all of its runs is worth. Copies that would lose money are worth nothing.
+	Value float64 `json:"value"`
+}
+
+// Mul returns a new ItemBPC with the ISK amounts multiplied by the given multiplier
+func (bpc ItemBPC) Mul(multiplier float64) ItemBPC {
+	bpc.ProductValue *= multiplier
+	bpc.MaterialCost *= multiplier
+	bpc.JobCost *= multiplier
+	bpc.ProfitPerRun *= multiplier
+	bpc.Value *= multiplier
+	return bpc
+}
+
+// Prices returns the prices that a blueprint copy with this value is appraised at
+func (bpc ItemBPC) Prices() Prices {
+	return Prices{Strategy: "bpc"}.Set(bpc.Value)
+}
+
+// materialQuantity is how many of a material a job needs after material efficiency and facility bonuses. Every run
+// needs at least one of each material.
+func materialQuantity(base int64, runs int64, me int64, structureBonus float64) int64 {
+	quantity := float64(base*runs) * (1 - float64(me)/100) * (1 - structureBonus/100)
+	// Rounding to two decimals first keeps floating point error from adding a unit
+	quantity = math.Ceil(math.Round(quantity*100) / 100)
+	if quantity < float64(runs) {
+		return runs
+	}
+	return int64(quantity)
+}
+
+// BPCForItem values a blueprint copy by what building all of its runs would make in the given market. Returns nil if
+// the item isn't a blueprint copy or if the blueprint's product isn't known.
+func (app *App) BPCForItem(market string, item AppraisalItem) *ItemBPC {
+	if !item.Extra.BPC {
+		return nil
+	}
+
+	tName := strings.TrimSuffix(item.TypeName, " Blueprint")
+	bpType, ok := app.TypeDB.GetType(tName)
+	if !ok {
+		log.Printf("WARN: parsed out name that isn't a type: %q", tName)
+		return nil
+	}
+
+	productMarket := market
+	// If the user selected "universe" as the market then it is fairly likely that someone has a
+	// rediculously low price in a station no one wants to travel to. To avoid negative "value"
+	// for blueprint copies, we're forcing this item to be sold at the default market's prices
+	if productMarket == UniverseMarketName {
+		productMarket = app.DefaultMarketName()
+	}
+
+	bpc := &ItemBPC{
+		Runs: item.Extra.BPCRuns,
+		ME:   item.Extra.ME,
+		TE:   item.Extra.TE,
+	}
+	if bpc.Runs <= 0 {
+		bpc.Runs = 1
+	}
+
+	for _, product := range bpType.BlueprintProducts {
+		p, ok := app.PriceDB.GetPrice(productMarket, product.TypeID)
+		if !ok {
+			log.Printf("WARN: No market data for type (%d %s)", item.TypeID, item.TypeName)
+			continue
+		}
+		bpc.ProductValue += p.Sell.Min * float64(product.Quantity)
+	}
+
+	var materialCost, estimatedItemValue float64
+	for _, component := range bpType.Components {
+		p, ok := app.PriceDB.GetPrice(market, component.TypeID)
+		if !ok {
+			log.Printf("WARN: No market data for component (%d)", component.TypeID)
+			continue
+		}
+		price := math.Min(p.Sell.Min, p.Buy.Max)
+		materialCost += price * float64(materialQuantity(component.Quantity, bpc.Runs, bpc.ME, app.Industry.StructureBonus))
+		// ESI uses CCP's adjusted prices here, the market price is close enough
+		estimatedItemValue += price * float64(component.Quantity)
+	}
+
+	bpc.MaterialCost = materialCost / float64(bpc.Runs)
+	bpc.JobCost = estimatedItemValue * app.costIndex(app.Industry.SystemID) / 100
+	bpc.ProfitPerRun = bpc.ProductValue - bpc.MaterialCost - bpc.JobCost
+	bpc.TimePerRun = float64(bpType.ManufacturingTime) * (1 - float64(bpc.TE)/100) * (1 - 0.04*float64(app.Industry.Skill))
+	if bpc.ProfitPerRun > 0 {
+		bpc.Value = bpc.ProfitPerRun * float64(bpc.Runs)
+	}
+	return bpc
+}
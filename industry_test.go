@@ -0,0 +1,122 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testIndustryTypeDB struct {
+	typedb.TypeDB
+	types map[string]typedb.EveType
+	// lookups counts the calls to GetType when it isn't nil
+	lookups *int
+}
+
+func (db testIndustryTypeDB) GetType(typeName string) (typedb.EveType, bool) {
+	if db.lookups != nil {
+		*db.lookups++
+	}
+	t, ok := db.types[typeName]
+	return t, ok
+}
+
+func (db testIndustryTypeDB) GetTypeByID(typeID int64) (typedb.EveType, bool) {
+	for _, t := range db.types {
+		if t.ID == typeID {
+			return t, true
+		}
+	}
+	return typedb.EveType{}, false
+}
+
+func TestMaterialQuantity(t *testing.T) {
+	assert.Equal(t, int64(100), materialQuantity(100, 1, 0, 0))
+	assert.Equal(t, int64(90), materialQuantity(100, 1, 10, 0))
+	assert.Equal(t, int64(891), materialQuantity(100, 10, 10, 1))
+	// Every run needs at least one
+	assert.Equal(t, int64(5), materialQuantity(1, 5, 10, 1))
+}
+
+func TestBPCForItem(t *testing.T) {
+	app := &App{
+		TypeDB: testIndustryTypeDB{types: map[string]typedb.EveType{
+			"Rifter": {
+				ID:                587,
+				Name:              "Rifter",
+				BlueprintProducts: []typedb.Component{{TypeID: 587, Quantity: 1}},
+				Components:        []typedb.Component{{TypeID: 34, Quantity: 1000}},
+				ManufacturingTime: 6000,
+			},
+		}},
+		PriceDB: testReprocessingPriceDB{prices: map[int64]Prices{
+			587: {Sell: PriceStats{Min: 10000}, Buy: PriceStats{Max: 9000}},
+			34:  {Sell: PriceStats{Min: 5}, Buy: PriceStats{Max: 4}},
+		}},
+		Industry: IndustryOptions{Skill: 5, CostIndex: 10},
+	}
+
+	item := AppraisalItem{TypeName: "Rifter Blueprint", Quantity: 2}
+	item.Extra.BPC = true
+	item.Extra.BPCRuns = 10
+	item.Extra.ME = 10
+	item.Extra.TE = 20
+
+	bpc := app.BPCForItem("jita", item)
+	assert.NotNil(t, bpc)
+	assert.Equal(t, 10000.0, bpc.ProductValue)
+	assert.Equal(t, 900*4.0, bpc.MaterialCost)
+	assert.Equal(t, 400.0, bpc.JobCost)
+	assert.Equal(t, 6000.0, bpc.ProfitPerRun)
+	assert.InDelta(t, 6000*0.8*0.8, bpc.TimePerRun, 0.0001)
+	assert.Equal(t, 60000.0, bpc.Value)
+
+	prices, err := app.PricesForItem("jita", item)
+	assert.NoError(t, err)
+	assert.Equal(t, "bpc", prices.Strategy)
+	assert.Equal(t, 60000.0, prices.Sell.Min)
+
+	// Copies that lose money are worth nothing
+	app.Industry.CostIndex = 1000
+	bpc = app.BPCForItem("jita", item)
+	assert.True(t, bpc.ProfitPerRun < 0)
+	assert.Equal(t, 0.0, bpc.Value)
+
+	// Originals and other items aren't valued as copies
+	item.Extra.BPC = false
+	assert.Nil(t, app.BPCForItem("jita", item))
+}
+
+func TestPopulateItemsValuesBPCsOnce(t *testing.T) {
+	lookups := 0
+	app := &App{
+		TypeDB: testIndustryTypeDB{lookups: &lookups, types: map[string]typedb.EveType{
+			"Rifter": {
+				ID:                587,
+				Name:              "Rifter",
+				BlueprintProducts: []typedb.Component{{TypeID: 587, Quantity: 1}},
+				Components:        []typedb.Component{{TypeID: 34, Quantity: 1000}},
+			},
+			"Rifter Blueprint": {ID: 691, Name: "Rifter Blueprint"},
+		}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{"jita": {
+			587: {Sell: PriceStats{Min: 10000}, Buy: PriceStats{Max: 9000}},
+			34:  {Sell: PriceStats{Min: 5}, Buy: PriceStats{Max: 4}},
+		}}},
+		Industry: IndustryOptions{Skill: 5},
+	}
+
+	item := AppraisalItem{TypeID: 691, TypeName: "Rifter Blueprint", Quantity: 1}
+	item.Extra.BPC = true
+	item.Extra.BPCRuns = 1
+	appraisal := &Appraisal{MarketName: "jita", Items: []AppraisalItem{item}, PricePercentage: 50}
+	app.PopulateItems(appraisal)
+
+	assert.Equal(t, 1, lookups)
+	if assert.NotNil(t, appraisal.Items[0].BPCValue) {
+		assert.Equal(t, 3000.0, appraisal.Items[0].BPCValue.Value)
+	}
+	assert.Equal(t, "bpc", appraisal.Items[0].Prices.Strategy)
+	assert.Equal(t, 3000.0, appraisal.Items[0].Prices.Sell.Min)
+}
@@ -0,0 +1,186 @@
+package evepraisal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// LoyaltyOffer is an offer in a corporation's loyalty point store, as ESI describes it. Quantity is how many of the
+// type one redemption gives.
+type LoyaltyOffer struct {
+	OfferID       int64              `json:"offer_id"`
+	TypeID        int64              `json:"type_id"`
+	Quantity      int64              `json:"quantity"`
+	LPCost        int64              `json:"lp_cost"`
+	ISKCost       float64            `json:"isk_cost"`
+	AKCost        int64              `json:"ak_cost,omitempty"`
+	RequiredItems []LoyaltyOfferItem `json:"required_items"`
+}
+
+// LoyaltyOfferItem is an item that has to be handed in to redeem a loyalty store offer
+type LoyaltyOfferItem struct {
+	TypeID   int64 `json:"type_id"`
+	Quantity int64 `json:"quantity"`
+}
+
+// LoyaltyStoreSource provides the offers in the loyalty point stores of NPC corporations
+type LoyaltyStoreSource interface {
+	LoyaltyStoreOffers(corporationID int64) ([]LoyaltyOffer, error)
+}
+
+// LPStoreOptions decide how loyalty store offers are valued. Pricing is the name of a pricing policy; the output is
+// valued with its sell value and the required items cost their sell value too, since they're bought off the market.
+type LPStoreOptions struct {
+	Market  string `json:"market"`
+	Pricing string `json:"pricing"`
+}
+
+// LPOfferItem is the output or a required item of a loyalty store offer with its price in the chosen market. Value is
+// for the whole quantity. Blueprints are copies, so BPC is set and they are priced by what building one run from them
+// makes, since ESI doesn't say how many runs they have.
+type LPOfferItem struct {
+	TypeID   int64   `json:"type_id"`
+	TypeName string  `json:"type_name"`
+	Quantity int64   `json:"quantity"`
+	Price    float64 `json:"price"`
+	Value    float64 `json:"value"`
+	BPC      bool    `json:"bpc,omitempty"`
+}
+
+// LPOffer is a loyalty store offer with what it is worth. Profit is the value of the output minus the ISK cost and the
+// value of the required items, and ISKPerLP is that profit for each loyalty point spent. Warnings flag offers whose
+// output or required items might be hard to trade at the prices used.
+type LPOffer struct {
+	OfferID            int64         `json:"offer_id"`
+	LPCost             int64         `json:"lp_cost"`
+	ISKCost            float64       `json:"isk_cost"`
+	Output             LPOfferItem   `json:"output"`
+	RequiredItems      []LPOfferItem `json:"required_items"`
+	RequiredItemsValue float64       `json:"required_items_value"`
+	Profit             float64       `json:"profit"`
+	ISKPerLP           float64       `json:"isk_per_lp"`
+	DailyVolume        float64       `json:"daily_volume"`
+	Warnings           []ItemWarning `json:"warnings,omitempty"`
+}
+
+// LPStoreOffers fetches the offers in a corporation's loyalty point store and values them in the chosen market, best
+// ISK/LP first. Offers that only cost analysis kredits are left out.
+func (app *App) LPStoreOffers(corporationID int64, options LPStoreOptions) ([]LPOffer, error) {
+	if app.LoyaltyStores == nil {
+		return nil, fmt.Errorf("loyalty stores are not available")
+	}
+	if app.TypeDB == nil {
+		return nil, fmt.Errorf("type database is not loaded yet")
+	}
+	offers, err := app.LoyaltyStores.LoyaltyStoreOffers(corporationID)
+	if err != nil {
+		return nil, err
+	}
+	return app.ValueLPOffers(offers, options, time.Now()), nil
+}
+
+// ValueLPOffers values loyalty store offers in the chosen market, best ISK/LP first
+func (app *App) ValueLPOffers(offers []LoyaltyOffer, options LPStoreOptions, now time.Time) []LPOffer {
+	policy := pricingPolicyOrDefault(options.Pricing)
+	results := make([]LPOffer, 0, len(offers))
+	for _, offer := range offers {
+		if offer.LPCost <= 0 {
+			continue
+		}
+
+		result := LPOffer{
+			OfferID:       offer.OfferID,
+			LPCost:        offer.LPCost,
+			ISKCost:       offer.ISKCost,
+			RequiredItems: make([]LPOfferItem, 0, len(offer.RequiredItems)),
+		}
+
+		var prices Prices
+		result.Output, prices = app.lpOfferItem(options.Market, offer.TypeID, offer.Quantity, policy)
+		if result.Output.Price == 0 {
+			result.Warnings = append(result.Warnings, ItemWarning{
+				Code:    WarningNoPrice,
+				Message: fmt.Sprintf("%s has no price in %s", result.Output.TypeName, options.Market),
+			})
+		} else {
+			item := AppraisalItem{TypeID: offer.TypeID, Quantity: offer.Quantity, Prices: prices}
+			result.Warnings = append(result.Warnings, app.WarningsForItem(options.Market, item, now)...)
+		}
+		if result.Output.BPC {
+			result.Warnings = append(result.Warnings, ItemWarning{
+				Code:    WarningBPCValue,
+				Message: "Blueprint copies can't be sold on the market, so this is the profit of building one run",
+			})
+		}
+
+		history := app.MarketHistoryForItem(options.Market, offer.TypeID, now)
+		if history != nil {
+			result.DailyVolume = history.AvgDailyVolume
+		}
+		if history == nil || history.AvgDailyVolume < float64(offer.Quantity) {
+			result.Warnings = append(result.Warnings, ItemWarning{
+				Code:    WarningLowDailyVolume,
+				Message: fmt.Sprintf("Only %s are traded per day, fewer than one redemption gives", humanize.Commaf(result.DailyVolume)),
+			})
+		}
+
+		for _, required := range offer.RequiredItems {
+			item, _ := app.lpOfferItem(options.Market, required.TypeID, required.Quantity, policy)
+			if item.Price == 0 {
+				result.Warnings = append(result.Warnings, ItemWarning{
+					Code:    WarningNoPrice,
+					Message: fmt.Sprintf("Required item %s has no price in %s", item.TypeName, options.Market),
+				})
+			}
+			result.RequiredItemsValue += item.Value
+			result.RequiredItems = append(result.RequiredItems, item)
+		}
+
+		result.Profit = result.Output.Value - result.ISKCost - result.RequiredItemsValue
+		result.ISKPerLP = result.Profit / float64(result.LPCost)
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].ISKPerLP != results[j].ISKPerLP {
+			return results[i].ISKPerLP > results[j].ISKPerLP
+		}
+		return results[i].OfferID < results[j].OfferID
+	})
+	return results
+}
+
+// lpOfferItem prices a quantity of a type in the given market
+func (app *App) lpOfferItem(market string, typeID int64, quantity int64, policy PricingPolicy) (LPOfferItem, Prices) {
+	t, ok := app.TypeDB.GetTypeByID(typeID)
+	if !ok {
+		t = typedb.EveType{ID: typeID, Name: fmt.Sprintf("Type %d", typeID)}
+	}
+
+	var prices Prices
+	bpc := strings.HasSuffix(t.Name, " Blueprint")
+	if bpc {
+		item := AppraisalItem{TypeID: typeID, TypeName: t.Name, Quantity: quantity}
+		item.Extra.BPC = true
+		item.Extra.BPCRuns = 1
+		if value := app.BPCForItem(market, item); value != nil {
+			prices = value.Prices()
+		}
+	} else {
+		prices = app.fallbackPrices(market, t)
+	}
+	price := policy.Sell(prices)
+	return LPOfferItem{
+		TypeID:   typeID,
+		TypeName: t.Name,
+		Quantity: quantity,
+		Price:    price,
+		Value:    price * float64(quantity),
+		BPC:      bpc,
+	}, prices
+}
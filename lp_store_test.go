@@ -0,0 +1,139 @@
+package evepraisal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLPStorePriceDB struct {
+	testFallbackPriceDB
+	history map[int64]MarketHistory
+}
+
+func (db testLPStorePriceDB) GetMarketHistory(regionID int64, typeID int64) (MarketHistory, bool) {
+	history, ok := db.history[typeID]
+	return history, ok
+}
+
+type testLoyaltyStores map[int64][]LoyaltyOffer
+
+func (stores testLoyaltyStores) LoyaltyStoreOffers(corporationID int64) ([]LoyaltyOffer, error) {
+	offers, ok := stores[corporationID]
+	if !ok {
+		return nil, fmt.Errorf("ESI is down")
+	}
+	return offers, nil
+}
+
+func TestLPStoreOffers(t *testing.T) {
+	now := time.Date(2020, 2, 1, 12, 0, 0, 0, time.UTC)
+	// Enough is listed that only the daily volume is worth a warning
+	orders := func(price float64) Prices {
+		prices := Prices{Strategy: "orders"}.Set(price)
+		prices.Buy.Volume, prices.Sell.Volume = 100000, 100000
+		return prices
+	}
+	app := &App{
+		Markets: NormalizeMarkets([]Market{{Name: "jita", RegionIDs: []int64{10000002}}}),
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			10: {ID: 10, Name: "Navy Implant"},
+			11: {ID: 11, Name: "Tag"},
+			12: {ID: 12, Name: "Navy Ammo"},
+			13: {ID: 13, Name: "Navy Skin"},
+		}},
+		PriceDB: testLPStorePriceDB{
+			testFallbackPriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+				"jita": {
+					10: orders(2000000),
+					11: orders(50000),
+					12: orders(500),
+				},
+			}},
+			history: map[int64]MarketHistory{
+				10: {Days: []MarketHistoryDay{{Date: "2020-01-20", Average: 2000000, Volume: 300}}},
+			},
+		},
+		PriceFallbacks: []string{FallbackOrders},
+		LoyaltyStores: testLoyaltyStores{1000180: {
+			{OfferID: 1, TypeID: 10, Quantity: 1, LPCost: 1000, ISKCost: 100000, RequiredItems: []LoyaltyOfferItem{{TypeID: 11, Quantity: 2}}},
+			{OfferID: 2, TypeID: 12, Quantity: 5000, LPCost: 1000},
+			{OfferID: 3, TypeID: 13, Quantity: 1, LPCost: 500},
+			// Offers that only cost analysis kredits aren't LP conversions
+			{OfferID: 4, TypeID: 12, Quantity: 100, AKCost: 10},
+		}},
+	}
+
+	offers := app.ValueLPOffers(app.LoyaltyStores.(testLoyaltyStores)[1000180], LPStoreOptions{Market: "jita"}, now)
+	if !assert.Len(t, offers, 3) {
+		return
+	}
+
+	// The ammo is worth the most per LP but barely trades
+	assert.Equal(t, int64(2), offers[0].OfferID)
+	assert.Equal(t, LPOfferItem{TypeID: 12, TypeName: "Navy Ammo", Quantity: 5000, Price: 500, Value: 2500000}, offers[0].Output)
+	assert.InDelta(t, 2500, offers[0].ISKPerLP, 0.0001)
+	assert.Equal(t, []ItemWarning{{Code: WarningLowDailyVolume, Message: "Only 0 are traded per day, fewer than one redemption gives"}}, offers[0].Warnings)
+
+	// The implant costs ISK and a tag
+	assert.Equal(t, int64(1), offers[1].OfferID)
+	assert.Equal(t, []LPOfferItem{{TypeID: 11, TypeName: "Tag", Quantity: 2, Price: 50000, Value: 100000}}, offers[1].RequiredItems)
+	assert.InDelta(t, 100000, offers[1].RequiredItemsValue, 0.0001)
+	assert.InDelta(t, 1800000, offers[1].Profit, 0.0001)
+	assert.InDelta(t, 1800, offers[1].ISKPerLP, 0.0001)
+	assert.InDelta(t, 10, offers[1].DailyVolume, 0.0001)
+	assert.Empty(t, offers[1].Warnings)
+
+	// Offers that can't be priced go last
+	assert.Equal(t, int64(3), offers[2].OfferID)
+	assert.Equal(t, 0.0, offers[2].ISKPerLP)
+	if assert.Len(t, offers[2].Warnings, 2) {
+		assert.Equal(t, WarningNoPrice, offers[2].Warnings[0].Code)
+	}
+
+	offers, err := app.LPStoreOffers(1000180, LPStoreOptions{Market: "jita", Pricing: "buy_max"})
+	assert.NoError(t, err)
+	assert.Len(t, offers, 3)
+
+	_, err = app.LPStoreOffers(1000181, LPStoreOptions{Market: "jita"})
+	assert.Error(t, err)
+
+	app.LoyaltyStores = nil
+	_, err = app.LPStoreOffers(1000180, LPStoreOptions{Market: "jita"})
+	assert.Error(t, err)
+}
+
+func TestLPStoreBlueprintOffers(t *testing.T) {
+	now := time.Date(2020, 2, 1, 12, 0, 0, 0, time.UTC)
+	app := &App{
+		TypeDB: testIndustryTypeDB{types: map[string]typedb.EveType{
+			"Rifter": {
+				ID:                587,
+				Name:              "Rifter",
+				BlueprintProducts: []typedb.Component{{TypeID: 587, Quantity: 1}},
+				Components:        []typedb.Component{{TypeID: 34, Quantity: 1000}},
+			},
+			// The original's base price isn't what a copy is worth
+			"Rifter Blueprint": {ID: 691, Name: "Rifter Blueprint", BasePrice: 1000000},
+		}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{"jita": {
+			587: {Strategy: "orders", Sell: PriceStats{Min: 10000}, Buy: PriceStats{Max: 9000}},
+			34:  {Strategy: "orders", Sell: PriceStats{Min: 5}, Buy: PriceStats{Max: 4}},
+		}}},
+	}
+
+	offers := app.ValueLPOffers([]LoyaltyOffer{{OfferID: 1, TypeID: 691, Quantity: 2, LPCost: 1000}}, LPStoreOptions{Market: "jita"}, now)
+	if !assert.Len(t, offers, 1) {
+		return
+	}
+	// Each copy makes a Rifter for 4,000 ISK of tritanium
+	assert.Equal(t, LPOfferItem{TypeID: 691, TypeName: "Rifter Blueprint", Quantity: 2, Price: 6000, Value: 12000, BPC: true}, offers[0].Output)
+	assert.InDelta(t, 12, offers[0].ISKPerLP, 0.0001)
+	assert.Contains(t, offers[0].Warnings, ItemWarning{
+		Code:    WarningBPCValue,
+		Message: "Blueprint copies can't be sold on the market, so this is the profit of building one run",
+	})
+}
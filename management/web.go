@@ -4,9 +4,12 @@ import (
 	"compress/gzip"
 	"encoding/json"
 	"expvar"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/evepraisal/go-evepraisal"
@@ -85,6 +88,175 @@ func (ctx *Context) HandleBackupToFile(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+// HandleListBuybackPrograms is the handler for GET /buyback
+func (ctx *Context) HandleListBuybackPrograms(w http.ResponseWriter, r *http.Request) {
+	programs, err := ctx.App.BuybackDB.ListBuybackPrograms()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(programs)
+}
+
+// HandleGetBuybackProgram is the handler for GET /buyback/:name
+func (ctx *Context) HandleGetBuybackProgram(w http.ResponseWriter, r *http.Request) {
+	program, err := ctx.App.BuybackDB.GetBuybackProgram(vestigo.Param(r, "name"))
+	if err == evepraisal.ErrBuybackProgramNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(program)
+}
+
+// HandlePutBuybackProgram is the handler for PUT /buyback/:name, which creates or replaces a buyback program
+func (ctx *Context) HandlePutBuybackProgram(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var program evepraisal.BuybackProgram
+	err := json.NewDecoder(r.Body).Decode(&program)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	program.Name = vestigo.Param(r, "name")
+
+	err = program.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for i, rule := range program.Rules {
+		if _, ok := ctx.App.GetMarket(rule.Market); rule.Market != "" && !ok {
+			http.Error(w, fmt.Sprintf("rule %d: unknown market %q", i, rule.Market), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = ctx.App.BuybackDB.PutBuybackProgram(program)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleDeleteBuybackProgram is the handler for DELETE /buyback/:name
+func (ctx *Context) HandleDeleteBuybackProgram(w http.ResponseWriter, r *http.Request) {
+	err := ctx.App.BuybackDB.DeleteBuybackProgram(vestigo.Param(r, "name"))
+	if err == evepraisal.ErrBuybackProgramNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ctx *Context) isConfiguredMarket(name string) bool {
+	for _, market := range ctx.App.Markets {
+		if market.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleListPriceSheets is the handler for GET /price-sheets
+func (ctx *Context) HandleListPriceSheets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ctx.App.PriceSheets.ListPriceSheets())
+}
+
+// HandleGetPriceSheet is the handler for GET /price-sheets/:name
+func (ctx *Context) HandleGetPriceSheet(w http.ResponseWriter, r *http.Request) {
+	sheet, ok := ctx.App.PriceSheets.GetPriceSheet(vestigo.Param(r, "name"))
+	if !ok {
+		http.Error(w, evepraisal.ErrPriceSheetNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sheet)
+}
+
+// HandlePutPriceSheet is the handler for PUT /price-sheets/:name, which creates or replaces a price sheet. The body is
+// either a JSON price sheet or, with a text/csv content type, CSV with the columns: type ID or name, buy, sell. CSV
+// uploads take the display_name and overlay from the query string.
+func (ctx *Context) HandlePutPriceSheet(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var (
+		sheet evepraisal.PriceSheet
+		err   error
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		sheet.DisplayName = r.URL.Query().Get("display_name")
+		sheet.Overlay = r.URL.Query().Get("overlay")
+		sheet.Items, err = evepraisal.ParsePriceSheetCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&sheet)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sheet.Name = vestigo.Param(r, "name")
+	sheet.Updated = time.Now()
+
+	if ctx.isConfiguredMarket(sheet.Name) {
+		http.Error(w, fmt.Sprintf("%q is already a market", sheet.Name), http.StatusBadRequest)
+		return
+	}
+	// Sheets can only overlay markets with orders
+	if sheet.Overlay != "" && !ctx.isConfiguredMarket(sheet.Overlay) {
+		http.Error(w, fmt.Sprintf("unknown overlay market %q", sheet.Overlay), http.StatusBadRequest)
+		return
+	}
+
+	if ctx.App.TypeDB == nil {
+		http.Error(w, "types aren't loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+	err = sheet.ResolveTypes(ctx.App.TypeDB)
+	if err == nil {
+		err = sheet.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ctx.App.PriceSheets.PutPriceSheet(sheet)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleDeletePriceSheet is the handler for DELETE /price-sheets/:name
+func (ctx *Context) HandleDeletePriceSheet(w http.ResponseWriter, r *http.Request) {
+	err := ctx.App.PriceSheets.DeletePriceSheet(vestigo.Param(r, "name"))
+	if err == evepraisal.ErrPriceSheetNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleListStructures is the handler for GET /structures. It shows whether each player structure market is being
+// fetched or what went wrong with it.
+func (ctx *Context) HandleListStructures(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]evepraisal.StructureMarketStatus, 0)
+	if ctx.App.StructureMarkets != nil {
+		statuses = ctx.App.StructureMarkets.StructureMarketStatuses()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
 // HTTPHandler returns the http.Handler for the web management api
 func HTTPHandler(app *evepraisal.App, appraisalBackupPath string) http.Handler {
 	ctx := Context{App: app, AppraisalBackupPath: appraisalBackupPath}
@@ -92,6 +264,15 @@ func HTTPHandler(app *evepraisal.App, appraisalBackupPath string) http.Handler {
 	router.Get("/backup/appraisals", ctx.HandleBackup)
 	router.Get("/backup-to-file/appraisals", ctx.HandleBackupToFile)
 	router.Post("/restore", ctx.HandleRestore)
+	router.Get("/buyback", ctx.HandleListBuybackPrograms)
+	router.Get("/buyback/:name", ctx.HandleGetBuybackProgram)
+	router.Put("/buyback/:name", ctx.HandlePutBuybackProgram)
+	router.Delete("/buyback/:name", ctx.HandleDeleteBuybackProgram)
+	router.Get("/price-sheets", ctx.HandleListPriceSheets)
+	router.Get("/price-sheets/:name", ctx.HandleGetPriceSheet)
+	router.Put("/price-sheets/:name", ctx.HandlePutPriceSheet)
+	router.Delete("/price-sheets/:name", ctx.HandleDeletePriceSheet)
+	router.Get("/structures", ctx.HandleListStructures)
 	router.Handle("/expvar", expvar.Handler())
 	return router
 }
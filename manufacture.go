@@ -0,0 +1,147 @@
+package evepraisal
+
+import (
+	"math"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// maxManufactureDepth is how many levels of components are expanded in a manufacturing breakdown
+const maxManufactureDepth = 5
+
+// Recommendations for a node of a manufacturing breakdown
+const (
+	RecommendBuild = "build"
+	RecommendBuy   = "buy"
+)
+
+// costIndex returns the manufacturing cost index of the given system. The configured cost index is used if the system
+// isn't known.
+func (app *App) costIndex(systemID int64) float64 {
+	if app.CostIndices != nil && systemID != 0 {
+		if costIndex, ok := app.CostIndices.CostIndex(systemID, "manufacturing"); ok {
+			return costIndex
+		}
+	}
+	return app.Industry.CostIndex
+}
+
+// ManufactureOptions describe the job that a manufacturing breakdown is for. The material efficiency is used for
+// every blueprint in the tree.
+type ManufactureOptions struct {
+	Runs           int64   `json:"runs"`
+	ME             int64   `json:"me"`
+	StructureBonus float64 `json:"structure_bonus"`
+	SystemID       int64   `json:"system_id,omitempty"`
+}
+
+// ManufactureNode is one item in a manufacturing breakdown along with what it costs to buy or to build. Components are
+// only given for items that can be built.
+type ManufactureNode struct {
+	TypeID         int64             `json:"type_id"`
+	TypeName       string            `json:"type_name"`
+	Quantity       int64             `json:"quantity"`
+	UnitPrice      float64           `json:"unit_price"`
+	BuyCost        float64           `json:"buy_cost"`
+	Runs           int64             `json:"runs,omitempty"`
+	JobCost        float64           `json:"job_cost,omitempty"`
+	BuildCost      float64           `json:"build_cost,omitempty"`
+	Recommendation string            `json:"recommendation"`
+	Components     []ManufactureNode `json:"components,omitempty"`
+}
+
+// Cost is what the node costs when following the recommendation
+func (node ManufactureNode) Cost() float64 {
+	if node.Recommendation == RecommendBuild {
+		return node.BuildCost
+	}
+	return node.BuyCost
+}
+
+// Savings is how much following the recommendation saves over the other option
+func (node ManufactureNode) Savings() float64 {
+	if len(node.Components) == 0 {
+		return 0
+	}
+	return math.Abs(node.BuyCost - node.BuildCost)
+}
+
+// ManufactureBreakdown is the full material tree for building an item
+type ManufactureBreakdown struct {
+	MarketName string             `json:"market_name"`
+	Options    ManufactureOptions `json:"options"`
+	CostIndex  float64            `json:"cost_index"`
+	Tree       ManufactureNode    `json:"tree"`
+}
+
+// ManufactureBreakdown prices every item needed to build the given type in the given market and recommends whether each
+// of them should be built or bought. Returns false if the type can't be built.
+func (app *App) ManufactureBreakdown(market string, typeID int64, options ManufactureOptions) (*ManufactureBreakdown, bool) {
+	t, ok := app.TypeDB.GetTypeByID(typeID)
+	if !ok || len(t.Components) == 0 {
+		return nil, false
+	}
+	if options.Runs <= 0 {
+		options.Runs = 1
+	}
+
+	breakdown := &ManufactureBreakdown{
+		MarketName: market,
+		Options:    options,
+		CostIndex:  app.costIndex(options.SystemID),
+	}
+	breakdown.Tree = app.manufactureNode(breakdown, typeID, options.Runs*productQuantity(t.BlueprintProducts, typeID), 0)
+	return breakdown, true
+}
+
+// productQuantity is how many of the type a single run of its blueprint makes
+func productQuantity(products []typedb.Component, typeID int64) int64 {
+	for _, product := range products {
+		if product.TypeID == typeID && product.Quantity > 0 {
+			return product.Quantity
+		}
+	}
+	return 1
+}
+
+func (app *App) manufactureNode(breakdown *ManufactureBreakdown, typeID int64, quantity int64, depth int) ManufactureNode {
+	node := ManufactureNode{
+		TypeID:         typeID,
+		Quantity:       quantity,
+		Recommendation: RecommendBuy,
+	}
+
+	t, ok := app.TypeDB.GetTypeByID(typeID)
+	if !ok {
+		return node
+	}
+	node.TypeName = t.Name
+
+	prices, _ := app.PriceDB.GetPrice(breakdown.MarketName, typeID)
+	node.UnitPrice = prices.Sell.Min
+	node.BuyCost = node.UnitPrice * float64(quantity)
+
+	if len(t.Components) == 0 || depth >= maxManufactureDepth {
+		return node
+	}
+
+	perRun := productQuantity(t.BlueprintProducts, typeID)
+	node.Runs = (quantity + perRun - 1) / perRun
+
+	var materialCost, estimatedItemValue float64
+	for _, component := range t.Components {
+		childQuantity := materialQuantity(component.Quantity, node.Runs, breakdown.Options.ME, breakdown.Options.StructureBonus)
+		child := app.manufactureNode(breakdown, component.TypeID, childQuantity, depth+1)
+		materialCost += child.Cost()
+		// ESI uses CCP's adjusted prices here, the market price is close enough
+		estimatedItemValue += child.UnitPrice * float64(component.Quantity*node.Runs)
+		node.Components = append(node.Components, child)
+	}
+
+	node.JobCost = estimatedItemValue * breakdown.CostIndex / 100
+	node.BuildCost = materialCost + node.JobCost
+	if node.UnitPrice == 0 || node.BuildCost < node.BuyCost {
+		node.Recommendation = RecommendBuild
+	}
+	return node
+}
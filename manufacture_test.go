@@ -0,0 +1,76 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCostIndices map[int64]float64
+
+func (c testCostIndices) CostIndex(systemID int64, activity string) (float64, bool) {
+	costIndex, ok := c[systemID]
+	return costIndex, ok
+}
+
+func TestManufactureBreakdown(t *testing.T) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			587: {
+				ID:                587,
+				Name:              "Rifter",
+				BlueprintProducts: []typedb.Component{{TypeID: 587, Quantity: 1}},
+				Components:        []typedb.Component{{TypeID: 34, Quantity: 1000}, {TypeID: 11530, Quantity: 2}},
+			},
+			11530: {
+				ID:                11530,
+				Name:              "Plasma Thruster",
+				BlueprintProducts: []typedb.Component{{TypeID: 11530, Quantity: 10}},
+				Components:        []typedb.Component{{TypeID: 34, Quantity: 100}},
+			},
+			34: {ID: 34, Name: "Tritanium"},
+		}},
+		PriceDB: testReprocessingPriceDB{prices: map[int64]Prices{
+			587:   {Sell: PriceStats{Min: 100000}},
+			11530: {Sell: PriceStats{Min: 1000}},
+			34:    {Sell: PriceStats{Min: 5}},
+		}},
+		Industry:    IndustryOptions{CostIndex: 10},
+		CostIndices: testCostIndices{30000142: 5},
+	}
+
+	_, ok := app.ManufactureBreakdown("jita", 34, ManufactureOptions{})
+	assert.False(t, ok)
+
+	breakdown, ok := app.ManufactureBreakdown("jita", 587, ManufactureOptions{Runs: 2, ME: 10})
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, breakdown.CostIndex)
+
+	tree := breakdown.Tree
+	assert.Equal(t, int64(2), tree.Quantity)
+	assert.Equal(t, 200000.0, tree.BuyCost)
+	assert.Len(t, tree.Components, 2)
+
+	tritanium := tree.Components[0]
+	assert.Equal(t, int64(1800), tritanium.Quantity)
+	assert.Equal(t, RecommendBuy, tritanium.Recommendation)
+	assert.Empty(t, tritanium.Components)
+
+	// 4 thrusters is a single run of the thruster blueprint, which is cheaper than buying them
+	thruster := tree.Components[1]
+	assert.Equal(t, int64(4), thruster.Quantity)
+	assert.Equal(t, int64(1), thruster.Runs)
+	assert.Equal(t, 4000.0, thruster.BuyCost)
+	assert.Equal(t, 90*5+50.0, thruster.BuildCost)
+	assert.Equal(t, RecommendBuild, thruster.Recommendation)
+
+	assert.Equal(t, 1800*5+thruster.BuildCost+(2000*5+4*1000)*0.1, tree.BuildCost)
+	assert.Equal(t, RecommendBuild, tree.Recommendation)
+
+	// The live cost index of the system is used when it is known
+	breakdown, _ = app.ManufactureBreakdown("jita", 587, ManufactureOptions{SystemID: 30000142})
+	assert.Equal(t, 5.0, breakdown.CostIndex)
+	breakdown, _ = app.ManufactureBreakdown("jita", 587, ManufactureOptions{SystemID: 30000144})
+	assert.Equal(t, 10.0, breakdown.CostIndex)
+}
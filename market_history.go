@@ -0,0 +1,149 @@
+package evepraisal
+
+import (
+	"time"
+)
+
+// MarketHistoryWindow is how many days of market history are used for liquidity metrics
+const MarketHistoryWindow = 30
+
+// maxDaysToSell caps DaysToSell for items that haven't traded at all
+const maxDaysToSell = 365.0
+
+// MarketHistoryDay is how much of a type traded in a region on a single day
+type MarketHistoryDay struct {
+	Date       string  `json:"date"`
+	Average    float64 `json:"average"`
+	Highest    float64 `json:"highest"`
+	Lowest     float64 `json:"lowest"`
+	OrderCount int64   `json:"order_count"`
+	Volume     int64   `json:"volume"`
+}
+
+// Time returns the day as a time
+func (day MarketHistoryDay) Time() time.Time {
+	t, _ := time.Parse("2006-01-02", day.Date)
+	return t
+}
+
+// MarketHistory is the daily trade history of a type in a region along with the cache details of the ESI response
+type MarketHistory struct {
+	Days    []MarketHistoryDay `json:"days"`
+	ETag    string             `json:"etag"`
+	Expires time.Time          `json:"expires"`
+}
+
+// RegionItemMarketHistory is the market history for a type in a region
+type RegionItemMarketHistory struct {
+	RegionID int64
+	TypeID   int64
+	History  MarketHistory
+}
+
+// ItemMarketHistory summarizes recent trading of an item in a market. ESI only keeps history for whole regions, so
+// this is the trading in every region of the market (RegionIDs), not only in the market's stations or systems.
+type ItemMarketHistory struct {
+	RegionIDs      []int64 `json:"region_ids"`
+	AvgDailyVolume float64 `json:"avg_daily_volume"`
+	Average        float64 `json:"average"`
+	Highest        float64 `json:"highest"`
+	Lowest         float64 `json:"lowest"`
+	DaysToSell     float64 `json:"days_to_sell"`
+}
+
+// AvgDailyVolume is how many of the item traded per day on average. It is 0 if there is no market history.
+func (i AppraisalItem) AvgDailyVolume() float64 {
+	if i.MarketHistory == nil {
+		return 0
+	}
+	return i.MarketHistory.AvgDailyVolume
+}
+
+// DaysToSell estimates how many days it would take the market to absorb the given quantity of the item. Items that
+// haven't traded recently return a large number instead of infinity. It is 0 if there is no market history.
+func (i AppraisalItem) DaysToSell(quantity int64) float64 {
+	if i.MarketHistory == nil {
+		return 0
+	}
+	if i.MarketHistory.AvgDailyVolume == 0 {
+		return maxDaysToSell
+	}
+	days := float64(quantity) / i.MarketHistory.AvgDailyVolume
+	if days > maxDaysToSell {
+		return maxDaysToSell
+	}
+	return days
+}
+
+// historyRegionIDs returns the regions that market history is kept for the given market. The universe market uses
+// every region that another market uses.
+func (app *App) historyRegionIDs(marketName string) []int64 {
+	market, ok := app.GetMarket(marketName)
+	if !ok {
+		return nil
+	}
+	if !market.IsUniverse() {
+		return market.RegionIDs
+	}
+
+	seen := make(map[int64]bool)
+	regionIDs := make([]int64, 0)
+	for _, m := range app.Markets {
+		if m.IsUniverse() {
+			continue
+		}
+		for _, regionID := range m.RegionIDs {
+			if !seen[regionID] {
+				seen[regionID] = true
+				regionIDs = append(regionIDs, regionID)
+			}
+		}
+	}
+	return regionIDs
+}
+
+// MarketHistoryForItem summarizes the last MarketHistoryWindow days of trading for the given type in the given
+// market. Returns nil if there's no history for the type.
+func (app *App) MarketHistoryForItem(market string, typeID int64, now time.Time) *ItemMarketHistory {
+	since := now.UTC().AddDate(0, 0, -MarketHistoryWindow)
+
+	var (
+		found       bool
+		totalVolume int64
+		totalISK    float64
+		result      ItemMarketHistory
+	)
+	for _, regionID := range app.historyRegionIDs(market) {
+		history, ok := app.PriceDB.GetMarketHistory(regionID, typeID)
+		if !ok {
+			continue
+		}
+		found = true
+		result.RegionIDs = append(result.RegionIDs, regionID)
+
+		for _, day := range history.Days {
+			if day.Time().Before(since) {
+				continue
+			}
+			totalVolume += day.Volume
+			totalISK += day.Average * float64(day.Volume)
+			if day.Highest > result.Highest {
+				result.Highest = day.Highest
+			}
+			if result.Lowest == 0 || day.Lowest < result.Lowest {
+				result.Lowest = day.Lowest
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	if totalVolume > 0 {
+		result.Average = totalISK / float64(totalVolume)
+	}
+	// ESI leaves out days without trades so the whole window is used, not just the days that were returned
+	result.AvgDailyVolume = float64(totalVolume) / MarketHistoryWindow
+	return &result
+}
@@ -0,0 +1,65 @@
+package evepraisal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMarketHistoryDB struct {
+	PriceDB
+	history map[int64]MarketHistory
+}
+
+func (db testMarketHistoryDB) GetMarketHistory(regionID int64, typeID int64) (MarketHistory, bool) {
+	history, ok := db.history[regionID]
+	return history, ok
+}
+
+func TestMarketHistoryForItem(t *testing.T) {
+	now := time.Date(2020, 2, 1, 12, 0, 0, 0, time.UTC)
+	app := &App{
+		Markets: NormalizeMarkets([]Market{
+			{Name: "jita", RegionIDs: []int64{10000002}},
+			{Name: "amarr", RegionIDs: []int64{10000043}},
+		}),
+		PriceDB: testMarketHistoryDB{history: map[int64]MarketHistory{
+			10000002: {Days: []MarketHistoryDay{
+				{Date: "2019-12-01", Average: 1, Highest: 1, Lowest: 1, Volume: 100000},
+				{Date: "2020-01-20", Average: 10, Highest: 12, Lowest: 8, Volume: 200},
+				{Date: "2020-01-21", Average: 20, Highest: 22, Lowest: 18, Volume: 100},
+			}},
+			10000043: {Days: []MarketHistoryDay{
+				{Date: "2020-01-25", Average: 10, Highest: 30, Lowest: 5, Volume: 300},
+			}},
+		}},
+	}
+
+	history := app.MarketHistoryForItem("jita", 34, now)
+	assert.NotNil(t, history)
+	assert.Equal(t, []int64{10000002}, history.RegionIDs)
+	assert.Equal(t, 10.0, history.AvgDailyVolume)
+	assert.InDelta(t, 13.333, history.Average, 0.001)
+	assert.Equal(t, 22.0, history.Highest)
+	assert.Equal(t, 8.0, history.Lowest)
+
+	// Universe uses every hub region
+	history = app.MarketHistoryForItem("universe", 34, now)
+	assert.Equal(t, 20.0, history.AvgDailyVolume)
+	assert.Equal(t, 5.0, history.Lowest)
+
+	assert.Nil(t, app.MarketHistoryForItem("perimeter", 34, now))
+}
+
+func TestDaysToSell(t *testing.T) {
+	item := AppraisalItem{Quantity: 100}
+	assert.Equal(t, 0.0, item.DaysToSell(item.Quantity))
+
+	item.MarketHistory = &ItemMarketHistory{AvgDailyVolume: 10}
+	assert.Equal(t, 10.0, item.DaysToSell(item.Quantity))
+	assert.Equal(t, maxDaysToSell, item.DaysToSell(1000000))
+
+	item.MarketHistory.AvgDailyVolume = 0
+	assert.Equal(t, maxDaysToSell, item.DaysToSell(1))
+}
@@ -0,0 +1,217 @@
+package evepraisal
+
+import (
+	"fmt"
+	"time"
+)
+
+// Market defines a place that items can be appraised against. Orders are fetched for each of the RegionIDs and then
+// narrowed down to the given stations and systems. If no stations or systems are given, the whole region is used.
+//
+// Orders from the markets of the player structures in StructureIDs are fetched with the service character's token.
+//
+// A composite market lists other markets in Markets instead and has the orders of all of them.
+type Market struct {
+	Name         string   `mapstructure:"name" json:"name"`
+	DisplayName  string   `mapstructure:"display_name" json:"display_name"`
+	RegionIDs    []int64  `mapstructure:"region_ids" json:"region_ids,omitempty"`
+	StationIDs   []int64  `mapstructure:"station_ids" json:"station_ids,omitempty"`
+	SystemIDs    []int64  `mapstructure:"system_ids" json:"system_ids,omitempty"`
+	StructureIDs []int64  `mapstructure:"structure_ids" json:"structure_ids,omitempty"`
+	Markets      []string `mapstructure:"markets" json:"markets,omitempty"`
+
+	// parts are the markets that a composite market is made of. They're filled in by NormalizeMarkets.
+	parts []Market
+}
+
+// UniverseMarketName is the name of the market that includes every order that is fetched. Regions listed on the
+// universe market are fetched even if no other market uses them.
+const UniverseMarketName = "universe"
+
+// DefaultMarkets is the list of markets that is used when none are configured
+var DefaultMarkets = []Market{
+	{
+		Name:        "jita",
+		DisplayName: "Jita",
+		RegionIDs:   []int64{10000002},
+		SystemIDs:   []int64{30000142},
+	}, {
+		Name:        "perimeter",
+		DisplayName: "Perimeter",
+		RegionIDs:   []int64{10000002},
+		SystemIDs:   []int64{30000144},
+	}, {
+		Name:        UniverseMarketName,
+		DisplayName: "Universe",
+		RegionIDs:   []int64{10000027},
+	}, {
+		Name:        "amarr",
+		DisplayName: "Amarr",
+		RegionIDs:   []int64{10000043},
+		StationIDs:  []int64{60008950, 60002569, 60008494},
+		SystemIDs:   []int64{30003491},
+	}, {
+		Name:        "dodixie",
+		DisplayName: "Dodixie",
+		RegionIDs:   []int64{10000032},
+		StationIDs:  []int64{60011866, 60001867},
+		SystemIDs:   []int64{30002661},
+	}, {
+		Name:        "hek",
+		DisplayName: "Hek",
+		RegionIDs:   []int64{10000042},
+		StationIDs:  []int64{60005236, 60004516, 60015140, 60005686, 60011287},
+	}, {
+		Name:        "rens",
+		DisplayName: "Rens",
+		RegionIDs:   []int64{10000030},
+		SystemIDs:   []int64{30002510, 30002526},
+	},
+}
+
+// IsUniverse returns true if this is the market that includes all fetched orders
+func (m Market) IsUniverse() bool {
+	return m.Name == UniverseMarketName
+}
+
+// StructureMarketStatus is the state of fetching the orders of a player structure's market
+type StructureMarketStatus struct {
+	StructureID int64     `json:"structure_id"`
+	OrderCount  int       `json:"order_count"`
+	LastFetched time.Time `json:"last_fetched,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ErrorTime   time.Time `json:"error_time,omitempty"`
+	// AccessDenied is set when ESI refuses the service character access to the structure's market. The structure's
+	// orders are dropped until access is given again.
+	AccessDenied bool `json:"access_denied"`
+}
+
+// IsComposite returns true if the market is made up of other markets
+func (m Market) IsComposite() bool {
+	return len(m.Markets) > 0
+}
+
+// Parts returns the markets that a composite market is made of
+func (m Market) Parts() []Market {
+	return m.parts
+}
+
+// CheckMarkets returns an error if a market has no name or the same name as another market, if a composite market
+// uses a market that doesn't exist, the universe market or another composite market, or if defaultMarket isn't one of
+// the markets. An empty defaultMarket is the first market.
+func CheckMarkets(markets []Market, defaultMarket string) error {
+	if len(markets) == 0 {
+		markets = DefaultMarkets
+	}
+
+	byName := make(map[string]Market, len(markets))
+	for _, market := range markets {
+		if market.Name == "" {
+			return fmt.Errorf("every market needs a name")
+		}
+		if _, ok := byName[market.Name]; ok {
+			return fmt.Errorf("market %q is listed more than once", market.Name)
+		}
+		byName[market.Name] = market
+	}
+	for _, market := range markets {
+		for _, name := range market.Markets {
+			part, ok := byName[name]
+			switch {
+			case !ok:
+				return fmt.Errorf("market %q uses unknown market %q", market.Name, name)
+			case part.IsUniverse():
+				return fmt.Errorf("market %q can't use the universe market", market.Name)
+			case part.IsComposite():
+				return fmt.Errorf("market %q can't use the composite market %q", market.Name, name)
+			}
+		}
+	}
+
+	// The universe market is always added
+	if _, ok := byName[defaultMarket]; defaultMarket != "" && defaultMarket != UniverseMarketName && !ok {
+		return fmt.Errorf("unknown default market %q", defaultMarket)
+	}
+	return nil
+}
+
+// NormalizeMarkets fills in defaults for the given market list. The universe market is always included because
+// other markets fall back to it when they have too few orders. Composite markets get the regions of their parts.
+func NormalizeMarkets(markets []Market) []Market {
+	if len(markets) == 0 {
+		markets = DefaultMarkets
+	}
+
+	normalized := make([]Market, 0, len(markets)+1)
+	hasUniverse := false
+	for _, market := range markets {
+		if market.DisplayName == "" {
+			market.DisplayName = market.Name
+		}
+		if market.IsUniverse() {
+			hasUniverse = true
+		}
+		normalized = append(normalized, market)
+	}
+
+	if !hasUniverse {
+		normalized = append(normalized, Market{Name: UniverseMarketName, DisplayName: "Universe"})
+	}
+
+	byName := make(map[string]Market, len(normalized))
+	for _, market := range normalized {
+		byName[market.Name] = market
+	}
+	for i, market := range normalized {
+		if !market.IsComposite() {
+			continue
+		}
+		seen := make(map[int64]bool)
+		normalized[i].RegionIDs = nil
+		normalized[i].parts = nil
+		for _, name := range market.Markets {
+			part, ok := byName[name]
+			if !ok || part.IsComposite() {
+				continue
+			}
+			normalized[i].parts = append(normalized[i].parts, part)
+			for _, regionID := range part.RegionIDs {
+				if !seen[regionID] {
+					seen[regionID] = true
+					normalized[i].RegionIDs = append(normalized[i].RegionIDs, regionID)
+				}
+			}
+		}
+	}
+	return normalized
+}
+
+// AllMarkets returns the configured markets followed by a market for each price sheet
+func (app *App) AllMarkets() []Market {
+	if app.PriceSheets == nil {
+		return app.Markets
+	}
+	return append(append([]Market{}, app.Markets...), app.PriceSheets.Markets()...)
+}
+
+// GetMarket returns the configured market or price sheet with the given name
+func (app *App) GetMarket(name string) (Market, bool) {
+	for _, market := range app.AllMarkets() {
+		if market.Name == name {
+			return market, true
+		}
+	}
+	return Market{}, false
+}
+
+// DefaultMarketName returns the market that is used when none is chosen. That is DefaultMarket, or the first market
+// when it isn't set.
+func (app *App) DefaultMarketName() string {
+	if app.DefaultMarket != "" {
+		return app.DefaultMarket
+	}
+	if len(app.Markets) > 0 {
+		return app.Markets[0].Name
+	}
+	return DefaultMarkets[0].Name
+}
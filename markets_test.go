@@ -0,0 +1,58 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompositeMarkets(t *testing.T) {
+	markets := []Market{
+		{Name: "jita", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000142}},
+		{Name: "perimeter", RegionIDs: []int64{10000002}, SystemIDs: []int64{30000144}},
+		{Name: "amarr", RegionIDs: []int64{10000043}, SystemIDs: []int64{30002187}},
+		{Name: "hubs", DisplayName: "Highsec Hubs", Markets: []string{"jita", "perimeter", "amarr"}},
+	}
+	assert.NoError(t, CheckMarkets(markets, ""))
+
+	normalized := NormalizeMarkets(markets)
+	hubs := normalized[3]
+	assert.True(t, hubs.IsComposite())
+	assert.Equal(t, []int64{10000002, 10000043}, hubs.RegionIDs)
+	assert.Len(t, hubs.Parts(), 3)
+	assert.Equal(t, "perimeter", hubs.Parts()[1].Name)
+	assert.False(t, normalized[0].IsComposite())
+
+	assert.Error(t, CheckMarkets(append(markets, Market{Name: "bad", Markets: []string{"nowhere"}}), ""))
+	assert.Error(t, CheckMarkets(append(markets, Market{Name: "bad", Markets: []string{"hubs"}}), ""))
+	assert.Error(t, CheckMarkets(append(markets, Market{Name: UniverseMarketName}, Market{Name: "bad", Markets: []string{UniverseMarketName}}), ""))
+}
+
+func TestCheckMarkets(t *testing.T) {
+	markets := []Market{
+		{Name: "amarr", RegionIDs: []int64{10000043}},
+		{Name: "jita", RegionIDs: []int64{10000002}},
+	}
+	assert.NoError(t, CheckMarkets(markets, ""))
+	assert.NoError(t, CheckMarkets(markets, "jita"))
+	assert.NoError(t, CheckMarkets(markets, UniverseMarketName))
+	assert.Error(t, CheckMarkets(markets, "dodixie"))
+
+	// The default markets are used when none are configured
+	assert.NoError(t, CheckMarkets(nil, "rens"))
+	assert.Error(t, CheckMarkets(nil, "amarr-ish"))
+
+	assert.Error(t, CheckMarkets(append(markets, Market{RegionIDs: []int64{10000032}}), ""))
+	assert.Error(t, CheckMarkets(append(markets, Market{Name: "jita", RegionIDs: []int64{10000032}}), ""))
+}
+
+func TestDefaultMarketName(t *testing.T) {
+	app := &App{}
+	assert.Equal(t, "jita", app.DefaultMarketName())
+
+	app.Markets = NormalizeMarkets([]Market{{Name: "amarr", RegionIDs: []int64{10000043}}})
+	assert.Equal(t, "amarr", app.DefaultMarketName())
+
+	app.DefaultMarket = UniverseMarketName
+	assert.Equal(t, UniverseMarketName, app.DefaultMarketName())
+}
@@ -0,0 +1,137 @@
+package evepraisal
+
+import (
+	"time"
+)
+
+// OrderBookEntry is a single order in an order book
+type OrderBookEntry struct {
+	Price     float64 `json:"price"`
+	Volume    int64   `json:"volume"`
+	MinVolume int64   `json:"min_volume,omitempty"`
+}
+
+// OrderBook holds the individual orders for a type in a market. Buy orders are sorted from the highest to the lowest
+// price and sell orders are sorted from the lowest to the highest price so the best order is always first.
+type OrderBook struct {
+	Buy     []OrderBookEntry `json:"buy"`
+	Sell    []OrderBookEntry `json:"sell"`
+	Updated time.Time        `json:"updated"`
+}
+
+// MarketItemOrderBook is an order book for a type in a market
+type MarketItemOrderBook struct {
+	Market    string
+	TypeID    int64
+	OrderBook OrderBook
+}
+
+// OrderBookFill is the result of selling a quantity of an item against an order book
+type OrderBookFill struct {
+	Quantity     int64   `json:"quantity"`
+	Unfilled     int64   `json:"unfilled"`
+	Total        float64 `json:"total"`
+	AveragePrice float64 `json:"average_price"`
+	TopPrice     float64 `json:"top_price"`
+	WorstPrice   float64 `json:"worst_price"`
+	Slippage     float64 `json:"slippage"`
+}
+
+// Mul multiplies the ISK values of the fill with the given factor
+func (fill OrderBookFill) Mul(multiplier float64) OrderBookFill {
+	fill.Total *= multiplier
+	fill.AveragePrice *= multiplier
+	fill.TopPrice *= multiplier
+	fill.WorstPrice *= multiplier
+	return fill
+}
+
+// undercutAmount is how much cheaper a new sell order is listed than the current best sell order
+var undercutAmount = 0.01
+
+// DumpToBuyOrders walks the buy orders from the highest price down and returns how much ISK selling the given
+// quantity into them would give. Units that don't fit into any buy order are unfilled and are worth nothing.
+func (book OrderBook) DumpToBuyOrders(quantity int64) OrderBookFill {
+	fill := OrderBookFill{}
+	left := quantity
+	for _, order := range book.Buy {
+		if left == 0 {
+			break
+		}
+
+		// We can't sell into orders that want more than we have left
+		if order.MinVolume > left {
+			continue
+		}
+
+		filled := order.Volume
+		if filled > left {
+			filled = left
+		}
+		if fill.TopPrice == 0 {
+			fill.TopPrice = order.Price
+		}
+		fill.WorstPrice = order.Price
+		fill.Total += float64(filled) * order.Price
+		fill.Quantity += filled
+		left -= filled
+	}
+	fill.Unfilled = left
+	fill.finish(quantity)
+	return fill
+}
+
+// UndercutSellOrders returns how much ISK listing the given quantity below the sell orders would give. The units up to
+// the volume of the best sell order are listed just below it. Listing more than that means competing with the orders
+// behind it too, so every time the quantity passes the volume of the sell orders up to a price level, the rest is
+// listed as far below the best price as that level is above it, and undercut again. Units past the end of the book use
+// the last level. If there are no sell orders then nothing can be priced and every unit is unfilled.
+func (book OrderBook) UndercutSellOrders(quantity int64) OrderBookFill {
+	fill := OrderBookFill{}
+	if len(book.Sell) == 0 {
+		fill.Unfilled = quantity
+		return fill
+	}
+
+	top := book.Sell[0].Price
+	fill.TopPrice = top
+	left := quantity
+	for i, order := range book.Sell {
+		if left == 0 {
+			break
+		}
+
+		listed := order.Volume
+		if listed > left || i == len(book.Sell)-1 {
+			listed = left
+		}
+		price := undercutPrice(top - (order.Price - top))
+		fill.WorstPrice = price
+		fill.Total += float64(listed) * price
+		fill.Quantity += listed
+		left -= listed
+	}
+	fill.finish(quantity)
+	return fill
+}
+
+// undercutPrice returns the price of a sell order that undercuts the given price. Prices never go below the smallest
+// price step.
+func undercutPrice(price float64) float64 {
+	price -= undercutAmount
+	if price < undercutAmount {
+		return undercutAmount
+	}
+	return price
+}
+
+// finish calculates the average price and slippage. Slippage is the percentage that the average price per unit
+// (including unfilled units) is worse than the top of the book.
+func (fill *OrderBookFill) finish(quantity int64) {
+	if fill.Quantity > 0 {
+		fill.AveragePrice = fill.Total / float64(fill.Quantity)
+	}
+	if fill.TopPrice > 0 && quantity > 0 {
+		fill.Slippage = (1 - (fill.Total/float64(quantity))/fill.TopPrice) * 100
+	}
+}
@@ -0,0 +1,88 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testOrderBook = OrderBook{
+	Buy: []OrderBookEntry{
+		{Price: 100, Volume: 10},
+		{Price: 90, Volume: 10, MinVolume: 50},
+		{Price: 80, Volume: 20},
+	},
+	Sell: []OrderBookEntry{
+		{Price: 110, Volume: 5},
+		{Price: 120, Volume: 100},
+	},
+}
+
+func TestDumpToBuyOrders(t *testing.T) {
+	// Fits into the top order
+	fill := testOrderBook.DumpToBuyOrders(5)
+	assert.Equal(t, int64(5), fill.Quantity)
+	assert.Equal(t, int64(0), fill.Unfilled)
+	assert.Equal(t, 500.0, fill.Total)
+	assert.Equal(t, 100.0, fill.WorstPrice)
+	assert.Equal(t, 0.0, fill.Slippage)
+
+	// Walks down the book and skips the order with a minimum volume that is too large
+	fill = testOrderBook.DumpToBuyOrders(20)
+	assert.Equal(t, int64(20), fill.Quantity)
+	assert.Equal(t, 1800.0, fill.Total)
+	assert.Equal(t, 90.0, fill.AveragePrice)
+	assert.Equal(t, 80.0, fill.WorstPrice)
+	assert.InDelta(t, 10.0, fill.Slippage, 0.0001)
+
+	// More than the book can take
+	fill = testOrderBook.DumpToBuyOrders(40)
+	assert.Equal(t, int64(30), fill.Quantity)
+	assert.Equal(t, int64(10), fill.Unfilled)
+	assert.Equal(t, 2600.0, fill.Total)
+	assert.InDelta(t, 35.0, fill.Slippage, 0.0001)
+
+	// No buy orders at all
+	fill = OrderBook{}.DumpToBuyOrders(10)
+	assert.Equal(t, int64(10), fill.Unfilled)
+	assert.Equal(t, 0.0, fill.Total)
+}
+
+func TestUndercutSellOrders(t *testing.T) {
+	// Fits below the top order
+	fill := testOrderBook.UndercutSellOrders(5)
+	assert.Equal(t, int64(5), fill.Quantity)
+	assert.Equal(t, 110.0, fill.TopPrice)
+	assert.InDelta(t, 109.99, fill.WorstPrice, 0.0001)
+	assert.InDelta(t, 549.95, fill.Total, 0.0001)
+	assert.InDelta(t, 0.0091, fill.Slippage, 0.0001)
+
+	// Passing the volume of the top order undercuts the next level, mirrored below the top
+	fill = testOrderBook.UndercutSellOrders(10)
+	assert.Equal(t, int64(10), fill.Quantity)
+	assert.Equal(t, 110.0, fill.TopPrice)
+	assert.InDelta(t, 99.99, fill.WorstPrice, 0.0001)
+	assert.InDelta(t, 1049.9, fill.Total, 0.0001)
+	assert.InDelta(t, 4.5545, fill.Slippage, 0.0001)
+
+	// Units past the end of the book use the last level
+	fill = testOrderBook.UndercutSellOrders(200)
+	assert.Equal(t, int64(200), fill.Quantity)
+	assert.Equal(t, int64(0), fill.Unfilled)
+	assert.InDelta(t, 549.95+195*99.99, fill.Total, 0.0001)
+
+	// Levels far above the top can't push the price below the smallest price step
+	fill = OrderBook{Sell: []OrderBookEntry{{Price: 1, Volume: 1}, {Price: 5, Volume: 1}}}.UndercutSellOrders(2)
+	assert.InDelta(t, 0.01, fill.WorstPrice, 0.0001)
+
+	fill = OrderBook{}.UndercutSellOrders(10)
+	assert.Equal(t, int64(10), fill.Unfilled)
+	assert.Equal(t, 0.0, fill.Total)
+}
+
+func TestOrderBookFillMul(t *testing.T) {
+	fill := testOrderBook.DumpToBuyOrders(20).Mul(0.5)
+	assert.Equal(t, 900.0, fill.Total)
+	assert.Equal(t, 45.0, fill.AveragePrice)
+	assert.Equal(t, 50.0, fill.TopPrice)
+}
@@ -29,6 +29,8 @@ type IndustryItem struct {
 	Quantity int64
 	BPC      bool
 	BPCRuns  int64
+	ME       int64
+	TE       int64
 }
 
 var reIndustry = regexp.MustCompile(`^([\S ]+) \(([\d]+) Units?\)$`)
@@ -123,7 +125,7 @@ func ParseIndustry(input Input) (ParserResult, Input) {
 		if count == 0 {
 			count = 1
 		}
-		matchgroup[IndustryItem{Name: match[2], BPC: isBPC, BPCRuns: runCount}] += count
+		matchgroup[IndustryItem{Name: match[2], BPC: isBPC, BPCRuns: runCount, ME: ToInt(match[3]), TE: ToInt(match[4])}] += count
 	}
 	// add items w/totals
 	for item, quantity := range matchgroup {
@@ -35,8 +35,8 @@ Victorieux Luxury Yacht Blueprint	0	0	1	Cruiser
 2 x Medium Warhead Rigor Catalyst I Blueprint	0	0	-1	3	NU4-2G - Writer's Workshop	Item hangar	Rig Launcher`,
 		&Industry{
 			Items: []IndustryItem{
-				{Name: "Cap Booster 3200 Blueprint", Quantity: 1, BPC: true, BPCRuns: 2},
-				{Name: "Deflection Shield Emitter Blueprint", Quantity: 1},
+				{Name: "Cap Booster 3200 Blueprint", Quantity: 1, BPC: true, BPCRuns: 2, ME: 10},
+				{Name: "Deflection Shield Emitter Blueprint", Quantity: 1, ME: 10, TE: 20},
 				{Name: "Medium Warhead Rigor Catalyst I Blueprint", Quantity: 2, BPC: true, BPCRuns: 3},
 				{Name: "Victorieux Luxury Yacht Blueprint", Quantity: 1, BPC: true, BPCRuns: 1},
 			},
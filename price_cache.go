@@ -0,0 +1,120 @@
+package evepraisal
+
+import (
+	"sync"
+)
+
+// DefaultPriceCacheSize is how many prices the price cache holds when no size is configured
+const DefaultPriceCacheSize = 200000
+
+type cachedPrice struct {
+	prices Prices
+	ok     bool
+}
+
+// PriceCache keeps decoded prices in memory in front of a PriceDB. Types without prices are remembered too. Prices
+// that are updated through the cache are dropped from it once the update is saved, so the cache never serves a price
+// that is older than the PriceDB's.
+type PriceCache struct {
+	PriceDB
+	maxEntries int
+
+	lock    sync.RWMutex
+	entries map[marketTypeKey]cachedPrice
+	// generation changes on every update. Prices that were read before an update are only cached if the generation
+	// hasn't changed because they might already be stale.
+	generation uint64
+}
+
+// NewPriceCache returns a cache in front of priceDB that holds up to maxEntries prices. The cache starts over when it
+// is full.
+func NewPriceCache(priceDB PriceDB, maxEntries int) *PriceCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultPriceCacheSize
+	}
+	return &PriceCache{
+		PriceDB:    priceDB,
+		maxEntries: maxEntries,
+		entries:    make(map[marketTypeKey]cachedPrice),
+	}
+}
+
+// GetPrice returns the price of a type in a market from the cache, reading it from the PriceDB if it isn't cached
+func (c *PriceCache) GetPrice(market string, typeID int64) (Prices, bool) {
+	key := marketTypeKey{market: market, typeID: typeID}
+	c.lock.RLock()
+	entry, hit := c.entries[key]
+	generation := c.generation
+	c.lock.RUnlock()
+	if hit {
+		return entry.prices, entry.ok
+	}
+
+	prices, ok := c.PriceDB.GetPrice(market, typeID)
+	c.store(generation, map[marketTypeKey]cachedPrice{key: {prices: prices, ok: ok}})
+	return prices, ok
+}
+
+// GetPrices returns the prices of the given types in a market. The types that aren't cached are read from the
+// PriceDB all at once.
+func (c *PriceCache) GetPrices(market string, typeIDs []int64) (map[int64]Prices, error) {
+	prices := make(map[int64]Prices, len(typeIDs))
+	missing := make([]int64, 0)
+	c.lock.RLock()
+	for _, typeID := range typeIDs {
+		entry, hit := c.entries[marketTypeKey{market: market, typeID: typeID}]
+		if !hit {
+			missing = append(missing, typeID)
+		} else if entry.ok {
+			prices[typeID] = entry.prices
+		}
+	}
+	generation := c.generation
+	c.lock.RUnlock()
+	if len(missing) == 0 {
+		return prices, nil
+	}
+
+	fetched, err := c.PriceDB.GetPrices(market, missing)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[marketTypeKey]cachedPrice, len(missing))
+	for _, typeID := range missing {
+		p, ok := fetched[typeID]
+		entries[marketTypeKey{market: market, typeID: typeID}] = cachedPrice{prices: p, ok: ok}
+		if ok {
+			prices[typeID] = p
+		}
+	}
+	c.store(generation, entries)
+	return prices, nil
+}
+
+// store caches prices that were read from the PriceDB at the given generation
+func (c *PriceCache) store(generation uint64, entries map[marketTypeKey]cachedPrice) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generation != generation {
+		return
+	}
+	if len(c.entries)+len(entries) > c.maxEntries {
+		c.entries = make(map[marketTypeKey]cachedPrice)
+	}
+	for key, entry := range entries {
+		c.entries[key] = entry
+	}
+}
+
+// UpdatePrices saves the prices to the PriceDB and then drops them from the cache
+func (c *PriceCache) UpdatePrices(items []MarketItemPrices) error {
+	err := c.PriceDB.UpdatePrices(items)
+
+	c.lock.Lock()
+	c.generation++
+	for _, item := range items {
+		delete(c.entries, marketTypeKey{market: item.Market, typeID: item.TypeID})
+	}
+	c.lock.Unlock()
+	return err
+}
@@ -0,0 +1,119 @@
+package evepraisal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEncodedPriceDB keeps prices encoded like the bolt PriceDB does, so every read has to decode them
+type testEncodedPriceDB struct {
+	PriceDB
+	prices map[marketTypeKey][]byte
+	reads  int
+}
+
+func (db *testEncodedPriceDB) GetPrice(market string, typeID int64) (Prices, bool) {
+	db.reads++
+	return db.decode(market, typeID)
+}
+
+func (db *testEncodedPriceDB) GetPrices(market string, typeIDs []int64) (map[int64]Prices, error) {
+	db.reads++
+	prices := make(map[int64]Prices)
+	for _, typeID := range typeIDs {
+		if p, ok := db.decode(market, typeID); ok {
+			prices[typeID] = p
+		}
+	}
+	return prices, nil
+}
+
+func (db *testEncodedPriceDB) decode(market string, typeID int64) (Prices, bool) {
+	var prices Prices
+	buf, ok := db.prices[marketTypeKey{market: market, typeID: typeID}]
+	if !ok {
+		return prices, false
+	}
+	err := json.Unmarshal(buf, &prices)
+	return prices, err == nil
+}
+
+func (db *testEncodedPriceDB) UpdatePrices(items []MarketItemPrices) error {
+	if db.prices == nil {
+		db.prices = make(map[marketTypeKey][]byte)
+	}
+	for _, item := range items {
+		buf, err := json.Marshal(item.Prices)
+		if err != nil {
+			return err
+		}
+		db.prices[marketTypeKey{market: item.Market, typeID: item.TypeID}] = buf
+	}
+	return nil
+}
+
+func (db *testEncodedPriceDB) GetMarketHistory(regionID int64, typeID int64) (MarketHistory, bool) {
+	return MarketHistory{}, false
+}
+
+func TestPriceCache(t *testing.T) {
+	db := &testEncodedPriceDB{}
+	cache := NewPriceCache(db, 10)
+	tritanium := Prices{Strategy: "orders"}.Set(5)
+	assert.NoError(t, cache.UpdatePrices([]MarketItemPrices{{Market: "jita", TypeID: 34, Prices: tritanium}}))
+
+	prices, ok := cache.GetPrice("jita", 34)
+	assert.True(t, ok)
+	assert.Equal(t, tritanium, prices)
+	_, ok = cache.GetPrice("jita", 35)
+	assert.False(t, ok)
+	assert.Equal(t, 2, db.reads)
+
+	// Prices and missing prices are both cached
+	_, ok = cache.GetPrice("jita", 34)
+	assert.True(t, ok)
+	_, ok = cache.GetPrice("jita", 35)
+	assert.False(t, ok)
+	assert.Equal(t, 2, db.reads)
+
+	// Only the types that aren't cached are read
+	all, err := cache.GetPrices("jita", []int64{34, 35, 36})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]Prices{34: tritanium}, all)
+	assert.Equal(t, 3, db.reads)
+	_, err = cache.GetPrices("jita", []int64{34, 35, 36})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, db.reads)
+
+	// Updates replace cached prices
+	pyerite := Prices{Strategy: "orders"}.Set(10)
+	assert.NoError(t, cache.UpdatePrices([]MarketItemPrices{{Market: "jita", TypeID: 35, Prices: pyerite}}))
+	prices, ok = cache.GetPrice("jita", 35)
+	assert.True(t, ok)
+	assert.Equal(t, pyerite, prices)
+
+	// The cache starts over when it's full
+	for typeID := int64(100); typeID < 120; typeID++ {
+		cache.GetPrice("jita", typeID)
+	}
+	assert.True(t, len(cache.entries) <= 10)
+}
+
+func TestPriceCacheStaleRead(t *testing.T) {
+	db := &testEncodedPriceDB{}
+	cache := NewPriceCache(db, 0)
+	old := Prices{Strategy: "orders"}.Set(5)
+	assert.NoError(t, db.UpdatePrices([]MarketItemPrices{{Market: "jita", TypeID: 34, Prices: old}}))
+
+	// A read that started before an update isn't cached because it may be older than the update
+	generation := cache.generation
+	prices, _ := db.GetPrice("jita", 34)
+	assert.NoError(t, cache.UpdatePrices([]MarketItemPrices{{Market: "jita", TypeID: 34, Prices: Prices{Strategy: "orders"}.Set(6)}}))
+	cache.store(generation, map[marketTypeKey]cachedPrice{{market: "jita", typeID: 34}: {prices: prices, ok: true}})
+
+	prices, ok := cache.GetPrice("jita", 34)
+	assert.True(t, ok)
+	assert.Equal(t, 6.0, prices.Sell.Min)
+}
@@ -0,0 +1,106 @@
+package evepraisal
+
+import (
+	"fmt"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// CCPMarketName is the pseudo-market that CCP's average prices are stored under
+const CCPMarketName = "ccp"
+
+// Steps of the chain that is used to find a price for an item
+const (
+	// FallbackOrders uses the prices from the orders in the appraisal's market
+	FallbackOrders = "orders"
+	// FallbackUniverse uses the prices from the orders in every region
+	FallbackUniverse = "universe"
+	// FallbackCCP uses CCP's average price
+	FallbackCCP = "ccp"
+	// FallbackComponents uses the value of the components needed to build the item
+	FallbackComponents = "components"
+	// FallbackBasePrice uses the base price from the static data export
+	FallbackBasePrice = "base_price"
+)
+
+// DefaultPriceFallbacks is the order that prices are looked for when none is configured
+var DefaultPriceFallbacks = []string{FallbackOrders, FallbackUniverse, FallbackCCP, FallbackComponents, FallbackBasePrice}
+
+// CheckPriceFallbacks returns an error if any of the given fallback steps don't exist
+func CheckPriceFallbacks(fallbacks []string) error {
+	for _, fallback := range fallbacks {
+		switch fallback {
+		case FallbackOrders, FallbackUniverse, FallbackCCP, FallbackComponents, FallbackBasePrice:
+		default:
+			return fmt.Errorf("Unknown price fallback: %q", fallback)
+		}
+	}
+	return nil
+}
+
+// hasPrice returns true if the prices can value an item
+func (prices Prices) hasPrice() bool {
+	return prices.Sell.Min > 0 || prices.Buy.Max > 0
+}
+
+// isMarketPrice returns true if the prices come from the market itself. Price sources also store the universe price
+// or CCP's price under a market's name when it has too few orders, and those copies aren't the market's orders.
+func isMarketPrice(market string, prices Prices) bool {
+	switch prices.Strategy {
+	case "orders", "price_sheet":
+		return true
+	case "", "orders_universe":
+		return market == UniverseMarketName
+	}
+	return false
+}
+
+// fallbackPrices tries each step of the price fallback chain in order and returns the first one that gives a price.
+// Prices.Strategy is set to the step that was used. If no step gives a price, the (empty) market prices are returned.
+func (app *App) fallbackPrices(market string, t typedb.EveType) Prices {
+	fallbacks := app.PriceFallbacks
+	if len(fallbacks) == 0 {
+		fallbacks = DefaultPriceFallbacks
+	}
+
+	marketPrices, _ := app.PriceDB.GetPrice(market, t.ID)
+	if !isMarketPrice(market, marketPrices) {
+		marketPrices = Prices{}
+	}
+	for _, fallback := range fallbacks {
+		switch fallback {
+		case FallbackOrders:
+			if marketPrices.hasPrice() {
+				return marketPrices
+			}
+		case FallbackUniverse:
+			if market == UniverseMarketName {
+				continue
+			}
+			prices, ok := app.PriceDB.GetPrice(UniverseMarketName, t.ID)
+			if ok && prices.hasPrice() {
+				if prices.Strategy == "" {
+					prices.Strategy = "orders_universe"
+				}
+				return prices
+			}
+		case FallbackCCP:
+			prices, ok := app.PriceDB.GetPrice(CCPMarketName, t.ID)
+			if ok && prices.hasPrice() {
+				prices.Strategy = "ccp"
+				return prices
+			}
+		case FallbackComponents:
+			prices := priceByComponents(t, app.PriceDB, market)
+			if prices.hasPrice() {
+				prices.Strategy = "component"
+				return prices
+			}
+		case FallbackBasePrice:
+			if t.BasePrice > 0 {
+				return Prices{Strategy: "base_price"}.Set(t.BasePrice)
+			}
+		}
+	}
+	return marketPrices
+}
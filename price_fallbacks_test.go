@@ -0,0 +1,92 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFallbackPriceDB struct {
+	PriceDB
+	prices map[string]map[int64]Prices
+}
+
+func (db testFallbackPriceDB) GetPrice(market string, typeID int64) (Prices, bool) {
+	prices, ok := db.prices[market][typeID]
+	return prices, ok
+}
+
+func (db testFallbackPriceDB) GetPrices(market string, typeIDs []int64) (map[int64]Prices, error) {
+	prices := make(map[int64]Prices)
+	for _, typeID := range typeIDs {
+		if p, ok := db.prices[market][typeID]; ok {
+			prices[typeID] = p
+		}
+	}
+	return prices, nil
+}
+
+func TestPricesForItemFallbacks(t *testing.T) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			1: {ID: 1},
+			2: {ID: 2},
+			3: {ID: 3},
+			4: {ID: 4, Components: []typedb.Component{{TypeID: 1, Quantity: 10}}},
+			5: {ID: 5, BasePrice: 1234},
+			6: {ID: 6},
+		}},
+		PriceDB: testFallbackPriceDB{prices: map[string]map[int64]Prices{
+			"jita": {
+				1: Prices{Strategy: "orders"}.Set(10),
+				// Orders without a price don't count
+				2: {Strategy: "orders", Sell: PriceStats{OrderCount: 0}},
+			},
+			UniverseMarketName: {
+				2: Prices{}.Set(20),
+			},
+			CCPMarketName: {
+				3: Prices{Strategy: "ccp"}.Set(30),
+			},
+		}},
+	}
+
+	strategyAndPrice := func(typeID int64) (string, float64) {
+		prices, err := app.PricesForItem("jita", AppraisalItem{TypeID: typeID})
+		assert.NoError(t, err)
+		return prices.Strategy, prices.Sell.Min
+	}
+
+	strategy, price := strategyAndPrice(1)
+	assert.Equal(t, "orders", strategy)
+	assert.Equal(t, 10.0, price)
+
+	strategy, price = strategyAndPrice(2)
+	assert.Equal(t, "orders_universe", strategy)
+	assert.Equal(t, 20.0, price)
+
+	strategy, price = strategyAndPrice(3)
+	assert.Equal(t, "ccp", strategy)
+	assert.Equal(t, 30.0, price)
+
+	strategy, price = strategyAndPrice(4)
+	assert.Equal(t, "component", strategy)
+	assert.Equal(t, 100.0, price)
+
+	strategy, price = strategyAndPrice(5)
+	assert.Equal(t, "base_price", strategy)
+	assert.Equal(t, 1234.0, price)
+
+	strategy, price = strategyAndPrice(6)
+	assert.Equal(t, "", strategy)
+	assert.Equal(t, 0.0, price)
+
+	// Steps that aren't configured are skipped
+	app.PriceFallbacks = []string{FallbackOrders, FallbackBasePrice}
+	strategy, _ = strategyAndPrice(3)
+	assert.Equal(t, "", strategy)
+
+	assert.NoError(t, CheckPriceFallbacks(DefaultPriceFallbacks))
+	assert.Error(t, CheckPriceFallbacks([]string{"orders", "magic"}))
+}
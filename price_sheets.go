@@ -0,0 +1,275 @@
+package evepraisal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+)
+
+// ErrPriceSheetNotFound is returned whenever a price sheet by a given name can't be found
+var ErrPriceSheetNotFound = errors.New("Price sheet not found")
+
+// PriceSheet is a list of prices that an administrator sets for items without a usable market. Every sheet can be
+// selected as a market of its own.
+type PriceSheet struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	// Overlay is the market whose prices are used for items that aren't on the sheet. Without one, those items go
+	// straight to the rest of the price fallbacks.
+	Overlay string           `json:"overlay,omitempty"`
+	Items   []PriceSheetItem `json:"items"`
+	Updated time.Time        `json:"updated"`
+}
+
+// PriceSheetItem is the price of a single type on a price sheet. Name is only used to find the type ID when a sheet is
+// uploaded.
+type PriceSheetItem struct {
+	TypeID int64   `json:"type_id"`
+	Name   string  `json:"name,omitempty"`
+	Buy    float64 `json:"buy"`
+	Sell   float64 `json:"sell"`
+}
+
+// Prices returns the prices of the item in the same form as prices that come from orders
+func (item PriceSheetItem) Prices() Prices {
+	prices := Prices{Strategy: "price_sheet"}.Set(item.Sell)
+	prices.Buy = Prices{}.Set(item.Buy).Buy
+	return prices
+}
+
+// Market returns the market that the sheet is selectable as
+func (sheet PriceSheet) Market() Market {
+	displayName := sheet.DisplayName
+	if displayName == "" {
+		displayName = sheet.Name
+	}
+	return Market{Name: sheet.Name, DisplayName: displayName}
+}
+
+// ResolveTypes fills in the type ID of every item that only has a name. An error is returned for the first item whose
+// type can't be found.
+func (sheet *PriceSheet) ResolveTypes(typeDB typedb.TypeDB) error {
+	for i, item := range sheet.Items {
+		if item.TypeID != 0 {
+			continue
+		}
+		t, ok := typeDB.GetType(item.Name)
+		if !ok {
+			return fmt.Errorf("item %d: unknown type %q", i, item.Name)
+		}
+		sheet.Items[i].TypeID = t.ID
+	}
+	return nil
+}
+
+// Validate returns an error if the sheet can't be used
+func (sheet PriceSheet) Validate() error {
+	if !managedNameRe.MatchString(sheet.Name) {
+		return fmt.Errorf("name must be 1-64 letters, numbers, dashes or underscores")
+	}
+	// Other markets fall back to these, so a sheet can't take their place
+	if sheet.Name == CCPMarketName || sheet.Name == UniverseMarketName {
+		return fmt.Errorf("%q is reserved", sheet.Name)
+	}
+	if sheet.Overlay == sheet.Name {
+		return fmt.Errorf("a sheet can't overlay itself")
+	}
+	for i, item := range sheet.Items {
+		if item.TypeID <= 0 {
+			return fmt.Errorf("item %d: type_id is required", i)
+		}
+		if item.Buy < 0 || item.Sell < 0 {
+			return fmt.Errorf("item %d: prices can't be negative", i)
+		}
+	}
+	return nil
+}
+
+// ParsePriceSheetCSV reads price sheet items from CSV with the columns: type ID or name, buy, sell. A header row is
+// skipped if there is one.
+func ParsePriceSheetCSV(r io.Reader) ([]PriceSheetItem, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var items []PriceSheetItem
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		buy, buyErr := strconv.ParseFloat(record[1], 64)
+		sell, sellErr := strconv.ParseFloat(record[2], 64)
+		if buyErr != nil || sellErr != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: buy and sell must be numbers", line)
+		}
+
+		item := PriceSheetItem{Buy: buy, Sell: sell}
+		typeID, err := strconv.ParseInt(record[0], 10, 64)
+		if err == nil {
+			item.TypeID = typeID
+		} else {
+			item.Name = strings.TrimSpace(record[0])
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+type loadedPriceSheet struct {
+	PriceSheet
+	prices map[int64]Prices
+}
+
+func loadPriceSheet(sheet PriceSheet) loadedPriceSheet {
+	loaded := loadedPriceSheet{PriceSheet: sheet, prices: make(map[int64]Prices, len(sheet.Items))}
+	for _, item := range sheet.Items {
+		loaded.prices[item.TypeID] = item.Prices()
+	}
+	return loaded
+}
+
+// PriceSheets serves the prices on price sheets through GetPrice, using the sheet's name as the market. Everything
+// else is passed through to the wrapped PriceDB.
+type PriceSheets struct {
+	PriceDB
+	db PriceSheetDB
+
+	sheets map[string]loadedPriceSheet
+	lock   sync.RWMutex
+}
+
+// NewPriceSheets returns a PriceDB that serves the price sheets stored in the given database on top of priceDB
+func NewPriceSheets(priceDB PriceDB, db PriceSheetDB) (*PriceSheets, error) {
+	sheets, err := db.ListPriceSheets()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PriceSheets{PriceDB: priceDB, db: db, sheets: make(map[string]loadedPriceSheet, len(sheets))}
+	for _, sheet := range sheets {
+		s.sheets[sheet.Name] = loadPriceSheet(sheet)
+	}
+	return s, nil
+}
+
+// GetPrice returns the price from the sheet named by market, falling back to the sheet's overlay market. Markets that
+// aren't price sheets are looked up in the wrapped PriceDB.
+func (s *PriceSheets) GetPrice(market string, typeID int64) (Prices, bool) {
+	s.lock.RLock()
+	sheet, ok := s.sheets[market]
+	s.lock.RUnlock()
+	if !ok {
+		return s.PriceDB.GetPrice(market, typeID)
+	}
+
+	prices, ok := sheet.prices[typeID]
+	if ok {
+		return prices, true
+	}
+	if sheet.Overlay != "" {
+		return s.PriceDB.GetPrice(sheet.Overlay, typeID)
+	}
+	return Prices{}, false
+}
+
+// GetPrices returns the prices of the given types from the sheet named by market, falling back to the sheet's overlay
+// market. Markets that aren't price sheets are looked up in the wrapped PriceDB.
+func (s *PriceSheets) GetPrices(market string, typeIDs []int64) (map[int64]Prices, error) {
+	s.lock.RLock()
+	sheet, ok := s.sheets[market]
+	s.lock.RUnlock()
+	if !ok {
+		return s.PriceDB.GetPrices(market, typeIDs)
+	}
+
+	prices := make(map[int64]Prices, len(typeIDs))
+	missing := make([]int64, 0)
+	for _, typeID := range typeIDs {
+		if p, ok := sheet.prices[typeID]; ok {
+			prices[typeID] = p
+		} else {
+			missing = append(missing, typeID)
+		}
+	}
+	if sheet.Overlay == "" || len(missing) == 0 {
+		return prices, nil
+	}
+
+	overlayPrices, err := s.PriceDB.GetPrices(sheet.Overlay, missing)
+	if err != nil {
+		return nil, err
+	}
+	for typeID, p := range overlayPrices {
+		prices[typeID] = p
+	}
+	return prices, nil
+}
+
+// Markets returns a market for every price sheet, ordered by name
+func (s *PriceSheets) Markets() []Market {
+	sheets := s.ListPriceSheets()
+	markets := make([]Market, len(sheets))
+	for i, sheet := range sheets {
+		markets[i] = sheet.Market()
+	}
+	return markets
+}
+
+// ListPriceSheets returns every price sheet, ordered by name
+func (s *PriceSheets) ListPriceSheets() []PriceSheet {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	sheets := make([]PriceSheet, 0, len(s.sheets))
+	for _, sheet := range s.sheets {
+		sheets = append(sheets, sheet.PriceSheet)
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].Name < sheets[j].Name })
+	return sheets
+}
+
+// GetPriceSheet returns the price sheet with the given name
+func (s *PriceSheets) GetPriceSheet(name string) (PriceSheet, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	sheet, ok := s.sheets[name]
+	return sheet.PriceSheet, ok
+}
+
+// PutPriceSheet stores the given sheet, replacing any sheet with the same name
+func (s *PriceSheets) PutPriceSheet(sheet PriceSheet) error {
+	err := s.db.PutPriceSheet(sheet)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.sheets[sheet.Name] = loadPriceSheet(sheet)
+	s.lock.Unlock()
+	return nil
+}
+
+// DeletePriceSheet deletes the sheet with the given name
+func (s *PriceSheets) DeletePriceSheet(name string) error {
+	err := s.db.DeletePriceSheet(name)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	delete(s.sheets, name)
+	s.lock.Unlock()
+	return nil
+}
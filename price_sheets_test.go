@@ -0,0 +1,133 @@
+package evepraisal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPriceSheetDB struct {
+	sheets map[string]PriceSheet
+}
+
+func (db *testPriceSheetDB) ListPriceSheets() ([]PriceSheet, error) {
+	sheets := make([]PriceSheet, 0, len(db.sheets))
+	for _, sheet := range db.sheets {
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+func (db *testPriceSheetDB) PutPriceSheet(sheet PriceSheet) error {
+	db.sheets[sheet.Name] = sheet
+	return nil
+}
+
+func (db *testPriceSheetDB) DeletePriceSheet(name string) error {
+	if _, ok := db.sheets[name]; !ok {
+		return ErrPriceSheetNotFound
+	}
+	delete(db.sheets, name)
+	return nil
+}
+
+func (db *testPriceSheetDB) Close() error { return nil }
+
+func TestParsePriceSheetCSV(t *testing.T) {
+	items, err := ParsePriceSheetCSV(strings.NewReader("type,buy,sell\n34,4.5,5\nPyerite, 10,12\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []PriceSheetItem{
+		{TypeID: 34, Buy: 4.5, Sell: 5},
+		{Name: "Pyerite", Buy: 10, Sell: 12},
+	}, items)
+
+	_, err = ParsePriceSheetCSV(strings.NewReader("34,4.5,5\n35,ten,12\n"))
+	assert.Error(t, err)
+
+	_, err = ParsePriceSheetCSV(strings.NewReader("34,4.5\n"))
+	assert.Error(t, err)
+}
+
+func TestPriceSheetResolveTypes(t *testing.T) {
+	typeDB := testIndustryTypeDB{types: map[string]typedb.EveType{"Pyerite": {ID: 35, Name: "Pyerite"}}}
+
+	sheet := PriceSheet{Name: "corp", Items: []PriceSheetItem{{TypeID: 34}, {Name: "Pyerite"}}}
+	assert.NoError(t, sheet.ResolveTypes(typeDB))
+	assert.Equal(t, int64(35), sheet.Items[1].TypeID)
+	assert.NoError(t, sheet.Validate())
+
+	sheet.Items = append(sheet.Items, PriceSheetItem{Name: "Unobtainium"})
+	assert.Error(t, sheet.ResolveTypes(typeDB))
+
+	assert.Error(t, PriceSheet{Name: "corp", Overlay: "corp"}.Validate())
+	assert.Error(t, PriceSheet{Name: CCPMarketName}.Validate())
+	assert.Error(t, PriceSheet{Name: UniverseMarketName}.Validate())
+	assert.Error(t, PriceSheet{Name: "corp", Items: []PriceSheetItem{{TypeID: 34, Buy: -1}}}.Validate())
+}
+
+func TestPriceSheets(t *testing.T) {
+	db := &testPriceSheetDB{sheets: map[string]PriceSheet{
+		"alliance": {
+			Name:        "alliance",
+			DisplayName: "Alliance Stock",
+			Items:       []PriceSheetItem{{TypeID: 34, Buy: 4, Sell: 5}},
+		},
+	}}
+	priceDB := testFallbackPriceDB{prices: map[string]map[int64]Prices{
+		"jita": {
+			34: Prices{Strategy: "orders"}.Set(6),
+			35: Prices{Strategy: "orders"}.Set(11),
+		},
+	}}
+
+	sheets, err := NewPriceSheets(priceDB, db)
+	assert.NoError(t, err)
+	app := &App{PriceDB: sheets, PriceSheets: sheets, Markets: []Market{{Name: "jita", DisplayName: "Jita"}}}
+
+	prices, ok := app.PriceDB.GetPrice("alliance", 34)
+	assert.True(t, ok)
+	assert.Equal(t, "price_sheet", prices.Strategy)
+	assert.Equal(t, 4.0, prices.Buy.Max)
+	assert.Equal(t, 5.0, prices.Sell.Min)
+
+	// Items that aren't on a sheet without an overlay have no price
+	_, ok = app.PriceDB.GetPrice("alliance", 35)
+	assert.False(t, ok)
+
+	// Other markets are passed through
+	prices, ok = app.PriceDB.GetPrice("jita", 34)
+	assert.True(t, ok)
+	assert.Equal(t, 6.0, prices.Sell.Min)
+
+	market, ok := app.GetMarket("alliance")
+	assert.True(t, ok)
+	assert.Equal(t, "Alliance Stock", market.DisplayName)
+	assert.Equal(t, []Market{{Name: "jita", DisplayName: "Jita"}, {Name: "alliance", DisplayName: "Alliance Stock"}}, app.AllMarkets())
+
+	// Overlaid sheets fall back to the overlay's prices
+	assert.NoError(t, sheets.PutPriceSheet(PriceSheet{
+		Name:    "overlay",
+		Overlay: "jita",
+		Items:   []PriceSheetItem{{TypeID: 34, Buy: 1, Sell: 2}},
+	}))
+	prices, _ = app.PriceDB.GetPrice("overlay", 34)
+	assert.Equal(t, 2.0, prices.Sell.Min)
+	prices, ok = app.PriceDB.GetPrice("overlay", 35)
+	assert.True(t, ok)
+	assert.Equal(t, "orders", prices.Strategy)
+	assert.Equal(t, 11.0, prices.Sell.Min)
+	assert.Contains(t, db.sheets, "overlay")
+
+	bulk, err := app.PriceDB.GetPrices("overlay", []int64{34, 35, 36})
+	assert.NoError(t, err)
+	assert.Len(t, bulk, 2)
+	assert.Equal(t, "price_sheet", bulk[34].Strategy)
+	assert.Equal(t, 11.0, bulk[35].Sell.Min)
+
+	assert.NoError(t, sheets.DeletePriceSheet("overlay"))
+	_, ok = app.GetMarket("overlay")
+	assert.False(t, ok)
+	assert.Equal(t, ErrPriceSheetNotFound, sheets.DeletePriceSheet("overlay"))
+}
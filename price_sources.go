@@ -0,0 +1,275 @@
+package evepraisal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PriceSource is somewhere that prices come from. A source is started with the PriceDB that it writes its prices to
+// and keeps them up to date in the background until it is closed.
+type PriceSource interface {
+	Start(db PriceDB) error
+	Close() error
+}
+
+// Types of price sources
+const (
+	// PriceSourceESIOrders aggregates the market orders of every market from ESI
+	PriceSourceESIOrders = "esi_orders"
+	// PriceSourceESICCP is CCP's average price for every type from ESI
+	PriceSourceESICCP = "esi_ccp"
+	// PriceSourceStatic is a price sheet file
+	PriceSourceStatic = "static"
+	// PriceSourceJSONHTTP is another price aggregator that serves prices as JSON over HTTP
+	PriceSourceJSONHTTP = "json_http"
+)
+
+// Rules for merging the prices of a source with the prices of higher priority sources
+const (
+	// PriceMergeWhole only uses the source's price when no higher priority source has a usable price
+	PriceMergeWhole = "whole"
+	// PriceMergeFill also fills in the buy or sell side of a higher priority price that has nothing on that side
+	PriceMergeFill = "fill"
+)
+
+// PriceSourceConfig configures a single price source. When more than one source has a price for a type in a market,
+// the price of the source with the highest priority is used.
+type PriceSourceConfig struct {
+	Name     string `mapstructure:"name" json:"name"`
+	Type     string `mapstructure:"type" json:"type"`
+	Priority int    `mapstructure:"priority" json:"priority"`
+	// Merge is PriceMergeWhole (the default) or PriceMergeFill
+	Merge string `mapstructure:"merge" json:"merge"`
+	// MinSellVolume skips the source's prices when they have fewer items for sale than this, unless no other source
+	// has a price at all
+	MinSellVolume int64 `mapstructure:"min_sell_volume" json:"min_sell_volume,omitempty"`
+	// Markets are the markets that the source's prices are used for. Every market is used when this is empty.
+	Markets []string `mapstructure:"markets" json:"markets,omitempty"`
+	// URL is where a json_http source fetches prices from. "{type_ids}" is replaced with a comma separated batch of
+	// type IDs.
+	URL string `mapstructure:"url" json:"url,omitempty"`
+	// Path is the price sheet file of a static source
+	Path string `mapstructure:"path" json:"path,omitempty"`
+	// Interval is how often json_http and static sources check for new prices
+	Interval time.Duration `mapstructure:"interval" json:"interval,omitempty"`
+}
+
+// DefaultPriceSources are used when no price sources are configured. Prices come from ESI's market orders and CCP's
+// price is kept in the "ccp" market for the price fallbacks.
+var DefaultPriceSources = []PriceSourceConfig{
+	{Name: PriceSourceESIOrders, Type: PriceSourceESIOrders, Priority: 20, MinSellVolume: 10},
+	{Name: PriceSourceESICCP, Type: PriceSourceESICCP, Priority: 10},
+}
+
+// HasMarket returns true if the source's prices are used for the given market
+func (config PriceSourceConfig) HasMarket(market string) bool {
+	if len(config.Markets) == 0 {
+		return true
+	}
+	for _, name := range config.Markets {
+		if name == market {
+			return true
+		}
+	}
+	return false
+}
+
+// MarketNames returns the names of the given markets that the source's prices are used for
+func (config PriceSourceConfig) MarketNames(markets []Market) []string {
+	names := make([]string, 0, len(markets))
+	for _, market := range markets {
+		if config.HasMarket(market.Name) {
+			names = append(names, market.Name)
+		}
+	}
+	return names
+}
+
+// NormalizePriceSources fills in defaults for the given price sources and orders them by priority, highest first
+func NormalizePriceSources(sources []PriceSourceConfig) []PriceSourceConfig {
+	if len(sources) == 0 {
+		sources = DefaultPriceSources
+	}
+
+	normalized := make([]PriceSourceConfig, len(sources))
+	for i, source := range sources {
+		if source.Name == "" {
+			source.Name = source.Type
+		}
+		if source.Merge == "" {
+			source.Merge = PriceMergeWhole
+		}
+		normalized[i] = source
+	}
+	sort.SliceStable(normalized, func(i, j int) bool { return normalized[i].Priority > normalized[j].Priority })
+	return normalized
+}
+
+// CheckPriceSources returns an error if one of the (normalized) price sources can't be used with the given markets
+func CheckPriceSources(sources []PriceSourceConfig, markets []Market) error {
+	marketNames := map[string]bool{CCPMarketName: true}
+	for _, market := range markets {
+		marketNames[market.Name] = true
+	}
+
+	names := make(map[string]bool, len(sources))
+	esiOrders := 0
+	for _, source := range sources {
+		if names[source.Name] {
+			return fmt.Errorf("price source %q is listed more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		switch source.Type {
+		case PriceSourceESIOrders:
+			esiOrders++
+			if esiOrders > 1 {
+				return fmt.Errorf("price source %q: only one %s source can be used", source.Name, PriceSourceESIOrders)
+			}
+		case PriceSourceESICCP:
+		case PriceSourceStatic:
+			if source.Path == "" {
+				return fmt.Errorf("price source %q: path is required", source.Name)
+			}
+		case PriceSourceJSONHTTP:
+			if source.URL == "" {
+				return fmt.Errorf("price source %q: url is required", source.Name)
+			}
+		default:
+			return fmt.Errorf("price source %q has unknown type %q", source.Name, source.Type)
+		}
+
+		if source.Merge != PriceMergeWhole && source.Merge != PriceMergeFill {
+			return fmt.Errorf("price source %q: merge must be %q or %q", source.Name, PriceMergeWhole, PriceMergeFill)
+		}
+		if source.MinSellVolume < 0 {
+			return fmt.Errorf("price source %q: min_sell_volume can't be negative", source.Name)
+		}
+		for _, market := range source.Markets {
+			if !marketNames[market] {
+				return fmt.Errorf("price source %q uses unknown market %q", source.Name, market)
+			}
+		}
+	}
+	return nil
+}
+
+type marketTypeKey struct {
+	market string
+	typeID int64
+}
+
+// PriceMerger combines the prices of several sources and writes the result to a PriceDB. It keeps the latest price
+// from every source so that the merged price can be recalculated whenever one of them changes.
+type PriceMerger struct {
+	PriceDB
+	sources []PriceSourceConfig
+
+	// prices has a price (or nil) for each source, in the same order as sources
+	prices map[marketTypeKey][]*Prices
+	lock   sync.Mutex
+}
+
+// NewPriceMerger returns a PriceMerger for the given (normalized) sources that writes to priceDB
+func NewPriceMerger(priceDB PriceDB, sources []PriceSourceConfig) *PriceMerger {
+	return &PriceMerger{
+		PriceDB: priceDB,
+		sources: sources,
+		prices:  make(map[marketTypeKey][]*Prices),
+	}
+}
+
+// SourceDB returns the PriceDB that the named source should be started with. Prices written to it are merged with
+// those of the other sources; everything else goes straight to the merger's PriceDB.
+func (m *PriceMerger) SourceDB(name string) (PriceDB, error) {
+	for i, source := range m.sources {
+		if source.Name == name {
+			return &sourcePriceDB{PriceDB: m.PriceDB, merger: m, index: i}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown price source %q", name)
+}
+
+type sourcePriceDB struct {
+	PriceDB
+	merger *PriceMerger
+	index  int
+}
+
+func (db *sourcePriceDB) UpdatePrices(items []MarketItemPrices) error {
+	return db.merger.update(db.index, items)
+}
+
+func (m *PriceMerger) update(index int, items []MarketItemPrices) error {
+	source := m.sources[index]
+	merged := make([]MarketItemPrices, 0, len(items))
+
+	m.lock.Lock()
+	for _, item := range items {
+		// CCP's prices are the "ccp" price fallback, which isn't one of the markets a source can be limited to
+		if !source.HasMarket(item.Market) && item.Market != CCPMarketName {
+			continue
+		}
+		key := marketTypeKey{market: item.Market, typeID: item.TypeID}
+		prices, ok := m.prices[key]
+		if !ok {
+			prices = make([]*Prices, len(m.sources))
+			m.prices[key] = prices
+		}
+		price := item.Prices
+		prices[index] = &price
+
+		result, ok := mergePrices(m.sources, prices)
+		if ok {
+			merged = append(merged, MarketItemPrices{Market: item.Market, TypeID: item.TypeID, Prices: result})
+		}
+	}
+	m.lock.Unlock()
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return m.PriceDB.UpdatePrices(merged)
+}
+
+// mergePrices combines the prices that each source has for a single type. Sources are in priority order. When no
+// source has enough volume, the highest priority price is used anyway.
+func mergePrices(sources []PriceSourceConfig, prices []*Prices) (Prices, bool) {
+	var merged Prices
+	found := false
+	for i, source := range sources {
+		price := prices[i]
+		if price == nil || price.Sell.Volume < source.MinSellVolume {
+			continue
+		}
+		if !found {
+			merged = *price
+			found = true
+			continue
+		}
+		if source.Merge == PriceMergeFill {
+			if isEmptyPriceStats(merged.Buy) {
+				merged.Buy = price.Buy
+			}
+			if isEmptyPriceStats(merged.Sell) {
+				merged.Sell = price.Sell
+			}
+		}
+	}
+	if found {
+		return merged, true
+	}
+
+	for _, price := range prices {
+		if price != nil {
+			return *price, true
+		}
+	}
+	return Prices{}, false
+}
+
+func isEmptyPriceStats(stats PriceStats) bool {
+	return stats.Min == 0 && stats.Max == 0
+}
@@ -0,0 +1,96 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUpdatePriceDB struct {
+	PriceDB
+	prices map[string]map[int64]Prices
+}
+
+func (db *testUpdatePriceDB) UpdatePrices(items []MarketItemPrices) error {
+	if db.prices == nil {
+		db.prices = make(map[string]map[int64]Prices)
+	}
+	for _, item := range items {
+		if db.prices[item.Market] == nil {
+			db.prices[item.Market] = make(map[int64]Prices)
+		}
+		db.prices[item.Market][item.TypeID] = item.Prices
+	}
+	return nil
+}
+
+func TestPriceMerger(t *testing.T) {
+	db := &testUpdatePriceDB{}
+	sources := NormalizePriceSources([]PriceSourceConfig{
+		{Type: PriceSourceESICCP, Priority: 10},
+		{Type: PriceSourceESIOrders, Priority: 20, MinSellVolume: 10},
+		{Name: "fuzzwork", Type: PriceSourceJSONHTTP, URL: "http://localhost", Priority: 5, Merge: PriceMergeFill, Markets: []string{"jita"}},
+	})
+	assert.NoError(t, CheckPriceSources(sources, DefaultMarkets))
+	assert.Equal(t, PriceSourceESIOrders, sources[0].Name)
+	assert.Equal(t, PriceMergeWhole, sources[0].Merge)
+
+	merger := NewPriceMerger(db, sources)
+	orders, err := merger.SourceDB(PriceSourceESIOrders)
+	assert.NoError(t, err)
+	ccp, err := merger.SourceDB(PriceSourceESICCP)
+	assert.NoError(t, err)
+	fuzzwork, err := merger.SourceDB("fuzzwork")
+	assert.NoError(t, err)
+	_, err = merger.SourceDB("unknown")
+	assert.Error(t, err)
+
+	thin := Prices{Strategy: "orders", Sell: PriceStats{Min: 5, Volume: 2}}
+	deep := Prices{Strategy: "orders", Sell: PriceStats{Min: 6, Volume: 100}}
+	assert.NoError(t, orders.UpdatePrices([]MarketItemPrices{
+		{Market: "jita", TypeID: 34, Prices: thin},
+		{Market: "jita", TypeID: 35, Prices: deep},
+	}))
+
+	// Nothing else has a price so the thin orders are used anyway
+	assert.Equal(t, thin, db.prices["jita"][34])
+	assert.Equal(t, deep, db.prices["jita"][35])
+
+	// CCP's price takes over from the thin orders but not from the deep ones
+	ccpPrice := Prices{Strategy: "ccp"}.Set(4)
+	assert.NoError(t, ccp.UpdatePrices([]MarketItemPrices{
+		{Market: "jita", TypeID: 34, Prices: ccpPrice},
+		{Market: "jita", TypeID: 35, Prices: ccpPrice},
+	}))
+	assert.Equal(t, ccpPrice, db.prices["jita"][34])
+	assert.Equal(t, deep, db.prices["jita"][35])
+
+	// A source that is limited to some markets still has its CCP prices stored
+	assert.NoError(t, fuzzwork.UpdatePrices([]MarketItemPrices{{Market: CCPMarketName, TypeID: 34, Prices: ccpPrice}}))
+	assert.Equal(t, ccpPrice, db.prices[CCPMarketName][34])
+
+	// fuzzwork fills in the buy side that the orders don't have, but only in jita
+	fuzzPrice := Prices{Strategy: "orders", Buy: PriceStats{Max: 5.5, Volume: 1000}}
+	assert.NoError(t, fuzzwork.UpdatePrices([]MarketItemPrices{
+		{Market: "jita", TypeID: 35, Prices: fuzzPrice},
+		{Market: "amarr", TypeID: 35, Prices: fuzzPrice},
+	}))
+	assert.Equal(t, 6.0, db.prices["jita"][35].Sell.Min)
+	assert.Equal(t, 5.5, db.prices["jita"][35].Buy.Max)
+	assert.NotContains(t, db.prices, "amarr")
+}
+
+func TestCheckPriceSources(t *testing.T) {
+	for _, sources := range [][]PriceSourceConfig{
+		{{Type: "unknown"}},
+		{{Type: PriceSourceESIOrders}, {Name: "other", Type: PriceSourceESIOrders}},
+		{{Type: PriceSourceESICCP}, {Type: PriceSourceESICCP}},
+		{{Type: PriceSourceStatic}},
+		{{Type: PriceSourceJSONHTTP}},
+		{{Type: PriceSourceESICCP, Merge: "average"}},
+		{{Type: PriceSourceESICCP, Markets: []string{"unknown"}}},
+	} {
+		assert.Error(t, CheckPriceSources(NormalizePriceSources(sources), DefaultMarkets), "%v", sources)
+	}
+	assert.NoError(t, CheckPriceSources(NormalizePriceSources(nil), DefaultMarkets))
+}
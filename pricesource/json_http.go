@@ -0,0 +1,196 @@
+package pricesource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/sethgrid/pester"
+)
+
+// typeIDsPlaceholder is replaced in a JSONHTTPSource's URL with a comma separated batch of type IDs
+const typeIDsPlaceholder = "{type_ids}"
+
+// typeIDBatchSize is how many type IDs are asked for in a single request
+var typeIDBatchSize = 200
+
+// JSONHTTPSource is the price source for another price aggregator that serves prices as JSON over HTTP, like
+// Fuzzwork's market aggregates. The response is an object keyed by type ID with "buy" and "sell" stats (and,
+// optionally, "all") for each type. Stats can use this site's field names or Fuzzwork's, and numbers can be strings.
+type JSONHTTPSource struct {
+	poller
+	url     string
+	markets []string
+	client  *pester.Client
+	db      evepraisal.PriceDB
+
+	// TypeIDs lists the types that are asked for when the URL has a "{type_ids}" placeholder
+	TypeIDs func() []int64
+}
+
+// NewJSONHTTPSource returns a source that fetches prices from url every interval. The prices are used for the given
+// markets.
+func NewJSONHTTPSource(ctx context.Context, url string, markets []string, interval time.Duration, client *pester.Client) *JSONHTTPSource {
+	return &JSONHTTPSource{
+		poller:  newPoller(ctx, interval),
+		url:     url,
+		markets: markets,
+		client:  client,
+	}
+}
+
+// Start starts fetching prices. Prices are written to db.
+func (s *JSONHTTPSource) Start(db evepraisal.PriceDB) error {
+	s.db = db
+	s.start(func() {
+		err := s.refresh(time.Now())
+		if err != nil && s.ctx.Err() == nil {
+			log.Printf("ERROR: fetching prices from %s: %s", s.url, err)
+		}
+	})
+	return nil
+}
+
+// refresh fetches every price, batching the type IDs if the URL asks for them
+func (s *JSONHTTPSource) refresh(now time.Time) error {
+	if !strings.Contains(s.url, typeIDsPlaceholder) {
+		return s.fetch(s.url, now)
+	}
+	if s.TypeIDs == nil {
+		return fmt.Errorf("the url needs type IDs but there are none")
+	}
+
+	typeIDs := s.TypeIDs()
+	for start := 0; start < len(typeIDs); start += typeIDBatchSize {
+		end := start + typeIDBatchSize
+		if end > len(typeIDs) {
+			end = len(typeIDs)
+		}
+		ids := make([]string, 0, end-start)
+		for _, typeID := range typeIDs[start:end] {
+			ids = append(ids, strconv.FormatInt(typeID, 10))
+		}
+		err := s.fetch(strings.Replace(s.url, typeIDsPlaceholder, strings.Join(ids, ","), 1), now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch fetches a single URL and saves the prices in it
+func (s *JSONHTTPSource) fetch(url string, now time.Time) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("User-Agent", "go-evepraisal")
+	resp, err := s.client.Do(req.WithContext(s.ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	var doc map[string]jsonPrices
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	if err != nil {
+		return err
+	}
+
+	items := make([]evepraisal.MarketItemPrices, 0, len(doc)*len(s.markets))
+	for key, p := range doc {
+		typeID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		prices := p.prices(now)
+		for _, market := range s.markets {
+			items = append(items, evepraisal.MarketItemPrices{Market: market, TypeID: typeID, Prices: prices})
+		}
+	}
+	return s.db.UpdatePrices(items)
+}
+
+type jsonPrices struct {
+	All  *jsonPriceStats `json:"all"`
+	Buy  jsonPriceStats  `json:"buy"`
+	Sell jsonPriceStats  `json:"sell"`
+}
+
+func (p jsonPrices) prices(now time.Time) evepraisal.Prices {
+	prices := evepraisal.Prices{
+		Buy:      p.Buy.stats(),
+		Sell:     p.Sell.stats(),
+		Updated:  now,
+		Strategy: "orders",
+	}
+	if p.All != nil {
+		prices.All = p.All.stats()
+	} else {
+		prices.All = prices.Sell
+	}
+	return prices
+}
+
+// jsonPriceStats has both this site's and Fuzzwork's names for each field
+type jsonPriceStats struct {
+	Average         flexFloat `json:"avg"`
+	WeightedAverage flexFloat `json:"weightedAverage"`
+	Max             flexFloat `json:"max"`
+	Median          flexFloat `json:"median"`
+	Min             flexFloat `json:"min"`
+	Percentile      flexFloat `json:"percentile"`
+	Stddev          flexFloat `json:"stddev"`
+	Volume          flexFloat `json:"volume"`
+	OrderCount      flexFloat `json:"order_count"`
+	FuzzOrderCount  flexFloat `json:"orderCount"`
+}
+
+func (s jsonPriceStats) stats() evepraisal.PriceStats {
+	stats := evepraisal.PriceStats{
+		Average:    float64(s.Average),
+		Max:        float64(s.Max),
+		Median:     float64(s.Median),
+		Min:        float64(s.Min),
+		Percentile: float64(s.Percentile),
+		Stddev:     float64(s.Stddev),
+		Volume:     int64(s.Volume),
+		OrderCount: int64(s.OrderCount),
+	}
+	if stats.Average == 0 {
+		stats.Average = float64(s.WeightedAverage)
+	}
+	if stats.OrderCount == 0 {
+		stats.OrderCount = int64(s.FuzzOrderCount)
+	}
+	return stats
+}
+
+// flexFloat is a number that may be given as a JSON number or as a string
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 || string(b) == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return err
+	}
+	*f = flexFloat(v)
+	return nil
+}
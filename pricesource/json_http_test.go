@@ -0,0 +1,88 @@
+package pricesource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/sethgrid/pester"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePriceDB records the prices that are written to it
+type fakePriceDB struct {
+	evepraisal.PriceDB
+	l      sync.Mutex
+	prices map[string]map[int64]evepraisal.Prices
+}
+
+func (db *fakePriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
+	db.l.Lock()
+	defer db.l.Unlock()
+	if db.prices == nil {
+		db.prices = make(map[string]map[int64]evepraisal.Prices)
+	}
+	for _, item := range items {
+		if db.prices[item.Market] == nil {
+			db.prices[item.Market] = make(map[int64]evepraisal.Prices)
+		}
+		db.prices[item.Market][item.TypeID] = item.Prices
+	}
+	return nil
+}
+
+func TestJSONHTTPSource(t *testing.T) {
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("types"))
+		switch r.URL.Query().Get("types") {
+		case "34,35":
+			_, _ = w.Write([]byte(`{
+				"34": {"buy": {"weightedAverage": "4.5", "max": "5.01", "volume": "1000000.0", "orderCount": "12"},
+				       "sell": {"min": "5.5", "volume": "200000"}},
+				"35": {"buy": {"max": 9}, "sell": {"avg": 11, "min": 10, "order_count": 3}}
+			}`))
+		case "36":
+			_, _ = w.Write([]byte(`{"36": {"buy": {}, "sell": {"min": null}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	defer func(size int) { typeIDBatchSize = size }(typeIDBatchSize)
+	typeIDBatchSize = 2
+
+	client := pester.New()
+	client.MaxRetries = 1
+	db := &fakePriceDB{}
+	s := NewJSONHTTPSource(context.Background(), ts.URL+"/aggregates/?station=60003760&types={type_ids}", []string{"jita", "perimeter"}, time.Hour, client)
+	s.db = db
+	s.TypeIDs = func() []int64 { return []int64{34, 35, 36} }
+
+	now := time.Now()
+	assert.NoError(t, s.refresh(now))
+	assert.Equal(t, []string{"34,35", "36"}, requested)
+
+	prices := db.prices["jita"][34]
+	assert.Equal(t, "orders", prices.Strategy)
+	assert.Equal(t, now, prices.Updated)
+	assert.Equal(t, 4.5, prices.Buy.Average)
+	assert.Equal(t, 5.01, prices.Buy.Max)
+	assert.Equal(t, int64(1000000), prices.Buy.Volume)
+	assert.Equal(t, int64(12), prices.Buy.OrderCount)
+	assert.Equal(t, 5.5, prices.Sell.Min)
+	assert.Equal(t, prices.Sell, prices.All)
+
+	assert.Equal(t, 11.0, db.prices["perimeter"][35].Sell.Average)
+	assert.Equal(t, int64(3), db.prices["perimeter"][35].Sell.OrderCount)
+	assert.Equal(t, 0.0, db.prices["jita"][36].Sell.Min)
+
+	// Errors are returned
+	s.TypeIDs = func() []int64 { return []int64{37} }
+	assert.Error(t, s.refresh(now))
+}
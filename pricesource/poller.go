@@ -0,0 +1,57 @@
+package pricesource
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultInterval is used when a source isn't given an interval
+var defaultInterval = 5 * time.Minute
+
+// poller calls a function every interval until it is closed
+type poller struct {
+	interval time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan bool
+	wg     *sync.WaitGroup
+}
+
+func newPoller(ctx context.Context, interval time.Duration) poller {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return poller{
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+		stop:     make(chan bool),
+		wg:       &sync.WaitGroup{},
+	}
+}
+
+func (p *poller) start(poll func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			poll()
+			select {
+			case <-time.After(p.interval):
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops polling. A poll that is in progress is cancelled.
+func (p *poller) Close() error {
+	close(p.stop)
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
@@ -0,0 +1,110 @@
+package pricesource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// StaticSource is the price source for a price sheet file. Files ending in .csv are read with
+// evepraisal.ParsePriceSheetCSV and anything else as a JSON price sheet. Every item needs a type ID. The file is read
+// again whenever it changes.
+type StaticSource struct {
+	poller
+	path    string
+	markets []string
+	db      evepraisal.PriceDB
+	modTime time.Time
+}
+
+// NewStaticSource returns a source for the price sheet at path whose prices are used for the given markets. The file
+// is checked for changes every interval.
+func NewStaticSource(ctx context.Context, path string, markets []string, interval time.Duration) *StaticSource {
+	return &StaticSource{
+		poller:  newPoller(ctx, interval),
+		path:    path,
+		markets: markets,
+	}
+}
+
+// Start loads the price sheet and writes its prices to db. An error is returned if the sheet can't be loaded.
+func (s *StaticSource) Start(db evepraisal.PriceDB) error {
+	s.db = db
+	err := s.refresh()
+	if err != nil {
+		return err
+	}
+	s.start(func() {
+		err := s.refresh()
+		if err != nil {
+			log.Printf("ERROR: loading price sheet %s: %s", s.path, err)
+		}
+	})
+	return nil
+}
+
+// refresh loads the price sheet if it has changed since it was last loaded
+func (s *StaticSource) refresh() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	items, err := loadPriceSheetItems(s.path)
+	if err != nil {
+		return err
+	}
+
+	priceItems := make([]evepraisal.MarketItemPrices, 0, len(items)*len(s.markets))
+	for _, market := range s.markets {
+		for _, item := range items {
+			priceItems = append(priceItems, evepraisal.MarketItemPrices{Market: market, TypeID: item.TypeID, Prices: item.Prices()})
+		}
+	}
+	err = s.db.UpdatePrices(priceItems)
+	if err != nil {
+		return err
+	}
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func loadPriceSheetItems(path string) ([]evepraisal.PriceSheetItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []evepraisal.PriceSheetItem
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		items, err = evepraisal.ParsePriceSheetCSV(f)
+	} else {
+		var sheet evepraisal.PriceSheet
+		err = json.NewDecoder(f).Decode(&sheet)
+		items = sheet.Items
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if item.TypeID <= 0 {
+			return nil, fmt.Errorf("item %d: type_id is required", i)
+		}
+		if item.Buy < 0 || item.Sell < 0 {
+			return nil, fmt.Errorf("item %d: prices can't be negative", i)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,41 @@
+package pricesource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	assert.NoError(t, os.WriteFile(path, []byte("type_id,buy,sell\n34,4,5\n35,9,10\n"), 0600))
+
+	db := &fakePriceDB{}
+	s := NewStaticSource(context.Background(), path, []string{"jita"}, time.Hour)
+	s.db = db
+	assert.NoError(t, s.refresh())
+
+	assert.Len(t, db.prices["jita"], 2)
+	assert.Equal(t, "price_sheet", db.prices["jita"][34].Strategy)
+	assert.Equal(t, 4.0, db.prices["jita"][34].Buy.Max)
+	assert.Equal(t, 10.0, db.prices["jita"][35].Sell.Min)
+
+	// The file is only read again when it changes
+	db.prices = nil
+	assert.NoError(t, s.refresh())
+	assert.Nil(t, db.prices)
+
+	assert.NoError(t, os.WriteFile(path, []byte("34,4,6\n"), 0600))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	assert.NoError(t, s.refresh())
+	assert.Equal(t, 6.0, db.prices["jita"][34].Sell.Min)
+
+	// Names can't be looked up so items need type IDs
+	broken := filepath.Join(t.TempDir(), "broken.json")
+	assert.NoError(t, os.WriteFile(broken, []byte(`{"items": [{"name": "Tritanium", "buy": 4, "sell": 5}]}`), 0600))
+	assert.Error(t, NewStaticSource(context.Background(), broken, []string{"jita"}, time.Hour).Start(db))
+}
@@ -0,0 +1,104 @@
+package evepraisal
+
+// PricingPolicy decides which of the aggregated market stats are used as the sell and buy value of an item. Policies
+// that only have one sensible number (like split) use it for both.
+type PricingPolicy struct {
+	Name        string
+	DisplayName string
+	Description string
+	Sell        func(prices Prices) float64
+	Buy         func(prices Prices) float64
+}
+
+// DefaultPricingPolicy is the policy that is used when none is given
+const DefaultPricingPolicy = "minmax"
+
+// PricingPolicies are all of the policies that can be selected for an appraisal
+var PricingPolicies = []PricingPolicy{
+	{
+		Name:        DefaultPricingPolicy,
+		DisplayName: "Sell Min / Buy Max",
+		Description: "Sell value is the lowest sell order and buy value is the highest buy order",
+		Sell:        func(p Prices) float64 { return p.Sell.Min },
+		Buy:         func(p Prices) float64 { return p.Buy.Max },
+	}, {
+		Name:        "sell_min",
+		DisplayName: "Sell Min",
+		Description: "Everything is valued at the lowest sell order",
+		Sell:        func(p Prices) float64 { return p.Sell.Min },
+		Buy:         func(p Prices) float64 { return p.Sell.Min },
+	}, {
+		Name:        "buy_max",
+		DisplayName: "Buy Max",
+		Description: "Everything is valued at the highest buy order",
+		Sell:        func(p Prices) float64 { return p.Buy.Max },
+		Buy:         func(p Prices) float64 { return p.Buy.Max },
+	}, {
+		Name:        "split",
+		DisplayName: "Split",
+		Description: "Everything is valued halfway between the highest buy order and the lowest sell order",
+		Sell:        splitPrice,
+		Buy:         splitPrice,
+	}, {
+		Name:        "sell_percentile",
+		DisplayName: "Sell 1st Percentile",
+		Description: "Everything is valued at the 1st percentile of sell orders, weighted by volume",
+		Sell:        func(p Prices) float64 { return p.Sell.Percentile },
+		Buy:         func(p Prices) float64 { return p.Sell.Percentile },
+	}, {
+		Name:        "buy_percentile",
+		DisplayName: "Buy 99th Percentile",
+		Description: "Everything is valued at the 99th percentile of buy orders, weighted by volume",
+		Sell:        func(p Prices) float64 { return p.Buy.Percentile },
+		Buy:         func(p Prices) float64 { return p.Buy.Percentile },
+	}, {
+		Name:        "percentile",
+		DisplayName: "Percentile",
+		Description: "Sell value is the 1st percentile of sell orders and buy value is the 99th percentile of buy orders",
+		Sell:        func(p Prices) float64 { return p.Sell.Percentile },
+		Buy:         func(p Prices) float64 { return p.Buy.Percentile },
+	}, {
+		Name:        "median",
+		DisplayName: "Median",
+		Description: "Sell and buy values are the median of the sell and buy orders",
+		Sell:        func(p Prices) float64 { return p.Sell.Median },
+		Buy:         func(p Prices) float64 { return p.Buy.Median },
+	}, {
+		Name:        "weighted_avg",
+		DisplayName: "Weighted Average",
+		Description: "Sell and buy values are the volume-weighted average of the sell and buy orders",
+		Sell:        func(p Prices) float64 { return p.Sell.Average },
+		Buy:         func(p Prices) float64 { return p.Buy.Average },
+	},
+}
+
+func splitPrice(p Prices) float64 {
+	if p.Sell.Min == 0 {
+		return p.Buy.Max
+	}
+	if p.Buy.Max == 0 {
+		return p.Sell.Min
+	}
+	return (p.Sell.Min + p.Buy.Max) / 2
+}
+
+// GetPricingPolicy returns the pricing policy with the given name. An empty name returns the default policy.
+func GetPricingPolicy(name string) (PricingPolicy, bool) {
+	if name == "" {
+		name = DefaultPricingPolicy
+	}
+	for _, policy := range PricingPolicies {
+		if policy.Name == name {
+			return policy, true
+		}
+	}
+	return PricingPolicy{}, false
+}
+
+func pricingPolicyOrDefault(name string) PricingPolicy {
+	policy, ok := GetPricingPolicy(name)
+	if !ok {
+		policy, _ = GetPricingPolicy(DefaultPricingPolicy)
+	}
+	return policy
+}
@@ -0,0 +1,43 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPricingPolicy(t *testing.T) {
+	policy, ok := GetPricingPolicy("")
+	assert.True(t, ok)
+	assert.Equal(t, DefaultPricingPolicy, policy.Name)
+
+	_, ok = GetPricingPolicy("split")
+	assert.True(t, ok)
+
+	_, ok = GetPricingPolicy("not-a-policy")
+	assert.False(t, ok)
+}
+
+func TestItemPricesUsePricingPolicy(t *testing.T) {
+	item := AppraisalItem{Quantity: 10}
+	item.Prices.Sell.Min = 110
+	item.Prices.Sell.Median = 130
+	item.Prices.Buy.Max = 90
+	item.Prices.Buy.Median = 70
+
+	assert.Equal(t, 110.0, item.SellPrice())
+	assert.Equal(t, 90.0, item.BuyPrice())
+
+	item.Pricing = "split"
+	assert.Equal(t, 1000.0, item.SellTotal())
+	assert.Equal(t, 1000.0, item.BuyTotal())
+
+	item.Pricing = "median"
+	assert.Equal(t, 1300.0, item.SellTotal())
+	assert.Equal(t, 700.0, item.BuyTotal())
+
+	// Split falls back to the only side that has orders
+	item.Prices.Buy.Max = 0
+	item.Pricing = "split"
+	assert.Equal(t, 110.0, item.SellPrice())
+}
@@ -0,0 +1,152 @@
+package evepraisal
+
+import (
+	"fmt"
+	"math"
+)
+
+// CategoryAsteroid is the category of ores, moon ores and ice, which reprocess using the ore yield
+const CategoryAsteroid = 25
+
+// ReprocessingOptions describe the character and structure an appraisal's items are reprocessed with. Bonuses are
+// percentages.
+type ReprocessingOptions struct {
+	// RigBonus is the yield bonus of the structure's reprocessing rig: 0 for none, 1 for T1 and 3 for T2
+	RigBonus float64 `json:"rig_bonus"`
+	// SecurityBonus scales the rig bonus by the security of the system: 0 in high sec, 6 in low sec and 12 in null sec
+	SecurityBonus float64 `json:"security_bonus"`
+	// StructureBonus is the yield bonus of the structure itself: 2 for an Athanor and 5.5 for a Tatara
+	StructureBonus float64 `json:"structure_bonus"`
+	// ImplantBonus is the yield bonus of the character's reprocessing implant: 1, 2 or 4
+	ImplantBonus                float64 `json:"implant_bonus"`
+	ReprocessingSkill           int64   `json:"reprocessing_skill"`
+	ReprocessingEfficiencySkill int64   `json:"reprocessing_efficiency_skill"`
+	// ProcessingSkill is the level of the ore or ice specific processing skill
+	ProcessingSkill int64 `json:"processing_skill"`
+	ScrapmetalSkill int64 `json:"scrapmetal_skill"`
+	// Tax is the percentage of the value of the materials that the structure owner takes
+	Tax float64 `json:"tax"`
+}
+
+// DefaultReprocessingOptions are a character with every reprocessing skill at V in an NPC station
+var DefaultReprocessingOptions = ReprocessingOptions{
+	ReprocessingSkill:           5,
+	ReprocessingEfficiencySkill: 5,
+	ProcessingSkill:             5,
+	ScrapmetalSkill:             5,
+}
+
+// Validate returns an error if any of the options are out of range
+func (o ReprocessingOptions) Validate() error {
+	for _, skill := range []int64{o.ReprocessingSkill, o.ReprocessingEfficiencySkill, o.ProcessingSkill, o.ScrapmetalSkill} {
+		if skill < 0 || skill > 5 {
+			return fmt.Errorf("Skill levels must be between 0 and 5")
+		}
+	}
+	for _, bonus := range []float64{o.RigBonus, o.SecurityBonus, o.StructureBonus, o.ImplantBonus, o.Tax} {
+		if bonus < 0 || bonus > 100 {
+			return fmt.Errorf("Reprocessing bonuses and tax must be between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// OreYield is the fraction of materials that are returned when reprocessing ore and ice
+func (o ReprocessingOptions) OreYield() float64 {
+	return (50 + o.RigBonus) / 100 *
+		(1 + o.SecurityBonus/100) *
+		(1 + o.StructureBonus/100) *
+		(1 + 0.03*float64(o.ReprocessingSkill)) *
+		(1 + 0.02*float64(o.ReprocessingEfficiencySkill)) *
+		(1 + 0.02*float64(o.ProcessingSkill)) *
+		(1 + o.ImplantBonus/100)
+}
+
+// ScrapYield is the fraction of materials that are returned when reprocessing anything that isn't ore or ice.
+// Structure, rig and implant bonuses don't apply.
+func (o ReprocessingOptions) ScrapYield() float64 {
+	return 0.5 * (1 + 0.02*float64(o.ScrapmetalSkill))
+}
+
+// ReprocessedMaterial is a material that an appraisal item reprocesses into
+type ReprocessedMaterial struct {
+	TypeID   int64   `json:"typeID"`
+	TypeName string  `json:"typeName"`
+	Quantity int64   `json:"quantity"`
+	Buy      float64 `json:"buy"`
+	Sell     float64 `json:"sell"`
+}
+
+// ItemReprocessing is what an appraisal item is worth when it is reprocessed. Totals are for the whole quantity and
+// include the tax as well as the market value of any leftover items that don't make up a full portion.
+type ItemReprocessing struct {
+	Yield     float64               `json:"yield"`
+	Materials []ReprocessedMaterial `json:"materials"`
+	Leftover  int64                 `json:"leftover,omitempty"`
+	Buy       float64               `json:"buy"`
+	Sell      float64               `json:"sell"`
+}
+
+// UnreprocessedSellTotal is the sell total of the item as is, without reprocessing or order book depth
+func (i AppraisalItem) UnreprocessedSellTotal() float64 {
+	return float64(i.Quantity) * i.SellPrice()
+}
+
+// ReprocessIsBetter returns true if reprocessing the item gives more than selling it as is
+func (i AppraisalItem) ReprocessIsBetter() bool {
+	if i.Reprocessed == nil {
+		return false
+	}
+	return i.Reprocessed.Sell > i.UnreprocessedSellTotal()
+}
+
+// ReprocessingForItem values the item by the materials it reprocesses into using the given options. Materials are
+// priced in the given market with the item's pricing policy and scaled by the price percentage, like the item's own
+// prices are. Returns nil if the item can't be reprocessed.
+func (app *App) ReprocessingForItem(market string, item AppraisalItem, options ReprocessingOptions, pricePercentage float64) *ItemReprocessing {
+	t, ok := app.TypeDB.GetTypeByID(item.TypeID)
+	if !ok || len(t.Materials) == 0 {
+		return nil
+	}
+
+	portionSize := t.PortionSize
+	if portionSize <= 0 {
+		portionSize = 1
+	}
+	portions := item.Quantity / portionSize
+
+	result := &ItemReprocessing{
+		Yield:     options.ScrapYield(),
+		Materials: make([]ReprocessedMaterial, 0, len(t.Materials)),
+		Leftover:  item.Quantity % portionSize,
+	}
+	if t.CategoryID == CategoryAsteroid {
+		result.Yield = options.OreYield()
+	}
+
+	policy := pricingPolicyOrDefault(item.Pricing)
+	afterTax := 1 - options.Tax/100
+	for _, component := range t.Materials {
+		material := ReprocessedMaterial{
+			TypeID:   component.TypeID,
+			Quantity: int64(math.Floor(float64(component.Quantity*portions) * result.Yield)),
+		}
+		if materialType, ok := app.TypeDB.GetTypeByID(component.TypeID); ok {
+			material.TypeName = materialType.Name
+		}
+		prices, _ := app.PriceDB.GetPrice(market, component.TypeID)
+		if pricePercentage > 0 {
+			prices = prices.Mul(pricePercentage / 100)
+		}
+		material.Buy = float64(material.Quantity) * policy.Buy(prices) * afterTax
+		material.Sell = float64(material.Quantity) * policy.Sell(prices) * afterTax
+
+		result.Materials = append(result.Materials, material)
+		result.Buy += material.Buy
+		result.Sell += material.Sell
+	}
+
+	result.Buy += float64(result.Leftover) * item.BuyPrice()
+	result.Sell += float64(result.Leftover) * item.SellPrice()
+	return result
+}
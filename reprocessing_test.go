@@ -0,0 +1,84 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testReprocessingTypeDB struct {
+	typedb.TypeDB
+	types map[int64]typedb.EveType
+}
+
+func (db testReprocessingTypeDB) GetTypeByID(typeID int64) (typedb.EveType, bool) {
+	t, ok := db.types[typeID]
+	return t, ok
+}
+
+type testReprocessingPriceDB struct {
+	PriceDB
+	prices map[int64]Prices
+}
+
+func (db testReprocessingPriceDB) GetPrice(market string, typeID int64) (Prices, bool) {
+	prices, ok := db.prices[typeID]
+	return prices, ok
+}
+
+func TestReprocessingYield(t *testing.T) {
+	assert.InDelta(t, 0.69575, DefaultReprocessingOptions.OreYield(), 0.00001)
+	assert.InDelta(t, 0.55, DefaultReprocessingOptions.ScrapYield(), 0.00001)
+
+	// A Tatara with a T2 rig in null sec
+	options := DefaultReprocessingOptions
+	options.StructureBonus = 5.5
+	options.RigBonus = 3
+	options.SecurityBonus = 12
+	options.ImplantBonus = 4
+	assert.InDelta(t, 0.9063, options.OreYield(), 0.0001)
+	assert.InDelta(t, 0.55, options.ScrapYield(), 0.00001)
+
+	assert.NoError(t, options.Validate())
+	options.ReprocessingSkill = 6
+	assert.Error(t, options.Validate())
+}
+
+func TestReprocessingForItem(t *testing.T) {
+	app := &App{
+		TypeDB: testReprocessingTypeDB{types: map[int64]typedb.EveType{
+			1230: {ID: 1230, Name: "Veldspar", CategoryID: CategoryAsteroid, PortionSize: 100, Materials: []typedb.Component{{TypeID: 34, Quantity: 400}}},
+			2048: {ID: 2048, Name: "Damage Control I", PortionSize: 1, Materials: []typedb.Component{{TypeID: 34, Quantity: 1000}, {TypeID: 35, Quantity: 100}}},
+			34:   {ID: 34, Name: "Tritanium"},
+			35:   {ID: 35, Name: "Pyerite"},
+		}},
+		PriceDB: testReprocessingPriceDB{prices: map[int64]Prices{
+			34: {Sell: PriceStats{Min: 5}, Buy: PriceStats{Max: 4}},
+			35: {Sell: PriceStats{Min: 10}, Buy: PriceStats{Max: 8}},
+		}},
+	}
+	options := ReprocessingOptions{Tax: 10}
+
+	// 250 veldspar is two portions with 50 left over
+	item := AppraisalItem{TypeID: 1230, Quantity: 250, Prices: Prices{Sell: PriceStats{Min: 1}, Buy: PriceStats{Max: 1}}}
+	reprocessed := app.ReprocessingForItem("jita", item, options, 0)
+	assert.NotNil(t, reprocessed)
+	assert.Equal(t, 0.5, reprocessed.Yield)
+	assert.Equal(t, int64(50), reprocessed.Leftover)
+	assert.Equal(t, []ReprocessedMaterial{{TypeID: 34, TypeName: "Tritanium", Quantity: 400, Buy: 1440, Sell: 1800}}, reprocessed.Materials)
+	assert.Equal(t, 1800.0+50, reprocessed.Sell)
+	assert.Equal(t, 1440.0+50, reprocessed.Buy)
+
+	// Modules use the scrapmetal yield and the totals use the reprocessed value
+	item = AppraisalItem{TypeID: 2048, Quantity: 2, Prices: Prices{Sell: PriceStats{Min: 1000}, Buy: PriceStats{Max: 900}}}
+	item.Reprocessed = app.ReprocessingForItem("jita", item, options, 50)
+	assert.Len(t, item.Reprocessed.Materials, 2)
+	assert.Equal(t, int64(1000), item.Reprocessed.Materials[0].Quantity)
+	assert.Equal(t, int64(100), item.Reprocessed.Materials[1].Quantity)
+	assert.InDelta(t, (1000*2.5+100*5)*0.9, item.SellTotal(), 0.0001)
+	assert.True(t, item.ReprocessIsBetter())
+
+	// Types without materials can't be reprocessed
+	assert.Nil(t, app.ReprocessingForItem("jita", AppraisalItem{TypeID: 34, Quantity: 10}, options, 0))
+}
@@ -0,0 +1,183 @@
+package evepraisal
+
+import (
+	"fmt"
+	"math"
+)
+
+// Ways that a sell plan can sell an item
+const (
+	SellMethodBuyOrders  = "buy_orders"
+	SellMethodSellOrders = "sell_orders"
+)
+
+const (
+	// baseSalesTax is the sales tax, in percent, without the Accounting skill. Each level takes 11% off.
+	baseSalesTax = 7.5
+	// baseBrokerFee is the NPC station broker fee, in percent, without the Broker Relations skill. Each level takes
+	// off 0.3 but the fee never goes below minBrokerFee.
+	baseBrokerFee = 3.0
+	minBrokerFee  = 1.0
+	// relistFeeRate is the part of the broker fee that is charged again whenever a sell order is updated
+	relistFeeRate = 0.5
+)
+
+// SellPlanOptions describe the character and the station that an appraisal's items are sold with. Percentages are out
+// of 100.
+type SellPlanOptions struct {
+	AccountingSkill      int64 `json:"accounting_skill"`
+	BrokerRelationsSkill int64 `json:"broker_relations_skill"`
+	// StructureBrokerFee is the broker fee that the owner of a structure charges. The NPC station broker fee is used
+	// when this isn't set. Broker Relations doesn't change the fees of structures.
+	StructureBrokerFee *float64 `json:"structure_broker_fee,omitempty"`
+	// Undercut is how far below the lowest sell order the item is expected to sell
+	Undercut float64 `json:"undercut"`
+	// Relists is how many times a sell order is expected to be updated to stay the lowest
+	Relists int64 `json:"relists"`
+}
+
+// DefaultSellPlanOptions are a character with Accounting and Broker Relations at V in an NPC station
+var DefaultSellPlanOptions = SellPlanOptions{
+	AccountingSkill:      5,
+	BrokerRelationsSkill: 5,
+	Undercut:             1,
+	Relists:              1,
+}
+
+// Validate returns an error if any of the options are out of range
+func (o SellPlanOptions) Validate() error {
+	for _, skill := range []int64{o.AccountingSkill, o.BrokerRelationsSkill} {
+		if skill < 0 || skill > 5 {
+			return fmt.Errorf("Skill levels must be between 0 and 5")
+		}
+	}
+	if o.StructureBrokerFee != nil && (*o.StructureBrokerFee < 0 || *o.StructureBrokerFee > 100) {
+		return fmt.Errorf("The structure broker fee must be between 0 and 100")
+	}
+	if o.Undercut < 0 || o.Undercut > 100 {
+		return fmt.Errorf("The undercut must be between 0 and 100")
+	}
+	if o.Relists < 0 || o.Relists > 1000 {
+		return fmt.Errorf("Relists must be between 0 and 1000")
+	}
+	return nil
+}
+
+// SalesTax is the percentage of every sale that is taken as tax
+func (o SellPlanOptions) SalesTax() float64 {
+	return baseSalesTax * (1 - 0.11*float64(o.AccountingSkill))
+}
+
+// BrokerFee is the percentage of the value of a sell order that is charged when it is listed
+func (o SellPlanOptions) BrokerFee() float64 {
+	if o.StructureBrokerFee != nil {
+		return *o.StructureBrokerFee
+	}
+	return math.Max(minBrokerFee, baseBrokerFee-0.3*float64(o.BrokerRelationsSkill))
+}
+
+// SellPlanOption is what selling an item one way gives. ISK values are for the whole quantity.
+type SellPlanOption struct {
+	Price     float64 `json:"price"`
+	Gross     float64 `json:"gross"`
+	BrokerFee float64 `json:"broker_fee"`
+	SalesTax  float64 `json:"sales_tax"`
+	Net       float64 `json:"net"`
+}
+
+// ItemSellPlan compares selling an item into buy orders with listing it as a sell order. Method is the way that nets
+// the most ISK.
+type ItemSellPlan struct {
+	Method     string         `json:"method"`
+	BuyOrders  SellPlanOption `json:"buy_orders"`
+	SellOrders SellPlanOption `json:"sell_orders"`
+}
+
+// Net is what the item nets when it is sold the planned way
+func (p ItemSellPlan) Net() float64 {
+	if p.Method == SellMethodSellOrders {
+		return p.SellOrders.Net
+	}
+	return p.BuyOrders.Net
+}
+
+// SellPlan is what an appraisal nets after fees and taxes. BuyOrders and SellOrders are the net totals if every item
+// is sold the same way and Net is the total when each item is sold the way that nets the most.
+type SellPlan struct {
+	Options        SellPlanOptions `json:"options"`
+	SalesTax       float64         `json:"sales_tax"`
+	BrokerFee      float64         `json:"broker_fee"`
+	BuyOrders      float64         `json:"buy_orders"`
+	SellOrders     float64         `json:"sell_orders"`
+	Net            float64         `json:"net"`
+	BuyOrderItems  int             `json:"buy_order_items"`
+	SellOrderItems int             `json:"sell_order_items"`
+}
+
+// SellPlanForItem works out what an item nets when it is sold into buy orders and when it is listed as a sell order in
+// the appraisal's market. The item's market prices are used, or the order book when depth is simulated, so price
+// percentages carry over but best prices and reprocessing don't. Returns nil for items that can't be sold on the
+// market and for buyback items, which are sold to the buyback program instead.
+func SellPlanForItem(item AppraisalItem, options SellPlanOptions) *ItemSellPlan {
+	if item.TypeID == 0 || item.Extra.BPC || item.Quantity <= 0 || item.Buyback != nil {
+		return nil
+	}
+	salesTax := options.SalesTax() / 100
+	brokerFee := options.BrokerFee() / 100 * (1 + relistFeeRate*float64(options.Relists))
+
+	buyTotal := float64(item.Quantity) * item.BuyPrice()
+	sellTotal := float64(item.Quantity) * item.SellPrice()
+	if item.Depth != nil {
+		buyTotal = item.Depth.Buy.Total
+		sellTotal = item.Depth.Sell.Total
+	}
+
+	plan := &ItemSellPlan{Method: SellMethodBuyOrders}
+
+	gross := buyTotal
+	plan.BuyOrders = SellPlanOption{
+		Price:    gross / float64(item.Quantity),
+		Gross:    gross,
+		SalesTax: gross * salesTax,
+	}
+	plan.BuyOrders.Net = gross - plan.BuyOrders.SalesTax
+
+	gross = sellTotal * (1 - options.Undercut/100)
+	plan.SellOrders = SellPlanOption{
+		Price:     gross / float64(item.Quantity),
+		Gross:     gross,
+		BrokerFee: gross * brokerFee,
+		SalesTax:  gross * salesTax,
+	}
+	plan.SellOrders.Net = gross - plan.SellOrders.BrokerFee - plan.SellOrders.SalesTax
+
+	if plan.SellOrders.Net > plan.BuyOrders.Net {
+		plan.Method = SellMethodSellOrders
+	}
+	return plan
+}
+
+// PlanSales adds a sell plan to the appraisal and to each of its items
+func (appraisal *Appraisal) PlanSales(options SellPlanOptions) {
+	plan := &SellPlan{
+		Options:   options,
+		SalesTax:  options.SalesTax(),
+		BrokerFee: options.BrokerFee(),
+	}
+	for i := range appraisal.Items {
+		item := SellPlanForItem(appraisal.Items[i], options)
+		appraisal.Items[i].SellPlan = item
+		if item == nil {
+			continue
+		}
+		plan.BuyOrders += item.BuyOrders.Net
+		plan.SellOrders += item.SellOrders.Net
+		plan.Net += item.Net()
+		if item.Method == SellMethodSellOrders {
+			plan.SellOrderItems++
+		} else {
+			plan.BuyOrderItems++
+		}
+	}
+	appraisal.SellPlan = plan
+}
@@ -0,0 +1,93 @@
+package evepraisal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSellPlanFees(t *testing.T) {
+	assert.InDelta(t, 3.375, DefaultSellPlanOptions.SalesTax(), 0.00001)
+	assert.InDelta(t, 1.5, DefaultSellPlanOptions.BrokerFee(), 0.00001)
+
+	untrained := SellPlanOptions{}
+	assert.InDelta(t, 7.5, untrained.SalesTax(), 0.00001)
+	assert.InDelta(t, 3.0, untrained.BrokerFee(), 0.00001)
+
+	// Broker Relations doesn't change a structure's fee
+	fee := 0.5
+	options := DefaultSellPlanOptions
+	options.StructureBrokerFee = &fee
+	assert.InDelta(t, 0.5, options.BrokerFee(), 0.00001)
+
+	assert.NoError(t, DefaultSellPlanOptions.Validate())
+	for _, invalid := range []SellPlanOptions{
+		{AccountingSkill: 6},
+		{BrokerRelationsSkill: -1},
+		{Undercut: 101},
+		{Relists: -1},
+	} {
+		assert.Error(t, invalid.Validate(), "%v", invalid)
+	}
+}
+
+func TestPlanSales(t *testing.T) {
+	appraisal := &Appraisal{Items: []AppraisalItem{
+		// A wide spread is worth listing
+		{TypeID: 34, Quantity: 1000, Prices: Prices{Buy: PriceStats{Max: 4.5}, Sell: PriceStats{Min: 5.5}}},
+		// A narrow spread isn't
+		{TypeID: 35, Quantity: 10, Prices: Prices{Buy: PriceStats{Max: 9.9}, Sell: PriceStats{Min: 10}}},
+		// Items without a type or copies can't be sold
+		{Name: "Unknown", Quantity: 1},
+		{TypeID: 36, Quantity: 1, Extra: AppraisalItemExtra{BPC: true}},
+		// Buyback items are sold to the program
+		{TypeID: 37, Quantity: 1, Prices: Prices{}.Set(10), Buyback: &ItemBuyback{Total: 9}},
+	}}
+	appraisal.PlanSales(DefaultSellPlanOptions)
+
+	tritanium := appraisal.Items[0].SellPlan
+	assert.Equal(t, SellMethodSellOrders, tritanium.Method)
+	assert.InDelta(t, 4500, tritanium.BuyOrders.Gross, 0.001)
+	assert.InDelta(t, 151.875, tritanium.BuyOrders.SalesTax, 0.001)
+	assert.InDelta(t, 4348.125, tritanium.BuyOrders.Net, 0.001)
+	assert.InDelta(t, 5.445, tritanium.SellOrders.Price, 0.00001)
+	// 1.5% to list and half of that again for the relist
+	assert.InDelta(t, 122.5125, tritanium.SellOrders.BrokerFee, 0.001)
+	assert.InDelta(t, 5138.72, tritanium.SellOrders.Net, 0.01)
+	assert.Equal(t, tritanium.SellOrders.Net, tritanium.Net())
+
+	pyerite := appraisal.Items[1].SellPlan
+	assert.Equal(t, SellMethodBuyOrders, pyerite.Method)
+	assert.Equal(t, pyerite.BuyOrders.Net, pyerite.Net())
+
+	assert.Nil(t, appraisal.Items[2].SellPlan)
+	assert.Nil(t, appraisal.Items[3].SellPlan)
+	assert.Nil(t, appraisal.Items[4].SellPlan)
+
+	plan := appraisal.SellPlan
+	assert.Equal(t, 1, plan.SellOrderItems)
+	assert.Equal(t, 1, plan.BuyOrderItems)
+	assert.InDelta(t, tritanium.BuyOrders.Net+pyerite.BuyOrders.Net, plan.BuyOrders, 0.001)
+	assert.InDelta(t, tritanium.SellOrders.Net+pyerite.SellOrders.Net, plan.SellOrders, 0.001)
+	assert.InDelta(t, tritanium.SellOrders.Net+pyerite.BuyOrders.Net, plan.Net, 0.001)
+}
+
+func TestSellPlanUsesMarketPrices(t *testing.T) {
+	options := SellPlanOptions{Undercut: 0}
+	prices := Prices{Buy: PriceStats{Max: 4}, Sell: PriceStats{Min: 5}}
+
+	// The best hub's prices aren't the appraisal's market
+	plan := SellPlanForItem(AppraisalItem{TypeID: 34, Quantity: 10, Prices: prices, BestPrice: &ItemBestPrice{SellPrice: 50, BuyPrice: 40}}, options)
+	assert.InDelta(t, 40, plan.BuyOrders.Gross, 0.00001)
+	assert.InDelta(t, 50, plan.SellOrders.Gross, 0.00001)
+
+	// Neither are the materials it reprocesses into
+	plan = SellPlanForItem(AppraisalItem{TypeID: 34, Quantity: 10, Prices: prices, Reprocessed: &ItemReprocessing{Buy: 400, Sell: 500}}, options)
+	assert.InDelta(t, 40, plan.BuyOrders.Gross, 0.00001)
+
+	// The order book is used when depth is simulated
+	depth := &ItemDepth{Buy: OrderBookFill{Total: 35}, Sell: OrderBookFill{Total: 48}}
+	plan = SellPlanForItem(AppraisalItem{TypeID: 34, Quantity: 10, Prices: prices, Depth: depth}, options)
+	assert.InDelta(t, 35, plan.BuyOrders.Gross, 0.00001)
+	assert.InDelta(t, 48, plan.SellOrders.Gross, 0.00001)
+}
@@ -102,9 +102,23 @@ type Type struct {
 	Name          struct {
 		En string
 	}
-	Published bool
-	Volume    float64
-	BasePrice float64
+	Published   bool
+	Volume      float64
+	BasePrice   float64
+	PortionSize int64 `yaml:"portionSize"`
+}
+
+// Group is an eve online type group
+type Group struct {
+	CategoryID int64 `yaml:"categoryID"`
+}
+
+// TypeMaterials are the materials that one portion of an eve online type reprocesses into
+type TypeMaterials struct {
+	Materials []struct {
+		MaterialTypeID int64 `yaml:"materialTypeID"`
+		Quantity       int64
+	}
 }
 
 // Blueprint is an eve online blueprint
@@ -112,6 +126,7 @@ type Blueprint struct {
 	BlueprintTypeID int64 `yaml:"blueprintTypeID"`
 	Activities      struct {
 		Manufacturing struct {
+			Time      int64
 			Materials []struct {
 				Quantity int64
 				TypeID   int64 `yaml:"typeID"`
@@ -145,6 +160,20 @@ func loadtypes(staticDataPath string) ([]typedb.EveType, error) {
 	}
 	log.Printf("Loaded %d blueprints", len(allBlueprints))
 
+	var allGroups map[int64]Group
+	err = loadDataFromZipFile(r, "fsd/groups.yaml", &allGroups)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %d groups", len(allGroups))
+
+	var allTypeMaterials map[int64]TypeMaterials
+	err = loadDataFromZipFile(r, "fsd/typeMaterials.yaml", &allTypeMaterials)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded materials for %d types", len(allTypeMaterials))
+
 	blueprintsByProductType := make(map[int64][]Blueprint)
 	for _, blueprint := range allBlueprints {
 		for _, product := range blueprint.Activities.Manufacturing.Products {
@@ -168,14 +197,18 @@ func loadtypes(staticDataPath string) ([]typedb.EveType, error) {
 		eveType := typedb.EveType{
 			ID:                typeID,
 			GroupID:           t.GroupID,
+			CategoryID:        allGroups[t.GroupID].CategoryID,
 			MarketGroupID:     t.MarketGroupID,
 			Name:              strings.TrimSpace(t.Name.En),
 			Volume:            t.Volume,
 			BasePrice:         t.BasePrice,
+			PortionSize:       t.PortionSize,
 			Aliases:           []string{},
 			BlueprintProducts: resolveBlueprintProducts(blueprintsByProductType, typeID),
 			Components:        resolveComponents(blueprintsByProductType, typeID),
 			BaseComponents:    flattenComponents(resolveBaseComponents(blueprintsByProductType, typeID, 1, 5)),
+			ManufacturingTime: resolveManufacturingTime(blueprintsByProductType, typeID),
+			Materials:         resolveMaterials(allTypeMaterials, typeID),
 		}
 
 		name, aliases := computeAliases(typeID, eveType.Name)
@@ -216,6 +249,27 @@ func resolveComponents(blueprintsByProductType map[int64][]Blueprint, typeID int
 	return components
 }
 
+func resolveManufacturingTime(blueprintsByProductType map[int64][]Blueprint, typeID int64) int64 {
+	blueprints, ok := blueprintsByProductType[typeID]
+	if !ok || len(blueprints) == 0 {
+		return 0
+	}
+	return blueprints[0].Activities.Manufacturing.Time
+}
+
+func resolveMaterials(allTypeMaterials map[int64]TypeMaterials, typeID int64) []typedb.Component {
+	typeMaterials, ok := allTypeMaterials[typeID]
+	if !ok {
+		return nil
+	}
+
+	var components []typedb.Component
+	for _, material := range typeMaterials.Materials {
+		components = append(components, typedb.Component{Quantity: material.Quantity, TypeID: material.MaterialTypeID})
+	}
+	return components
+}
+
 func flattenComponents(components []typedb.Component) []typedb.Component {
 	m := make(map[typedb.Component]int64)
 	for _, component := range components {
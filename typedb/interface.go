@@ -16,15 +16,19 @@ type TypeDB interface {
 type EveType struct {
 	ID                int64       `json:"id"`
 	GroupID           int64       `json:"group_id"`
+	CategoryID        int64       `json:"category_id"`
 	MarketGroupID     int64       `json:"market_group_id"`
 	Name              string      `json:"name"`
 	Aliases           []string    `json:"aliases"`
 	Volume            float64     `json:"volume"`
 	PackagedVolume    float64     `json:"packaged_volume"`
 	BasePrice         float64     `json:"base_price"`
+	PortionSize       int64       `json:"portion_size,omitempty"`
 	BlueprintProducts []Component `json:"blueprint_products,omitempty"`
 	Components        []Component `json:"components,omitempty"`
 	BaseComponents    []Component `json:"base_components,omitempty"`
+	ManufacturingTime int64       `json:"manufacturing_time,omitempty"`
+	Materials         []Component `json:"materials,omitempty"`
 }
 
 // Component defines what is needed and how many is needed to make something else
@@ -0,0 +1,127 @@
+package evepraisal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Codes for the warnings that can be attached to an appraisal item or a loyalty store offer
+const (
+	WarningCCPPrice       = "ccp_price"
+	WarningUniversePrice  = "universe_price"
+	WarningComponentPrice = "component_price"
+	WarningBasePrice      = "base_price"
+	WarningStalePrice     = "stale_price"
+	WarningLowSellVolume  = "low_sell_volume"
+	WarningLowBuyVolume   = "low_buy_volume"
+	WarningNoPrice        = "no_price"
+	WarningLowDailyVolume = "low_daily_volume"
+	WarningBPCValue       = "bpc_value"
+)
+
+var warningLabels = map[string]string{
+	WarningCCPPrice:       "CCP Price",
+	WarningUniversePrice:  "Universe Price",
+	WarningComponentPrice: "Component Price",
+	WarningBasePrice:      "Base Price",
+	WarningStalePrice:     "Stale",
+	WarningLowSellVolume:  "Low Sell Volume",
+	WarningLowBuyVolume:   "Low Buy Volume",
+	WarningNoPrice:        "No Price",
+	WarningLowDailyVolume: "Low Daily Volume",
+	WarningBPCValue:       "BPC",
+}
+
+// ItemWarning flags a reason why the price of an appraisal item might not be trustworthy
+type ItemWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Label is a short name for the warning that is shown in the UI
+func (w ItemWarning) Label() string {
+	label, ok := warningLabels[w.Code]
+	if !ok {
+		return w.Code
+	}
+	return label
+}
+
+// WarningsForItem returns warnings for anything that makes the prices of the given item suspect
+func (app *App) WarningsForItem(market string, item AppraisalItem, now time.Time) []ItemWarning {
+	var warnings []ItemWarning
+	prices := item.Prices
+
+	switch prices.Strategy {
+	case "ccp":
+		warnings = append(warnings, ItemWarning{
+			Code:    WarningCCPPrice,
+			Message: fmt.Sprintf("There are too few orders in %s so CCP's average price is used", market),
+		})
+	case "orders_universe":
+		warnings = append(warnings, ItemWarning{
+			Code:    WarningUniversePrice,
+			Message: fmt.Sprintf("There are too few orders in %s so prices from every region are used", market),
+		})
+	case "component":
+		warnings = append(warnings, ItemWarning{
+			Code:    WarningComponentPrice,
+			Message: "There is no market price so the value of the components needed to build it is used",
+		})
+	case "base_price":
+		warnings = append(warnings, ItemWarning{
+			Code:    WarningBasePrice,
+			Message: "There is no market price so the base price from the static data is used",
+		})
+	}
+
+	updated := app.pricesConfirmed(market, prices)
+	if app.StalePriceAge > 0 && !updated.IsZero() && now.Sub(updated) > app.StalePriceAge {
+		warnings = append(warnings, ItemWarning{
+			Code:    WarningStalePrice,
+			Message: fmt.Sprintf("Prices were last updated %s", humanize.RelTime(updated, now, "ago", "from now")),
+		})
+	}
+
+	// Volume is only known for prices that come from orders
+	if prices.Strategy == "orders" || prices.Strategy == "orders_universe" || (prices.Strategy == "" && prices.All.OrderCount > 0) {
+		if prices.Sell.Volume < item.Quantity {
+			warnings = append(warnings, ItemWarning{
+				Code:    WarningLowSellVolume,
+				Message: fmt.Sprintf("Only %s are listed on sell orders", humanize.Comma(prices.Sell.Volume)),
+			})
+		}
+		if prices.Buy.Volume < item.Quantity {
+			warnings = append(warnings, ItemWarning{
+				Code:    WarningLowBuyVolume,
+				Message: fmt.Sprintf("Only %s are wanted by buy orders", humanize.Comma(prices.Buy.Volume)),
+			})
+		}
+	}
+
+	return warnings
+}
+
+// pricesConfirmed returns when the given prices were last known to be current. Prices from orders are only rewritten
+// when the orders change, so orders that were fetched again without changing still count.
+func (app *App) pricesConfirmed(market string, prices Prices) time.Time {
+	if app.OrdersFetched == nil {
+		return prices.Updated
+	}
+
+	switch {
+	case prices.Strategy == "orders":
+	case prices.Strategy == "orders_universe" || (prices.Strategy == "" && prices.All.OrderCount > 0):
+		market = UniverseMarketName
+	default:
+		return prices.Updated
+	}
+
+	fetched, ok := app.OrdersFetched.OrdersFetched(market)
+	if ok && fetched.After(prices.Updated) {
+		return fetched
+	}
+	return prices.Updated
+}
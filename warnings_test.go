@@ -0,0 +1,54 @@
+package evepraisal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func warningCodes(warnings []ItemWarning) []string {
+	codes := make([]string, len(warnings))
+	for i, warning := range warnings {
+		codes[i] = warning.Code
+	}
+	return codes
+}
+
+func TestWarningsForItem(t *testing.T) {
+	now := time.Now()
+	app := &App{StalePriceAge: time.Hour}
+
+	item := AppraisalItem{Quantity: 10}
+	item.Prices.Strategy = "orders"
+	item.Prices.Updated = now
+	item.Prices.Sell.Volume = 100
+	item.Prices.Buy.Volume = 100
+	assert.Empty(t, app.WarningsForItem("jita", item, now))
+
+	item.Quantity = 500
+	assert.Equal(t, []string{WarningLowSellVolume, WarningLowBuyVolume}, warningCodes(app.WarningsForItem("jita", item, now)))
+
+	item.Quantity = 10
+	item.Prices.Strategy = "orders_universe"
+	item.Prices.Updated = now.Add(-2 * time.Hour)
+	assert.Equal(t, []string{WarningUniversePrice, WarningStalePrice}, warningCodes(app.WarningsForItem("jita", item, now)))
+
+	// CCP prices have no volume so they don't get volume warnings
+	item.Quantity = 500
+	item.Prices.Strategy = "ccp"
+	item.Prices.Updated = now
+	warnings := app.WarningsForItem("jita", item, now)
+	assert.Equal(t, []string{WarningCCPPrice}, warningCodes(warnings))
+	assert.Equal(t, "CCP Price", warnings[0].Label())
+
+	item.Prices.Strategy = "component"
+	assert.Equal(t, []string{WarningComponentPrice}, warningCodes(app.WarningsForItem("jita", item, now)))
+
+	item.Prices.Strategy = "ccp"
+
+	// Stale warnings can be turned off
+	app.StalePriceAge = 0
+	item.Prices.Updated = now.Add(-48 * time.Hour)
+	assert.Equal(t, []string{WarningCCPPrice}, warningCodes(app.WarningsForItem("jita", item, now)))
+}
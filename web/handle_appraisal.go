@@ -39,6 +39,8 @@ type AppraisalPage struct {
 	Appraisal *evepraisal.Appraisal `json:"appraisal"`
 	ShowFull  bool                  `json:"show_full,omitempty"`
 	IsOwner   bool                  `json:"is_owner,omitempty"`
+	// CanQuoteFreight is true when there are freight services to quote
+	CanQuoteFreight bool `json:"-"`
 }
 
 // AppraisalDebugPage is the data needed to render the
@@ -126,6 +128,152 @@ func getRequestParam(r *http.Request, name string) string {
 	return r.URL.Query().Get(name)
 }
 
+// parseReprocessingOptions returns the reprocessing options from the request or nil if reprocessing wasn't asked for.
+// Options that aren't given use the defaults.
+func parseReprocessingOptions(r *http.Request) (*evepraisal.ReprocessingOptions, error) {
+	if getRequestParam(r, "reprocess") != "yes" {
+		return nil, nil
+	}
+
+	options := evepraisal.DefaultReprocessingOptions
+	floatParams := map[string]*float64{
+		"reprocess_rig":       &options.RigBonus,
+		"reprocess_security":  &options.SecurityBonus,
+		"reprocess_structure": &options.StructureBonus,
+		"reprocess_implant":   &options.ImplantBonus,
+		"reprocess_tax":       &options.Tax,
+	}
+	for name, value := range floatParams {
+		if s := getRequestParam(r, name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s value: %s", name, err)
+			}
+			*value = f
+		}
+	}
+
+	skillParams := map[string]*int64{
+		"reprocess_skill":            &options.ReprocessingSkill,
+		"reprocess_efficiency_skill": &options.ReprocessingEfficiencySkill,
+		"reprocess_processing_skill": &options.ProcessingSkill,
+		"reprocess_scrapmetal_skill": &options.ScrapmetalSkill,
+	}
+	for name, value := range skillParams {
+		if s := getRequestParam(r, name); s != "" {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s value: %s", name, err)
+			}
+			*value = i
+		}
+	}
+
+	return &options, options.Validate()
+}
+
+// parseBestPriceOptions returns the best price options from the request or nil if best price mode wasn't asked for
+func parseBestPriceOptions(r *http.Request) (*evepraisal.BestPriceOptions, error) {
+	if getRequestParam(r, "best_price") != "yes" {
+		return nil, nil
+	}
+
+	var options evepraisal.BestPriceOptions
+	intParams := map[string]*int64{
+		"best_price_origin":    &options.OriginSystemID,
+		"best_price_max_jumps": &options.MaxJumps,
+	}
+	for name, value := range intParams {
+		if s := getRequestParam(r, name); s != "" {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s value: %s", name, err)
+			}
+			*value = i
+		}
+	}
+
+	return &options, options.Validate()
+}
+
+// parseSellPlanOptions returns the sell plan options from the request or nil if a sell plan wasn't asked for. Options
+// that aren't given use the defaults and the NPC station broker fee is used when no structure broker fee is given.
+func parseSellPlanOptions(r *http.Request) (*evepraisal.SellPlanOptions, error) {
+	if getRequestParam(r, "sell_plan") != "yes" {
+		return nil, nil
+	}
+
+	options := evepraisal.DefaultSellPlanOptions
+	intParams := map[string]*int64{
+		"sell_plan_accounting":       &options.AccountingSkill,
+		"sell_plan_broker_relations": &options.BrokerRelationsSkill,
+		"sell_plan_relists":          &options.Relists,
+	}
+	for name, value := range intParams {
+		if s := getRequestParam(r, name); s != "" {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s value: %s", name, err)
+			}
+			*value = i
+		}
+	}
+
+	if s := getRequestParam(r, "sell_plan_undercut"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid sell_plan_undercut value: %s", err)
+		}
+		options.Undercut = f
+	}
+	if s := getRequestParam(r, "sell_plan_broker_fee"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid sell_plan_broker_fee value: %s", err)
+		}
+		options.StructureBrokerFee = &f
+	}
+
+	return &options, options.Validate()
+}
+
+// parseFreightOptions returns the freight options from the request or nil if freight quotes weren't asked for
+func (ctx *Context) parseFreightOptions(r *http.Request) (*evepraisal.FreightOptions, error) {
+	if getRequestParam(r, "freight") != "yes" {
+		return nil, nil
+	}
+	options := &evepraisal.FreightOptions{Destination: getRequestParam(r, "freight_destination")}
+	return options, ctx.checkFreightOptions(*options)
+}
+
+// checkFreightOptions returns an error if there are no freight services or if the destination isn't a market
+func (ctx *Context) checkFreightOptions(options evepraisal.FreightOptions) error {
+	if len(ctx.App.FreightServices) == 0 {
+		return fmt.Errorf("No freight services are configured.")
+	}
+	if options.Destination != "" {
+		if _, ok := ctx.App.GetMarket(options.Destination); !ok {
+			return fmt.Errorf("Destination market %q is not valid.", options.Destination)
+		}
+	}
+	return nil
+}
+
+// getBuybackProgram returns the buyback program with the given name or nil if no name is given
+func (ctx *Context) getBuybackProgram(name string) (*evepraisal.BuybackProgram, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if ctx.App.BuybackDB == nil {
+		return nil, evepraisal.ErrBuybackProgramNotFound
+	}
+	program, err := ctx.App.BuybackDB.GetBuybackProgram(name)
+	if err != nil {
+		return nil, err
+	}
+	return &program, nil
+}
+
 func parseAppraisalBody(r *http.Request) (string, error) {
 	// Parse body
 	var (
@@ -191,6 +339,38 @@ func (ctx *Context) HandleAppraisal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	simulateDepth := getRequestParam(r, "simulate_depth") == "yes"
+
+	pricing := getRequestParam(r, "pricing")
+	if _, ok := evepraisal.GetPricingPolicy(pricing); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given pricing policy is not valid.")
+		return
+	}
+	if pricing == "" {
+		pricing = evepraisal.DefaultPricingPolicy
+	}
+
+	reprocessing, err := parseReprocessingOptions(r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid reprocessing options", err.Error())
+		return
+	}
+
+	bestPrice, err := parseBestPriceOptions(r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid best price options", err.Error())
+		return
+	}
+
+	buyback, err := ctx.getBuybackProgram(getRequestParam(r, "program"))
+	if err == evepraisal.ErrBuybackProgramNotFound {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid program", err.Error())
+		return
+	} else if err != nil {
+		ctx.renderServerError(r, w, err)
+		return
+	}
+
 	expireAfterStr := getRequestParam(r, "expire_after")
 	if expireAfterStr == "" {
 		expireAfterStr = "360h"
@@ -230,14 +410,7 @@ func (ctx *Context) HandleAppraisal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Invalid market given
-	foundMarket := false
-	for _, m := range selectableMarkets {
-		if m.Name == market {
-			foundMarket = true
-			break
-		}
-	}
-	if !foundMarket {
+	if _, ok := ctx.App.GetMarket(market); !ok {
 		ctx.renderErrorPageWithRoot(r, w, http.StatusBadRequest, "Invalid input", "Given market is not valid.", root)
 		return
 	}
@@ -251,7 +424,15 @@ func (ctx *Context) HandleAppraisal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Actually do the appraisal
-	appraisal, err := ctx.App.StringToAppraisal(market, body, pricePercentage)
+	appraisal, err := ctx.App.StringToAppraisal(body, evepraisal.AppraisalOptions{
+		MarketName:      market,
+		PricePercentage: pricePercentage,
+		Pricing:         pricing,
+		SimulateDepth:   simulateDepth,
+		Reprocessing:    reprocessing,
+		Buyback:         buyback,
+		BestPrice:       bestPrice,
+	})
 	if err == evepraisal.ErrNoValidLinesFound {
 		log.Println("No valid lines found:", spew.Sdump(body))
 		ctx.renderErrorPageWithRoot(r, w, http.StatusBadRequest, "Invalid input", err.Error(), root)
@@ -292,6 +473,13 @@ func (ctx *Context) HandleAppraisal(w http.ResponseWriter, r *http.Request) {
 	ctx.setSessionValue(r, w, "visibility", visibility)
 	ctx.setSessionValue(r, w, "persist", persist)
 	ctx.setSessionValue(r, w, "price_percentage", pricePercentage)
+	ctx.setSessionValue(r, w, "pricing", pricing)
+	ctx.setSessionValue(r, w, "simulate_depth", simulateDepth)
+	ctx.setSessionValue(r, w, "best_price", bestPrice != nil)
+	ctx.setSessionValue(r, w, "reprocess", reprocessing != nil)
+	if reprocessing != nil {
+		ctx.setSessionValue(r, w, "reprocessing", *reprocessing)
+	}
 	ctx.setSessionValue(r, w, "expire_after", expireAfterStr)
 
 	sort.Slice(appraisal.Items, func(i, j int) bool {
@@ -301,8 +489,9 @@ func (ctx *Context) HandleAppraisal(w http.ResponseWriter, r *http.Request) {
 	// Render the new appraisal to the screen (there is no redirect here, we set the URL using javascript later)
 	w.Header().Add("X-Appraisal-ID", appraisal.ID)
 	root.Page = AppraisalPage{
-		IsOwner:   IsAppraisalOwner(user, appraisal),
-		Appraisal: cleanAppraisal(appraisal),
+		IsOwner:         IsAppraisalOwner(user, appraisal),
+		Appraisal:       cleanAppraisal(appraisal),
+		CanQuoteFreight: len(ctx.App.FreightServices) > 0,
 	}
 	_ = ctx.renderWithRoot(r, w, "appraisal.html", root)
 }
@@ -316,8 +505,15 @@ func (ctx *Context) HandleAppraisalStructured(w http.ResponseWriter, r *http.Req
 
 	decoder := json.NewDecoder(r.Body)
 	var spec = struct {
-		MarketName string `json:"market_name"`
-		Items      []struct {
+		MarketName    string                          `json:"market_name"`
+		Pricing       string                          `json:"pricing"`
+		SimulateDepth bool                            `json:"simulate_depth"`
+		Reprocessing  *evepraisal.ReprocessingOptions `json:"reprocessing"`
+		Program       string                          `json:"program"`
+		BestPrice     *evepraisal.BestPriceOptions    `json:"best_price"`
+		SellPlan      *evepraisal.SellPlanOptions     `json:"sell_plan"`
+		Freight       *evepraisal.FreightOptions      `json:"freight"`
+		Items         []struct {
 			TypeID   int64  `json:"type_id"`
 			Name     string `json:"name"`
 			Quantity int64  `json:"quantity"`
@@ -335,23 +531,75 @@ func (ctx *Context) HandleAppraisalStructured(w http.ResponseWriter, r *http.Req
 	}
 
 	// Invalid market given
-	foundMarket := false
-	for _, m := range selectableMarkets {
-		if m.Name == spec.MarketName {
-			foundMarket = true
-			break
+	if _, ok := ctx.App.GetMarket(spec.MarketName); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given market is not valid.")
+		return
+	}
+
+	// Invalid pricing policy given
+	if _, ok := evepraisal.GetPricingPolicy(spec.Pricing); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given pricing policy is not valid.")
+		return
+	}
+	if spec.Pricing == "" {
+		spec.Pricing = evepraisal.DefaultPricingPolicy
+	}
+
+	// Invalid reprocessing options given
+	if spec.Reprocessing != nil {
+		err = spec.Reprocessing.Validate()
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid reprocessing options", err.Error())
+			return
 		}
 	}
-	if !foundMarket {
-		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given market is not valid.")
+
+	// Invalid best price options given
+	if spec.BestPrice != nil {
+		err = spec.BestPrice.Validate()
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid best price options", err.Error())
+			return
+		}
+	}
+
+	// Invalid sell plan options given
+	if spec.SellPlan != nil {
+		err = spec.SellPlan.Validate()
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid sell plan options", err.Error())
+			return
+		}
+	}
+
+	// Invalid freight options given
+	if spec.Freight != nil {
+		err = ctx.checkFreightOptions(*spec.Freight)
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid freight options", err.Error())
+			return
+		}
+	}
+
+	buyback, err := ctx.getBuybackProgram(spec.Program)
+	if err == evepraisal.ErrBuybackProgramNotFound {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid program", err.Error())
+		return
+	} else if err != nil {
+		ctx.renderServerError(r, w, err)
 		return
 	}
 
 	appraisal := &evepraisal.Appraisal{
-		Created:    time.Now().Unix(),
-		Kind:       "structured",
-		Items:      make([]evepraisal.AppraisalItem, len(spec.Items)),
-		MarketName: spec.MarketName,
+		Created:       time.Now().Unix(),
+		Kind:          "structured",
+		Items:         make([]evepraisal.AppraisalItem, len(spec.Items)),
+		MarketName:    spec.MarketName,
+		Pricing:       spec.Pricing,
+		SimulateDepth: spec.SimulateDepth,
+		Reprocessing:  spec.Reprocessing,
+		Buyback:       buyback,
+		BestPrice:     spec.BestPrice,
 	}
 
 	for i, item := range spec.Items {
@@ -369,6 +617,12 @@ func (ctx *Context) HandleAppraisalStructured(w http.ResponseWriter, r *http.Req
 	}
 
 	ctx.App.PopulateItems(appraisal)
+	if spec.SellPlan != nil {
+		appraisal.PlanSales(*spec.SellPlan)
+	}
+	if spec.Freight != nil {
+		ctx.App.QuoteFreight(appraisal, *spec.Freight)
+	}
 
 	go func() {
 		err := ctx.App.AppraisalDB.IncrementTotalAppraisals()
@@ -391,6 +645,35 @@ func (ctx *Context) HandleAppraisalStructured(w http.ResponseWriter, r *http.Req
 	)
 }
 
+// getViewableAppraisal returns the appraisal with the given ID if the current user can see it, and whether they own
+// it. A private appraisal can only be seen by its owner or with its private token. An error page is rendered and ok is
+// false when the appraisal can't be seen.
+func (ctx *Context) getViewableAppraisal(w http.ResponseWriter, r *http.Request, appraisalID string) (appraisal *evepraisal.Appraisal, isOwner bool, ok bool) {
+	appraisal, err := ctx.App.AppraisalDB.GetAppraisal(appraisalID, !user_agent.New(r.UserAgent()).Bot())
+	if err == evepraisal.ErrAppraisalNotFound {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
+		return nil, false, false
+	} else if err != nil {
+		ctx.renderServerError(r, w, err)
+		return nil, false, false
+	}
+
+	user := ctx.GetCurrentUser(r)
+	isOwner = IsAppraisalOwner(user, appraisal)
+
+	if appraisal.Private {
+		correctToken := appraisal.PrivateToken == bone.GetValue(r, "privateToken")
+		if !(isOwner || correctToken) {
+			ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
+			return nil, false, false
+		}
+	} else if bone.GetValue(r, "privateToken") != "" {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
+		return nil, false, false
+	}
+	return appraisal, isOwner, true
+}
+
 // HandleViewAppraisal is the handler for /a/[id]
 func (ctx *Context) HandleViewAppraisal(w http.ResponseWriter, r *http.Request) {
 	// Legacy Logic
@@ -407,26 +690,19 @@ func (ctx *Context) HandleViewAppraisal(w http.ResponseWriter, r *http.Request)
 		appraisalID = evepraisal.Uint64ToAppraisalID(legacyAppraisalID) + suffix
 	}
 
-	appraisal, err := ctx.App.AppraisalDB.GetAppraisal(appraisalID, !user_agent.New(r.UserAgent()).Bot())
-	if err == evepraisal.ErrAppraisalNotFound {
-		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
-		return
-	} else if err != nil {
-		ctx.renderServerError(r, w, err)
+	appraisal, isOwner, ok := ctx.getViewableAppraisal(w, r, appraisalID)
+	if !ok {
 		return
 	}
 
-	user := ctx.GetCurrentUser(r)
-	isOwner := IsAppraisalOwner(user, appraisal)
-
-	if appraisal.Private {
-		correctToken := appraisal.PrivateToken == bone.GetValue(r, "privateToken")
-		if !(isOwner || correctToken) {
-			ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
-			return
-		}
-	} else if bone.GetValue(r, "privateToken") != "" {
-		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
+	sellPlan, err := parseSellPlanOptions(r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid sell plan options", err.Error())
+		return
+	}
+	freight, err := ctx.parseFreightOptions(r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid freight options", err.Error())
 		return
 	}
 
@@ -435,6 +711,12 @@ func (ctx *Context) HandleViewAppraisal(w http.ResponseWriter, r *http.Request)
 		ctx.App.PopulateItems(appraisal)
 		appraisal.Created = time.Now().Unix()
 	}
+	if sellPlan != nil {
+		appraisal.PlanSales(*sellPlan)
+	}
+	if freight != nil {
+		ctx.App.QuoteFreight(appraisal, *freight)
+	}
 
 	appraisal = cleanAppraisal(appraisal)
 
@@ -460,9 +742,10 @@ func (ctx *Context) HandleViewAppraisal(w http.ResponseWriter, r *http.Request)
 
 	_ = ctx.render(r, w, "appraisal.html",
 		AppraisalPage{
-			Appraisal: appraisal,
-			ShowFull:  getRequestParam(r, "full") != "",
-			IsOwner:   isOwner,
+			Appraisal:       appraisal,
+			ShowFull:        getRequestParam(r, "full") != "",
+			IsOwner:         isOwner,
+			CanQuoteFreight: len(ctx.App.FreightServices) > 0,
 		})
 }
 
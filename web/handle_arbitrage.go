@@ -0,0 +1,103 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// maxArbitrageResults limits how many opportunities a single /arbitrage request returns
+var maxArbitrageResults = 500
+
+// ArbitragePage is the response of /arbitrage
+type ArbitragePage struct {
+	Opportunities []evepraisal.ArbitrageOpportunity `json:"opportunities"`
+	Markets       []string                          `json:"markets"`
+	Updated       time.Time                         `json:"updated"`
+	Filter        evepraisal.ArbitrageFilter        `json:"-"`
+	Limit         int                               `json:"-"`
+}
+
+// parseArbitrageFilter reads the filters from the request. Missing filters don't filter anything.
+func parseArbitrageFilter(r *http.Request) (evepraisal.ArbitrageFilter, error) {
+	var (
+		filter evepraisal.ArbitrageFilter
+		err    error
+	)
+	floats := []struct {
+		name  string
+		value *float64
+	}{
+		{"min_margin", &filter.MinMargin},
+		{"max_volume", &filter.MaxTypeVolume},
+		{"min_isk_per_m3", &filter.MinISKPerM3},
+	}
+	for _, f := range floats {
+		param := getRequestParam(r, f.name)
+		if param == "" {
+			continue
+		}
+		*f.value, err = strconv.ParseFloat(param, 64)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s value: %s", f.name, err)
+		}
+	}
+
+	ints := []struct {
+		name  string
+		value *int64
+	}{
+		{"min_order_volume", &filter.MinOrderVolume},
+		{"market_group", &filter.MarketGroupID},
+	}
+	for _, i := range ints {
+		param := getRequestParam(r, i.name)
+		if param == "" {
+			continue
+		}
+		*i.value, err = strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s value: %s", i.name, err)
+		}
+	}
+	return filter, nil
+}
+
+// HandleArbitrage is the handler for /arbitrage. It lists the types that can be bought in one hub and sold into buy
+// orders in another for a profit. The spreads are found when prices are refreshed, so this only filters them.
+func (ctx *Context) HandleArbitrage(w http.ResponseWriter, r *http.Request) {
+	if ctx.App.Arbitrage == nil {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "Arbitrage is not enabled.")
+		return
+	}
+
+	filter, err := parseArbitrageFilter(r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxArbitrageResults {
+		limit = maxArbitrageResults
+	}
+
+	opportunities, updated := ctx.App.Arbitrage.Opportunities(filter)
+	if len(opportunities) > limit {
+		opportunities = opportunities[:limit]
+	}
+
+	_ = ctx.render(r, w, "arbitrage.html", ArbitragePage{
+		Opportunities: opportunities,
+		Markets:       ctx.App.Arbitrage.Options().Markets,
+		Updated:       updated,
+		Filter:        filter,
+		Limit:         limit,
+	})
+}
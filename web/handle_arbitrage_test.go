@@ -0,0 +1,81 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleArbitrage(t *testing.T) {
+	finder := evepraisal.NewArbitrageFinder(testUpdateNothingPriceDB{}, evepraisal.ArbitrageOptions{Markets: []string{"jita", "amarr"}, MinMargin: 5})
+	typeDB := testTypeDB{types: []typedb.EveType{
+		{ID: 34, Name: "Tritanium", Volume: 0.01, MarketGroupID: 1857},
+		{ID: 35, Name: "Cargo Container", Volume: 10, MarketGroupID: 1651},
+	}}
+	finder.Types = typeDB.GetTypeByID
+
+	prices := func(market string, typeID int64, sell, buy float64) evepraisal.MarketItemPrices {
+		p := evepraisal.Prices{Strategy: "orders"}
+		p.Sell.Min, p.Sell.Volume = sell, 1000
+		p.Buy.Max, p.Buy.Volume = buy, 1000
+		return evepraisal.MarketItemPrices{Market: market, TypeID: typeID, Prices: p}
+	}
+	assert.NoError(t, finder.UpdatePrices([]evepraisal.MarketItemPrices{
+		prices("jita", 34, 4, 3), prices("amarr", 34, 6, 5),
+		prices("jita", 35, 100, 90), prices("amarr", 35, 200, 150),
+	}))
+
+	ctx := &Context{
+		App:         &evepraisal.App{Arbitrage: finder},
+		CookieStore: sessions.NewCookieStore([]byte("secret")),
+	}
+	assert.NoError(t, ctx.Reload())
+
+	get := func(query string, format string) *httptest.ResponseRecorder {
+		return testGet(ctx.HandleArbitrage, "/arbitrage?"+query, format)
+	}
+
+	w := get("", formatJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page ArbitragePage
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Equal(t, []string{"jita", "amarr"}, page.Markets)
+	if assert.Len(t, page.Opportunities, 2) {
+		assert.Equal(t, int64(35), page.Opportunities[0].TypeID)
+		assert.Equal(t, int64(34), page.Opportunities[1].TypeID)
+	}
+
+	w = get("max_volume=1&market_group=1857", formatJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	page = ArbitragePage{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	if assert.Len(t, page.Opportunities, 1) {
+		assert.Equal(t, "Tritanium", page.Opportunities[0].TypeName)
+	}
+
+	w = get("min_isk_per_m3=10&limit=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "Tritanium"))
+	assert.False(t, strings.Contains(w.Body.String(), "Cargo Container"))
+
+	assertBadQueries(t, ctx.HandleArbitrage, "/arbitrage", []string{"min_margin=lots", "market_group=1.5", "min_order_volume=x"})
+
+	ctx.App.Arbitrage = nil
+	assert.Equal(t, http.StatusNotFound, get("", formatJSON).Code)
+}
+
+// testUpdateNothingPriceDB drops the prices that are saved to it
+type testUpdateNothingPriceDB struct {
+	evepraisal.PriceDB
+}
+
+func (db testUpdateNothingPriceDB) UpdatePrices(items []evepraisal.MarketItemPrices) error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/go-zoo/bone"
+)
+
+// parseHaulOptions reads the capacity and the markets to haul between from the request. from defaults to the
+// appraisal's market.
+func (ctx *Context) parseHaulOptions(r *http.Request, appraisal *evepraisal.Appraisal) (evepraisal.HaulOptions, error) {
+	options := evepraisal.HaulOptions{
+		From: getRequestParam(r, "from"),
+		To:   getRequestParam(r, "to"),
+	}
+	if options.From == "" {
+		options.From = appraisal.MarketName
+	}
+
+	capacity := getRequestParam(r, "capacity")
+	if capacity == "" {
+		return options, fmt.Errorf("No 'capacity' given.")
+	}
+	var err error
+	options.Capacity, err = strconv.ParseFloat(capacity, 64)
+	if err != nil {
+		return options, fmt.Errorf("Invalid capacity value: %s", err)
+	}
+
+	for _, market := range []string{options.From, options.To} {
+		if market == "" {
+			continue
+		}
+		if _, ok := ctx.App.GetMarket(market); !ok {
+			return options, fmt.Errorf("Market %q is not valid.", market)
+		}
+	}
+	return options, options.Validate()
+}
+
+// HandleHaulAppraisal is the handler for /a/[id]/haul. It plans what to load into a ship's cargo to move the most
+// value, or to make the most profit between two markets. The plan is returned as JSON or as text that can be pasted
+// into the multibuy window.
+func (ctx *Context) HandleHaulAppraisal(w http.ResponseWriter, r *http.Request) {
+	appraisal, _, ok := ctx.getViewableAppraisal(w, r, bone.GetValue(r, "appraisalID"))
+	if !ok {
+		return
+	}
+
+	options, err := ctx.parseHaulOptions(r, appraisal)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid haul options", err.Error())
+		return
+	}
+
+	if getRequestParam(r, "live") == "yes" {
+		ctx.App.PopulateItems(appraisal)
+	}
+
+	plan := ctx.App.PlanHaul(appraisal, options)
+	if r.Header.Get("format") == formatJSON {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(plan)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, plan.Multibuy())
+}
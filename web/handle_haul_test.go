@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAppraisalDB struct {
+	evepraisal.AppraisalDB
+	appraisal evepraisal.Appraisal
+}
+
+func (db testAppraisalDB) GetAppraisal(appraisalID string, updateUsedTime bool) (*evepraisal.Appraisal, error) {
+	appraisal := db.appraisal
+	return &appraisal, nil
+}
+
+func TestHandleHaulAppraisal(t *testing.T) {
+	ctx := &Context{
+		App: &evepraisal.App{
+			AppraisalDB: testAppraisalDB{appraisal: evepraisal.Appraisal{
+				MarketName: "jita",
+				Items: []evepraisal.AppraisalItem{
+					{TypeID: 34, TypeName: "Tritanium", TypeVolume: 0.01, Quantity: 1000, Prices: evepraisal.Prices{}.Set(4)},
+					{TypeID: 35, TypeName: "Cargo Container", TypeVolume: 10, Quantity: 5, Prices: evepraisal.Prices{}.Set(1000)},
+				},
+			}},
+			Markets: []evepraisal.Market{{Name: "jita"}, {Name: "amarr"}},
+		},
+		CookieStore: sessions.NewCookieStore([]byte("secret")),
+	}
+
+	w := testGet(ctx.HandleHaulAppraisal, "/a/abc/haul?capacity=25", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Tritanium\t1000\nCargo Container\t1\n", w.Body.String())
+
+	w = testGet(ctx.HandleHaulAppraisal, "/a/abc/haul?capacity=25", formatJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var plan evepraisal.HaulPlan
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&plan))
+	assert.Equal(t, evepraisal.HaulGoalValue, plan.Goal)
+	assert.Len(t, plan.Items, 2)
+	assert.InDelta(t, 5000, plan.Value, 0.0001)
+
+	assertBadQueries(t, ctx.HandleHaulAppraisal, "/a/abc/haul", []string{"", "capacity=big", "capacity=0", "capacity=10&to=unknown", "capacity=10&to=jita"})
+}
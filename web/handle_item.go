@@ -1,9 +1,11 @@
 package web
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	evepraisal "github.com/evepraisal/go-evepraisal"
 	"github.com/evepraisal/go-evepraisal/typedb"
@@ -23,6 +25,21 @@ type itemResult struct {
 	Summaries []viewItemMarketSummary `json:"summaries"`
 }
 
+// ItemHistoryPage holds price snapshots for a single item in a single market
+type ItemHistoryPage struct {
+	TypeID     int64               `json:"type_id"`
+	MarketName string              `json:"market_name"`
+	From       time.Time           `json:"from"`
+	To         time.Time           `json:"to"`
+	History    []evepraisal.Prices `json:"history"`
+}
+
+// ItemManufacturePage holds the manufacturing breakdown of a single item
+type ItemManufacturePage struct {
+	Type      typedb.EveType                   `json:"type"`
+	Breakdown *evepraisal.ManufactureBreakdown `json:"breakdown"`
+}
+
 type componentDetails struct {
 	Type     typedb.EveType    `json:"type"`
 	Quantity int64             `json:"quantity"`
@@ -61,7 +78,7 @@ func (ctx *Context) HandleViewItems(w http.ResponseWriter, r *http.Request) {
 	for i, t := range types {
 
 		var summaries []viewItemMarketSummary
-		for _, market := range selectableMarkets {
+		for _, market := range ctx.selectableMarkets() {
 			prices, ok := ctx.App.PriceDB.GetPrice(market.Name, t.ID)
 			if !ok {
 				// No market data
@@ -120,7 +137,7 @@ func (ctx *Context) HandleViewItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var summaries []viewItemMarketSummary
-	for _, market := range selectableMarkets {
+	for _, market := range ctx.selectableMarkets() {
 		prices, ok := ctx.App.PriceDB.GetPrice(market.Name, item.ID)
 		if !ok {
 			// No market data
@@ -136,3 +153,117 @@ func (ctx *Context) HandleViewItem(w http.ResponseWriter, r *http.Request) {
 
 	_ = ctx.render(r, w, "view_item.html", itemResult{Type: item, Summaries: summaries})
 }
+
+// HandleViewItemHistory handles /item/[id]/history.json
+func (ctx *Context) HandleViewItemHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("format") != formatJSON {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not found", "/item/[id]/history is only available for the JSON format.")
+		return
+	}
+
+	typeID, err := strconv.ParseInt(bone.GetValue(r, "typeID"), 10, 64)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	market := r.FormValue("market")
+	if market == "" {
+		market = ctx.getSessionValueWithDefault(r, "market", ctx.App.DefaultMarketName())
+	}
+	if _, ok := ctx.App.GetMarket(market); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given market is not valid.")
+		return
+	}
+
+	days, err := strconv.ParseInt(r.FormValue("days"), 10, 64)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(days) * 24 * time.Hour)
+	history, err := ctx.App.PriceDB.GetPriceHistory(market, typeID, from, to)
+	if err != nil {
+		ctx.renderServerError(r, w, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ItemHistoryPage{
+		TypeID:     typeID,
+		MarketName: market,
+		From:       from,
+		To:         to,
+		History:    history,
+	})
+}
+
+// HandleViewItemManufacture handles /item/[id]/manufacture
+func (ctx *Context) HandleViewItemManufacture(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.ParseInt(bone.GetValue(r, "typeID"), 10, 64)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	item, ok := ctx.App.TypeDB.GetTypeByID(typeID)
+	if !ok {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "I couldn't find what you're looking for")
+		return
+	}
+
+	market := r.FormValue("market")
+	if market == "" {
+		market = ctx.getSessionValueWithDefault(r, "market", ctx.App.DefaultMarketName())
+	}
+	if _, ok := ctx.App.GetMarket(market); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Given market is not valid.")
+		return
+	}
+
+	options := evepraisal.ManufactureOptions{
+		Runs:           1,
+		StructureBonus: ctx.App.Industry.StructureBonus,
+		SystemID:       ctx.App.Industry.SystemID,
+	}
+	if s := r.FormValue("runs"); s != "" {
+		options.Runs, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || options.Runs < 1 || options.Runs > 10000 {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid runs value", "It needs to be between 1 and 10000")
+			return
+		}
+	}
+	if s := r.FormValue("me"); s != "" {
+		options.ME, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || options.ME < 0 || options.ME > 10 {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid me value", "It needs to be between 0 and 10")
+			return
+		}
+	}
+	if s := r.FormValue("structure_bonus"); s != "" {
+		options.StructureBonus, err = strconv.ParseFloat(s, 64)
+		if err != nil || options.StructureBonus < 0 || options.StructureBonus > 100 {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid structure_bonus value", "It needs to be between 0 and 100")
+			return
+		}
+	}
+	if s := r.FormValue("system_id"); s != "" {
+		options.SystemID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid system_id value", err.Error())
+			return
+		}
+	}
+
+	breakdown, ok := ctx.App.ManufactureBreakdown(market, typeID, options)
+	if !ok {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "This item can't be manufactured")
+		return
+	}
+
+	_ = ctx.render(r, w, "view_item_manufacture.html", ItemManufacturePage{Type: item, Breakdown: breakdown})
+}
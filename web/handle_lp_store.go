@@ -0,0 +1,70 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// LPStorePage is the response of /lp-store
+type LPStorePage struct {
+	CorporationID int64                `json:"corporation_id"`
+	Market        string               `json:"market"`
+	Pricing       string               `json:"pricing"`
+	Offers        []evepraisal.LPOffer `json:"offers"`
+}
+
+// HandleLPStore is the handler for /lp-store. It ranks the offers in a corporation's loyalty point store by the ISK
+// that each LP makes when the output is sold in the chosen market.
+func (ctx *Context) HandleLPStore(w http.ResponseWriter, r *http.Request) {
+	if ctx.App.LoyaltyStores == nil {
+		ctx.renderErrorPage(r, w, http.StatusNotFound, "Not Found", "Loyalty stores are not enabled.")
+		return
+	}
+
+	page := LPStorePage{
+		Market:  getRequestParam(r, "market"),
+		Pricing: getRequestParam(r, "pricing"),
+		Offers:  make([]evepraisal.LPOffer, 0),
+	}
+	if page.Market == "" {
+		page.Market = ctx.getSessionValueWithDefault(r, "market", ctx.App.DefaultMarketName())
+	}
+	if _, ok := ctx.App.GetMarket(page.Market); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Market not found.")
+		return
+	}
+	if page.Pricing == "" {
+		page.Pricing = ctx.getSessionValueWithDefault(r, "pricing", evepraisal.DefaultPricingPolicy)
+	}
+	if _, ok := evepraisal.GetPricingPolicy(page.Pricing); !ok {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Pricing policy not found.")
+		return
+	}
+
+	corporationID := getRequestParam(r, "corporation_id")
+	if corporationID == "" {
+		if r.Header.Get("format") == formatJSON {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "No 'corporation_id' given.")
+			return
+		}
+		_ = ctx.render(r, w, "lp_store.html", page)
+		return
+	}
+
+	var err error
+	page.CorporationID, err = strconv.ParseInt(corporationID, 10, 64)
+	if err != nil || page.CorporationID <= 0 {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "Invalid corporation_id.")
+		return
+	}
+
+	page.Offers, err = ctx.App.LPStoreOffers(page.CorporationID, evepraisal.LPStoreOptions{Market: page.Market, Pricing: page.Pricing})
+	if err != nil {
+		ctx.renderServerError(r, w, err)
+		return
+	}
+
+	_ = ctx.render(r, w, "lp_store.html", page)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLoyaltyStores map[int64][]evepraisal.LoyaltyOffer
+
+func (stores testLoyaltyStores) LoyaltyStoreOffers(corporationID int64) ([]evepraisal.LoyaltyOffer, error) {
+	return stores[corporationID], nil
+}
+
+// testLPPriceDB has prices in every market and no market history
+type testLPPriceDB struct {
+	evepraisal.PriceDB
+	prices map[int64]evepraisal.Prices
+}
+
+func (db testLPPriceDB) GetPrice(market string, typeID int64) (evepraisal.Prices, bool) {
+	prices, ok := db.prices[typeID]
+	return prices, ok
+}
+
+func (db testLPPriceDB) GetMarketHistory(regionID int64, typeID int64) (evepraisal.MarketHistory, bool) {
+	return evepraisal.MarketHistory{}, false
+}
+
+func TestHandleLPStore(t *testing.T) {
+	ctx := &Context{
+		App: &evepraisal.App{
+			TypeDB: testTypeDB{types: []typedb.EveType{
+				{ID: 34, Name: "Tritanium"},
+				{ID: 35, Name: "Pyerite"},
+			}},
+			PriceDB: testLPPriceDB{prices: map[int64]evepraisal.Prices{
+				34: evepraisal.Prices{Strategy: "orders"}.Set(5),
+				35: evepraisal.Prices{Strategy: "orders"}.Set(10),
+			}},
+			PriceFallbacks: []string{evepraisal.FallbackOrders},
+			Markets:        []evepraisal.Market{{Name: "jita", DisplayName: "Jita"}, {Name: "amarr", DisplayName: "Amarr"}},
+			LoyaltyStores: testLoyaltyStores{1000180: {
+				{OfferID: 1, TypeID: 34, Quantity: 1000, LPCost: 10, ISKCost: 1000},
+				{OfferID: 2, TypeID: 35, Quantity: 1000, LPCost: 10, ISKCost: 1000},
+			}},
+		},
+		CookieStore: sessions.NewCookieStore([]byte("secret")),
+	}
+	assert.NoError(t, ctx.Reload())
+
+	get := func(query string, format string) *httptest.ResponseRecorder {
+		return testGet(ctx.HandleLPStore, "/lp-store?"+query, format)
+	}
+
+	w := get("corporation_id=1000180&market=amarr", formatJSON)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page LPStorePage
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Equal(t, "amarr", page.Market)
+	assert.Equal(t, evepraisal.DefaultPricingPolicy, page.Pricing)
+	if assert.Len(t, page.Offers, 2) {
+		assert.Equal(t, int64(2), page.Offers[0].OfferID)
+		assert.InDelta(t, 900, page.Offers[0].ISKPerLP, 0.0001)
+		assert.Equal(t, int64(1), page.Offers[1].OfferID)
+	}
+
+	w = get("corporation_id=1000180", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "Pyerite"))
+
+	// The form is shown until a corporation is chosen
+	w = get("", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "corporation_id"))
+
+	assertBadQueries(t, ctx.HandleLPStore, "/lp-store", []string{"", "corporation_id=abc", "corporation_id=-1", "corporation_id=1000180&market=hek", "corporation_id=1000180&pricing=cheapest"})
+
+	ctx.App.LoyaltyStores = nil
+	assert.Equal(t, http.StatusNotFound, get("corporation_id=1000180", formatJSON).Code)
+}
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/evepraisal/go-evepraisal"
+)
+
+// maxBulkPriceTypes and maxBulkPriceMarkets limit how much a single /prices request can ask for
+var (
+	maxBulkPriceTypes   = 1000
+	maxBulkPriceMarkets = 20
+)
+
+// pricesBodySizeLimit is the largest /prices request body that is read. It leaves room for maxBulkPriceTypes type names.
+const pricesBodySizeLimit = int64(200 * 1000)
+
+// PricesPage is the response of /prices
+type PricesPage struct {
+	// Prices is keyed by market name and then type ID. Types without prices in a market are left out.
+	Prices map[string]map[int64]interface{} `json:"prices"`
+	// TypeIDs are the IDs of the types that were asked for by name
+	TypeIDs map[string]int64 `json:"type_ids,omitempty"`
+	// Unknown are the names and type IDs that didn't match any type
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+type pricesRequest struct {
+	Types   []interface{} `json:"types"`
+	Markets []string      `json:"markets"`
+	Fields  []string      `json:"fields"`
+}
+
+// parsePricesRequest reads the types, markets and fields from a JSON body or from comma separated "types", "markets"
+// and "fields" parameters
+func parsePricesRequest(w http.ResponseWriter, r *http.Request) (pricesRequest, error) {
+	var req pricesRequest
+	r.Body = http.MaxBytesReader(w, r.Body, pricesBodySizeLimit)
+	if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		return req, err
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return req, err
+	}
+	splitParam := func(name string) []string {
+		values := make([]string, 0)
+		for _, value := range r.Form[name] {
+			for _, v := range strings.Split(value, ",") {
+				v = strings.TrimSpace(v)
+				if v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+		return values
+	}
+	for _, t := range splitParam("types") {
+		req.Types = append(req.Types, t)
+	}
+	req.Markets = splitParam("markets")
+	req.Fields = splitParam("fields")
+	return req, nil
+}
+
+// HandlePrices is the handler for GET and POST /prices. It returns the prices of many types in many markets at once.
+// These are the stored prices without the price fallbacks that appraisals use. Every market is read on its own, so the
+// markets aren't a consistent snapshot if prices are updated in the middle of a request.
+func (ctx *Context) HandlePrices(w http.ResponseWriter, r *http.Request) {
+	// This is only available as JSON
+	r.Header.Set("format", formatJSON)
+
+	req, err := parsePricesRequest(w, r)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	if len(req.Types) == 0 {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", "No 'types' given.")
+		return
+	}
+	if len(req.Types) > maxBulkPriceTypes {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", fmt.Sprintf("At most %d types can be given.", maxBulkPriceTypes))
+		return
+	}
+	if len(req.Markets) == 0 {
+		req.Markets = []string{ctx.getSessionValueWithDefault(r, "market", ctx.App.DefaultMarketName())}
+	}
+	if len(req.Markets) > maxBulkPriceMarkets {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", fmt.Sprintf("At most %d markets can be given.", maxBulkPriceMarkets))
+		return
+	}
+	for _, market := range req.Markets {
+		if _, ok := ctx.App.GetMarket(market); !ok {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", fmt.Sprintf("Market %q is not valid.", market))
+			return
+		}
+	}
+	err = checkPriceFields(req.Fields)
+	if err != nil {
+		ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	page := PricesPage{Prices: make(map[string]map[int64]interface{}, len(req.Markets))}
+	typeIDs := make([]int64, 0, len(req.Types))
+	for _, t := range req.Types {
+		typeID, name, err := ctx.resolvePriceType(t)
+		if err != nil {
+			ctx.renderErrorPage(r, w, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		if typeID == 0 {
+			page.Unknown = append(page.Unknown, name)
+			continue
+		}
+		if name != "" {
+			if page.TypeIDs == nil {
+				page.TypeIDs = make(map[string]int64)
+			}
+			page.TypeIDs[name] = typeID
+		}
+		typeIDs = append(typeIDs, typeID)
+	}
+
+	for _, market := range req.Markets {
+		prices, err := ctx.App.PriceDB.GetPrices(market, typeIDs)
+		if err != nil {
+			ctx.renderServerError(r, w, err)
+			return
+		}
+		marketPrices := make(map[int64]interface{}, len(prices))
+		for typeID, p := range prices {
+			marketPrices[typeID], err = selectPriceFields(p, req.Fields)
+			if err != nil {
+				ctx.renderServerError(r, w, err)
+				return
+			}
+		}
+		page.Prices[market] = marketPrices
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// resolvePriceType returns the type ID of a type given by ID or by name. The name is returned for types given by
+// name, and the type ID is 0 if the type doesn't exist.
+func (ctx *Context) resolvePriceType(t interface{}) (int64, string, error) {
+	switch v := t.(type) {
+	case float64:
+		typeID := int64(v)
+		if _, ok := ctx.App.TypeDB.GetTypeByID(typeID); !ok {
+			return 0, strconv.FormatInt(typeID, 10), nil
+		}
+		return typeID, "", nil
+	case string:
+		if typeID, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return ctx.resolvePriceType(float64(typeID))
+		}
+		eveType, ok := ctx.App.TypeDB.GetType(v)
+		if !ok {
+			return 0, v, nil
+		}
+		return eveType.ID, v, nil
+	default:
+		return 0, "", fmt.Errorf("types must be type IDs or names")
+	}
+}
+
+// checkPriceFields returns an error if one of the fields isn't a field of the prices, like "sell.min" or "updated"
+func checkPriceFields(fields []string) error {
+	doc, err := pricesDocument(evepraisal.Prices{})
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if _, ok := lookupPriceField(doc, field); !ok {
+			return fmt.Errorf("Field %q is not valid.", field)
+		}
+	}
+	return nil
+}
+
+// selectPriceFields returns only the given fields of the prices, keeping them nested the same way. All of the prices
+// are returned when no fields are given.
+func selectPriceFields(prices evepraisal.Prices, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return prices, nil
+	}
+	doc, err := pricesDocument(prices)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]interface{})
+	for _, field := range fields {
+		value, ok := lookupPriceField(doc, field)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(field, ".")
+		parent := selected
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := parent[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				parent[part] = child
+			}
+			parent = child
+		}
+		parent[parts[len(parts)-1]] = value
+	}
+	return selected, nil
+}
+
+// pricesDocument returns the prices as they're encoded in JSON
+func pricesDocument(prices evepraisal.Prices) (map[string]interface{}, error) {
+	buf, err := json.Marshal(prices)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(buf, &doc)
+	return doc, err
+}
+
+func lookupPriceField(doc map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, part := range strings.Split(field, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evepraisal/go-evepraisal"
+	"github.com/evepraisal/go-evepraisal/typedb"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTypeDB struct {
+	typedb.TypeDB
+	types []typedb.EveType
+}
+
+func (db testTypeDB) GetType(name string) (typedb.EveType, bool) {
+	for _, t := range db.types {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return typedb.EveType{}, false
+}
+
+func (db testTypeDB) GetTypeByID(typeID int64) (typedb.EveType, bool) {
+	for _, t := range db.types {
+		if t.ID == typeID {
+			return t, true
+		}
+	}
+	return typedb.EveType{}, false
+}
+
+type testPriceDB struct {
+	evepraisal.PriceDB
+	prices map[string]map[int64]evepraisal.Prices
+	reads  int
+}
+
+func (db *testPriceDB) GetPrices(market string, typeIDs []int64) (map[int64]evepraisal.Prices, error) {
+	db.reads++
+	prices := make(map[int64]evepraisal.Prices)
+	for _, typeID := range typeIDs {
+		if p, ok := db.prices[market][typeID]; ok {
+			prices[typeID] = p
+		}
+	}
+	return prices, nil
+}
+
+func TestSelectPriceFields(t *testing.T) {
+	prices := evepraisal.Prices{Strategy: "orders"}.Set(5)
+	assert.NoError(t, checkPriceFields([]string{"sell.min", "buy", "strategy"}))
+	assert.Error(t, checkPriceFields([]string{"sell.cheapest"}))
+	assert.Error(t, checkPriceFields([]string{"strategy.name"}))
+
+	selected, err := selectPriceFields(prices, []string{"sell.min", "buy.max", "strategy"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"sell":     map[string]interface{}{"min": 5.0},
+		"buy":      map[string]interface{}{"max": 5.0},
+		"strategy": "orders",
+	}, selected)
+
+	selected, err = selectPriceFields(prices, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, prices, selected)
+}
+
+func TestHandlePrices(t *testing.T) {
+	priceDB := &testPriceDB{prices: map[string]map[int64]evepraisal.Prices{
+		"jita":  {34: evepraisal.Prices{Strategy: "orders"}.Set(5), 35: evepraisal.Prices{Strategy: "orders"}.Set(10)},
+		"amarr": {34: evepraisal.Prices{Strategy: "orders"}.Set(6)},
+	}}
+	ctx := &Context{App: &evepraisal.App{
+		TypeDB: testTypeDB{types: []typedb.EveType{
+			{ID: 34, Name: "Tritanium"},
+			{ID: 35, Name: "Pyerite"},
+		}},
+		PriceDB: priceDB,
+		Markets: []evepraisal.Market{{Name: "jita"}, {Name: "amarr"}},
+	}}
+
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		assert.Equal(t, http.StatusOK, w.Code)
+		var page map[string]interface{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		return page
+	}
+
+	w := httptest.NewRecorder()
+	ctx.HandlePrices(w, httptest.NewRequest("GET", "/prices?types=34,Pyerite,Unobtainium&markets=jita,amarr&fields=sell.min", nil))
+	assert.Equal(t, map[string]interface{}{
+		"prices": map[string]interface{}{
+			"jita": map[string]interface{}{
+				"34": map[string]interface{}{"sell": map[string]interface{}{"min": 5.0}},
+				"35": map[string]interface{}{"sell": map[string]interface{}{"min": 10.0}},
+			},
+			"amarr": map[string]interface{}{
+				"34": map[string]interface{}{"sell": map[string]interface{}{"min": 6.0}},
+			},
+		},
+		"type_ids": map[string]interface{}{"Pyerite": 35.0},
+		"unknown":  []interface{}{"Unobtainium"},
+	}, decode(w))
+	// One read per market
+	assert.Equal(t, 2, priceDB.reads)
+
+	w = httptest.NewRecorder()
+	body := strings.NewReader(`{"types": [35, "tritanium"], "markets": ["jita"], "fields": ["buy.max"]}`)
+	req := httptest.NewRequest("POST", "/prices", body)
+	req.Header.Set("Content-Type", "application/json")
+	ctx.HandlePrices(w, req)
+	page := decode(w)
+	assert.Equal(t, map[string]interface{}{
+		"jita": map[string]interface{}{
+			"34": map[string]interface{}{"buy": map[string]interface{}{"max": 5.0}},
+			"35": map[string]interface{}{"buy": map[string]interface{}{"max": 10.0}},
+		},
+	}, page["prices"])
+	// Without any markets the default market is used
+	ctx.CookieStore = sessions.NewCookieStore([]byte("secret"))
+	ctx.App.DefaultMarket = "amarr"
+	w = httptest.NewRecorder()
+	ctx.HandlePrices(w, httptest.NewRequest("GET", "/prices?types=34&fields=sell.min", nil))
+	assert.Equal(t, map[string]interface{}{
+		"amarr": map[string]interface{}{
+			"34": map[string]interface{}{"sell": map[string]interface{}{"min": 6.0}},
+		},
+	}, decode(w)["prices"])
+}
+
+func TestHandlePricesBodySizeLimit(t *testing.T) {
+	ctx := &Context{App: &evepraisal.App{
+		TypeDB:  testTypeDB{types: []typedb.EveType{{ID: 34, Name: "Tritanium"}}},
+		PriceDB: &testPriceDB{},
+		Markets: []evepraisal.Market{{Name: "jita"}},
+	}}
+
+	w := httptest.NewRecorder()
+	body := `{"markets": ["jita"], "types": ["` + strings.Repeat("a", int(pricesBodySizeLimit)) + `"]}`
+	req := httptest.NewRequest("POST", "/prices", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ctx.HandlePrices(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -103,16 +103,30 @@ func (ctx *Context) HTTPHandler() http.Handler {
 	router.GetFunc("/latest", cors(ctx.HandleLatestAppraisals))
 
 	// View Appraisal
+	router.GetFunc("/a/#appraisalID^[a-zA-Z0-9]+$/haul", cors(ctx.HandleHaulAppraisal))
+	router.GetFunc("/a/#appraisalID^[a-zA-Z0-9]+$/#privateToken^[a-zA-Z0-9]+$/haul", cors(ctx.HandleHaulAppraisal))
 	router.GetFunc("/a/#appraisalID^[a-zA-Z0-9]+$", cors(ctx.HandleViewAppraisal))
 	router.GetFunc("/a/#appraisalID^[a-zA-Z0-9]+$/#privateToken^[a-zA-Z0-9]+$", cors(ctx.HandleViewAppraisal))
 	router.GetFunc("/e/#legacyAppraisalID^[0-9]+$", cors(ctx.HandleViewAppraisal))
 
 	// View Item
+	router.GetFunc(`/item/#typeID^[0-9]+$/history`, cors(ctx.HandleViewItemHistory))
+	router.GetFunc(`/item/#typeID^[0-9]+$/manufacture`, cors(ctx.HandleViewItemManufacture))
 	router.GetFunc(`/item/#typeID^[\S ]+$`, cors(ctx.HandleViewItem))
 
 	// List items
 	router.GetFunc("/items", cors(ctx.HandleViewItems))
 
+	// Bulk prices
+	router.GetFunc("/prices", cors(ctx.HandlePrices))
+	router.PostFunc("/prices", cors(ctx.HandlePrices))
+
+	// Arbitrage
+	router.GetFunc("/arbitrage", cors(ctx.HandleArbitrage))
+
+	// LP store
+	router.GetFunc("/lp-store", cors(ctx.HandleLPStore))
+
 	// Search
 	router.GetFunc("/search", cors(ctx.HandleSearch))
 
@@ -0,0 +1,25 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testGet calls the handler with a GET request for the given URL, asking for the given format
+func testGet(handler http.HandlerFunc, url string, format string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", url, nil)
+	r.Header.Set("format", format)
+	handler(w, r)
+	return w
+}
+
+// assertBadQueries checks that the handler answers each of the queries to path with 400 Bad Request
+func assertBadQueries(t *testing.T, handler http.HandlerFunc, path string, queries []string) {
+	for _, query := range queries {
+		assert.Equal(t, http.StatusBadRequest, testGet(handler, path+"?"+query, formatJSON).Code, query)
+	}
+}
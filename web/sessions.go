@@ -4,6 +4,8 @@ import (
 	"encoding/gob"
 	"log"
 	"net/http"
+
+	"github.com/evepraisal/go-evepraisal"
 )
 
 var (
@@ -13,6 +15,7 @@ var (
 
 func init() {
 	gob.Register(FlashMessage{})
+	gob.Register(evepraisal.ReprocessingOptions{})
 }
 
 // FlashMessage is used to contain a message that only shows up for a user once
@@ -94,6 +97,20 @@ func (ctx *Context) getSessionFloat64WithDefault(r *http.Request, key string, de
 	return float64Value
 }
 
+func (ctx *Context) getSessionReprocessingWithDefault(r *http.Request, key string, defaultValue evepraisal.ReprocessingOptions) evepraisal.ReprocessingOptions {
+	value := ctx.getSessionValue(r, key)
+	if value == nil {
+		return defaultValue
+	}
+
+	options, ok := value.(evepraisal.ReprocessingOptions)
+	if !ok {
+		return defaultValue
+	}
+
+	return options
+}
+
 func (ctx *Context) setSessionValue(r *http.Request, w http.ResponseWriter, name string, value interface{}) {
 	session, _ := ctx.CookieStore.Get(r, sessionKey)
 	session.Values[name] = value
@@ -46,14 +46,23 @@ type namedThing struct {
 	DisplayName string
 }
 
-var selectableMarkets = []namedThing{
-	{Name: "jita", DisplayName: "Jita"},
-	{Name: "perimeter", DisplayName: "Perimeter"},
-	{Name: "universe", DisplayName: "Universe"},
-	{Name: "amarr", DisplayName: "Amarr"},
-	{Name: "dodixie", DisplayName: "Dodixie"},
-	{Name: "hek", DisplayName: "Hek"},
-	{Name: "rens", DisplayName: "Rens"},
+// selectableMarkets returns the markets that can be chosen for an appraisal
+func (ctx *Context) selectableMarkets() []namedThing {
+	allMarkets := ctx.App.AllMarkets()
+	markets := make([]namedThing, len(allMarkets))
+	for i, market := range allMarkets {
+		markets[i] = namedThing{Name: market.Name, DisplayName: market.DisplayName}
+	}
+	return markets
+}
+
+// selectablePricingPolicies returns the pricing policies that can be chosen for an appraisal
+func selectablePricingPolicies() []namedThing {
+	policies := make([]namedThing, len(evepraisal.PricingPolicies))
+	for i, policy := range evepraisal.PricingPolicies {
+		policies[i] = namedThing{Name: policy.Name, DisplayName: policy.DisplayName}
+	}
+	return policies
 }
 
 var selectableVisibilities = []namedThing{
@@ -70,6 +79,12 @@ type PageRoot struct {
 		Visibilities         []namedThing
 		SelectedPersist      bool
 		PricePercentage      float64
+		SimulateDepth        bool
+		BestPrice            bool
+		Reprocess            bool
+		Reprocessing         evepraisal.ReprocessingOptions
+		SelectedPricing      string
+		PricingPolicies      []namedThing
 		ExpireAfter          string
 		BaseURL              string
 		BaseURLWithoutScheme string
@@ -101,12 +116,18 @@ func (ctx *Context) renderWithRoot(r *http.Request, w http.ResponseWriter, templ
 		}
 	} else {
 		root.UI.Path = r.URL.Path
-		root.UI.SelectedMarket = ctx.getSessionValueWithDefault(r, "market", "jita")
-		root.UI.Markets = selectableMarkets
+		root.UI.SelectedMarket = ctx.getSessionValueWithDefault(r, "market", ctx.App.DefaultMarketName())
+		root.UI.Markets = ctx.selectableMarkets()
 		root.UI.SelectedVisibility = ctx.getSessionValueWithDefault(r, "visibility", "public")
 		root.UI.Visibilities = selectableVisibilities
 		root.UI.SelectedPersist = ctx.getSessionBooleanWithDefault(r, "persist", true)
 		root.UI.PricePercentage = ctx.getSessionFloat64WithDefault(r, "price_percentage", 100)
+		root.UI.SelectedPricing = ctx.getSessionValueWithDefault(r, "pricing", evepraisal.DefaultPricingPolicy)
+		root.UI.PricingPolicies = selectablePricingPolicies()
+		root.UI.SimulateDepth = ctx.getSessionBooleanWithDefault(r, "simulate_depth", false)
+		root.UI.BestPrice = ctx.getSessionBooleanWithDefault(r, "best_price", false)
+		root.UI.Reprocess = ctx.getSessionBooleanWithDefault(r, "reprocess", false)
+		root.UI.Reprocessing = ctx.getSessionReprocessingWithDefault(r, "reprocessing", evepraisal.DefaultReprocessingOptions)
 		root.UI.ExpireAfter = ctx.getSessionValueWithDefault(r, "expire_after", "360h")
 		root.UI.BaseURLWithoutScheme = strings.TrimPrefix(strings.TrimPrefix(ctx.BaseURL, "https://"), "http://")
 		root.UI.BaseURL = ctx.BaseURL